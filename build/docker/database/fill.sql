@@ -11,18 +11,34 @@ VALUES ('a', 'a', 50, 50),
        ('j', 'j', 25, 50);
 
 INSERT INTO items (id, name, length_meters, width_meters, height_meters, weight_kg)
-SELECT  series.series::text, series.series::text, random()*20, random()*10, random()*5, random()*50 FROM generate_series(1, 20) AS series;
+SELECT  series.series::text, series.series::text, 0.1+random()*20, 0.1+random()*10, 0.1+random()*5, 0.1+random()*50 FROM generate_series(1, 20) AS series;
 
-INSERT INTO storehouses_items (storehouse_id, item_id, items_count)
+INSERT INTO storehouses_items (storehouse_id, item_id, on_hand)
 SELECT storehouses.id, items.id, trunc(random()*9+7) FROM storehouses JOIN items ON true WHERE random() < 0.8;
 
 INSERT INTO reservations (id, destination_latitude, destination_longitude)
 VALUES ('one-reservation', 42, 43), ('two-reservation', 48, 49);
 
 INSERT INTO reservation_items (reservation_id, item_id, storehouse_id, items_count)
-SELECT 'one-reservation', si.item_id, si.storehouse_id, si.items_count-3
+SELECT 'one-reservation', si.item_id, si.storehouse_id, si.on_hand-3
 FROM storehouses_items AS si ORDER BY random() LIMIT 3;
 
 INSERT INTO reservation_items (reservation_id, item_id, storehouse_id, items_count)
-SELECT 'two-reservation', si.item_id, si.storehouse_id, si.items_count-3
-FROM storehouses_items AS si ORDER BY random() LIMIT 3;
\ No newline at end of file
+SELECT 'two-reservation', si.item_id, si.storehouse_id, si.on_hand-3
+FROM storehouses_items AS si ORDER BY random() LIMIT 3;
+
+-- reserved units are kept in storehouses in addition to available ones
+UPDATE storehouses_items AS si SET on_hand = si.on_hand + ri.reserved, reserved = ri.reserved
+FROM (
+    SELECT storehouse_id, item_id, SUM(items_count) AS reserved FROM reservation_items GROUP BY storehouse_id, item_id
+) AS ri
+WHERE si.storehouse_id = ri.storehouse_id AND si.item_id = ri.item_id;
+
+-- the ledger starts with receipts of all stock, then the seeded reservations take their items
+INSERT INTO stock_movements (id, type, storehouse_id, item_id, quantity, available_delta, reserved_delta, reason, actor)
+SELECT gen_random_uuid()::text, 'receipt', storehouse_id, item_id, on_hand, on_hand, 0, 'supplier-delivery', 'seed'
+FROM storehouses_items;
+
+INSERT INTO stock_movements (id, type, storehouse_id, item_id, quantity, available_delta, reserved_delta, reservation_id)
+SELECT gen_random_uuid()::text, 'reserve', storehouse_id, item_id, items_count, -items_count, items_count, reservation_id
+FROM reservation_items;
@@ -2,7 +2,23 @@ CREATE TABLE storehouses (
     id TEXT PRIMARY KEY,
     name TEXT UNIQUE NOT NULL,
     latitude float8 NOT NULL,
-    longitude float8 NOT NULL
+    longitude float8 NOT NULL,
+    deactivated BOOLEAN NOT NULL DEFAULT FALSE,
+    status TEXT NOT NULL DEFAULT 'open',
+    -- time zone, weekly opening hours and holidays, see domain.Schedule
+    schedule JSONB NOT NULL DEFAULT '{}',
+    -- vehicle profile, all limits are NULL if shipments are not limited
+    vehicle_max_weight_kg float8,
+    vehicle_max_volume_m3 float8,
+    vehicle_max_length_m float8,
+    -- zero means any number of vehicles
+    vehicle_max_count INT,
+
+    CONSTRAINT storehouse_status_is_known CHECK(status IN ('open', 'closed', 'maintenance')),
+    CONSTRAINT storehouse_vehicle_limits_set_together CHECK(
+        (vehicle_max_weight_kg IS NULL) = (vehicle_max_volume_m3 IS NULL) AND
+        (vehicle_max_weight_kg IS NULL) = (vehicle_max_length_m IS NULL) AND
+        (vehicle_max_weight_kg IS NULL) = (vehicle_max_count IS NULL))
 );
 
 CREATE TABLE items (
@@ -11,31 +27,96 @@ CREATE TABLE items (
     length_meters float8 NOT NULL,
     width_meters float8 NOT NULL,
     height_meters float8 NOT NULL,
-    weight_kg float8 NOT NULL
+    weight_kg float8 NOT NULL,
+
+    CONSTRAINT item_size_must_be_positive CHECK(length_meters > 0 AND width_meters > 0 AND height_meters > 0),
+    CONSTRAINT item_weight_must_be_positive CHECK(weight_kg > 0)
 );
 
 CREATE TABLE storehouses_items (
     id BIGSERIAL PRIMARY KEY,
     storehouse_id TEXT REFERENCES storehouses (id) NOT NULL,
     item_id TEXT REFERENCES items (id) NOT NULL,
-    items_count INT NOT NULL,
+    -- reserved units are still on hand until they are shipped
+    on_hand INT NOT NULL,
+    reserved INT NOT NULL DEFAULT 0,
+    available INT GENERATED ALWAYS AS (on_hand - reserved) STORED,
 
-    CONSTRAINT storehouse_items_count_must_be_non_negative CHECK(items_count > 0),
+    CONSTRAINT storehouse_items_reserved_must_be_non_negative CHECK(reserved >= 0),
+    CONSTRAINT storehouse_items_reserved_must_be_on_hand CHECK(reserved <= on_hand),
     CONSTRAINT storehouse_and_item_ids_non_repeatable UNIQUE(storehouse_id, item_id)
 );
 
 CREATE TABLE reservations (
     id TEXT PRIMARY KEY,
     destination_latitude float8 NOT NULL,
-    destination_longitude float8 NOT NULL
+    destination_longitude float8 NOT NULL,
+    status TEXT NOT NULL DEFAULT 'held',
+    -- allocation strategy of entries which are not manual, empty means the default one
+    strategy TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    version BIGINT NOT NULL DEFAULT 0,
+
+    CONSTRAINT reservation_status_must_be_known CHECK(status IN ('held', 'confirmed', 'picked', 'shipped', 'cancelled'))
 );
 
+CREATE INDEX reservations_expires_at_idx ON reservations (expires_at) WHERE status = 'held' AND expires_at IS NOT NULL;
+
+CREATE INDEX reservations_created_at_idx ON reservations (created_at, id);
+
 CREATE TABLE reservation_items (
     id BIGSERIAL PRIMARY KEY,
     reservation_id TEXT REFERENCES reservations (id) NOT NULL,
     item_id TEXT REFERENCES items (id) NOT NULL,
     storehouse_id TEXT REFERENCES storehouses (id) NOT NULL,
     items_count INT NOT NULL,
+    -- placed in the storehouse by the client, reoptimization doesn't move it
+    manual BOOLEAN NOT NULL DEFAULT FALSE,
 
     CONSTRAINT reservation_items_count_must_be_non_negative CHECK(items_count > 0)
-)
\ No newline at end of file
+);
+
+CREATE INDEX reservation_items_reservation_id_idx ON reservation_items (reservation_id);
+
+CREATE TABLE idempotency_keys (
+    key TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    response JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+CREATE TABLE stock_movements (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    storehouse_id TEXT REFERENCES storehouses (id) NOT NULL,
+    item_id TEXT REFERENCES items (id) NOT NULL,
+    quantity INT NOT NULL,
+    available_delta INT NOT NULL,
+    reserved_delta INT NOT NULL,
+    -- not a foreign key: fully released reservations are deleted, but their movements stay
+    reservation_id TEXT,
+    reason TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT stock_movement_type_must_be_known
+        CHECK(type IN ('receipt', 'write-off', 'adjustment', 'reserve', 'release', 'ship', 'transfer')),
+    CONSTRAINT stock_movement_quantity_must_be_non_zero CHECK(quantity <> 0)
+);
+
+CREATE INDEX stock_movements_storehouse_item_idx ON stock_movements (storehouse_id, item_id, created_at);
+
+-- the ledger is append-only: the history is never rewritten
+CREATE FUNCTION forbid_stock_movements_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_append_only
+    BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION forbid_stock_movements_change();
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	// storehouse schedules use IANA time zones, the image may have no zoneinfo
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -15,9 +18,12 @@ import (
 	loggers "github.com/adepte-myao/lamoda-test-2023/internal/pkg/logger"
 	"github.com/adepte-myao/lamoda-test-2023/internal/pkg/postgres"
 	"github.com/adepte-myao/lamoda-test-2023/internal/pkg/server"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/services"
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/handlers"
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/repositories"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/workers"
 )
 
 // @title Reservation microservice
@@ -48,17 +54,39 @@ func main() {
 	storehouseRepo := repositories.NewPostgresStorehouse(postgresDB)
 	itemRepo := repositories.NewPostgresItem(postgresDB)
 	reservationRepo := repositories.NewPostgresReservation(postgresDB)
+	idempotencyRepo := repositories.NewPostgresIdempotency(postgresDB)
+	stockMovementRepo := repositories.NewPostgresStockMovement(postgresDB)
+	transactionManager := repositories.NewPostgresTransactionManager(postgresDB)
 
-	service := services.New(storehouseRepo, itemRepo, reservationRepo)
+	costModel, err := newCostModel(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	strategies := map[domain.AllocationStrategyName]ports.AllocationStrategy{
+		domain.NearestFirst:      domain.NearestFirstStrategy{},
+		domain.FewestStorehouses: domain.FewestStorehousesStrategy{NodeBudget: cfg.Allocation.NodeBudget},
+		domain.CostOptimal:       domain.CostOptimalStrategy{NodeBudget: cfg.Allocation.NodeBudget},
+		domain.SingleStorehouse:  domain.SingleStorehouseStrategy{},
+	}
+
+	service := services.New(storehouseRepo, itemRepo, reservationRepo, idempotencyRepo, stockMovementRepo,
+		transactionManager, costModel, strategies)
 
 	handler := handlers.NewReservationHandler(service, validate)
 
+	storehouseService := services.NewStorehouseService(storehouseRepo, reservationRepo, stockMovementRepo, transactionManager)
+	storehouseHandler := handlers.NewStorehouseHandler(storehouseService, validate)
+
+	itemService := services.NewItemService(itemRepo)
+	itemHandler := handlers.NewItemHandler(itemService, validate)
+
+	stockService := services.NewStockService(storehouseRepo, itemRepo, stockMovementRepo, transactionManager)
+	stockHandler := handlers.NewStockHandler(stockService, validate)
+
 	engine := gin.New()
 	engine.Use(gin.Recovery())
-
-	if cfg.Logger.Level == "debug" {
-		engine.Use(loggers.SendErrorsToClient)
-	}
+	engine.Use(handlers.ErrorHandler(logger))
 
 	engine.GET("/ping", func(context *gin.Context) {
 		context.JSON(http.StatusOK, map[string]string{"info": "pong"})
@@ -68,8 +96,48 @@ func main() {
 	engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	engine.POST("/reserve", handler.Reserve)
+	engine.POST("/reserve/quote", handler.Quote)
 	engine.POST("/release", handler.Release)
+	engine.GET("/reservations", handler.ListReservations)
+	engine.GET("/reservations/:id", handler.GetReservation)
+	engine.POST("/reservations/:id/confirm", handler.Confirm)
+	engine.POST("/reservations/:id/pick", handler.Pick)
+	engine.POST("/reservations/:id/ship", handler.Ship)
+	engine.POST("/reservations/:id/cancel", handler.Cancel)
 	engine.GET("/get-unreserved-items", handler.GetUnreserved)
+	engine.GET("/availability", handler.GetAvailability)
+
+	engine.POST("/storehouses", storehouseHandler.Create)
+	engine.GET("/storehouses", storehouseHandler.GetAll)
+	engine.GET("/storehouses/:id", storehouseHandler.Get)
+	engine.PUT("/storehouses/:id", storehouseHandler.Update)
+	engine.POST("/storehouses/:id/relocate", storehouseHandler.Relocate)
+	engine.POST("/storehouses/:id/status", storehouseHandler.SetStatus)
+	engine.POST("/storehouses/:id/deactivate", storehouseHandler.Deactivate)
+	engine.GET("/storehouses/:id/stock", stockHandler.GetStock)
+	engine.GET("/storehouses/:id/stock-history", stockHandler.GetStockAt)
+
+	engine.POST("/items", itemHandler.Create)
+	engine.GET("/items", itemHandler.Search)
+	engine.GET("/items/:id", itemHandler.Get)
+	engine.PUT("/items/:id", itemHandler.Update)
+
+	engine.POST("/stock/receipts", stockHandler.Receive)
+	engine.POST("/stock/write-offs", stockHandler.WriteOff)
+	engine.POST("/stock/adjustments", stockHandler.Adjust)
+
+	sweeper, err := workers.NewExpirySweeper(cfg, service, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	sweeperCtx, stopSweeper := context.WithCancel(context.Background())
+	sweeperDone := make(chan struct{})
+
+	go func() {
+		defer close(sweeperDone)
+		sweeper.Run(sweeperCtx)
+	}()
 
 	httpServer := server.New(cfg, engine.Handler(), logger)
 
@@ -77,4 +145,72 @@ func main() {
 	if err != nil {
 		logger.Error(err)
 	}
+
+	// the sweeper uses the database, so it must finish before the connection is closed
+	stopSweeper()
+	<-sweeperDone
+}
+
+func newCostModel(cfg configs.AppConfig) (*domain.RuleCostModel, error) {
+	toCoefficients := func(coefficients configs.CostCoefficientsConfig) domain.CostCoefficients {
+		tiers := make([]domain.DistanceTier, 0, len(coefficients.Tiers))
+		for _, tier := range coefficients.Tiers {
+			tiers = append(tiers, domain.DistanceTier{UpToKm: tier.UpToKm, RatePerKg: tier.RatePerKg})
+		}
+
+		return domain.CostCoefficients{
+			Formula:           domain.CostFormula(coefficients.Formula),
+			K1:                coefficients.K1,
+			K2:                coefficients.K2,
+			VolumetricDivisor: coefficients.VolumetricDivisor,
+			Tiers:             tiers,
+		}
+	}
+
+	regions := make([]domain.Region, 0, len(cfg.Regions))
+	for _, region := range cfg.Regions {
+		regions = append(regions, domain.Region(region))
+	}
+
+	distances, err := newDistanceProvider(cfg, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]domain.CostRule, 0, len(cfg.Cost.Rules))
+	for _, rule := range cfg.Cost.Rules {
+		rules = append(rules, domain.CostRule{
+			StorehouseID: domain.StoreHouseID(rule.StorehouseID),
+			Region:       rule.Region,
+			Coefficients: toCoefficients(rule.Coefficients),
+		})
+	}
+
+	costModel, err := domain.NewRuleCostModel(toCoefficients(cfg.Cost.Default), regions, rules, distances)
+	if err != nil {
+		return nil, fmt.Errorf("creating cost model: %w", err)
+	}
+
+	return costModel, nil
+}
+
+func newDistanceProvider(cfg configs.AppConfig, regions []domain.Region) (domain.DistanceProvider, error) {
+	switch cfg.Distance.Provider {
+	case "", "haversine":
+		return domain.HaversineDistanceProvider{}, nil
+	case "matrix":
+		routes, err := repositories.LoadDistanceMatrix(cfg.Distance.MatrixFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading distance matrix: %w", err)
+		}
+
+		provider, err := domain.NewMatrixDistanceProvider(regions, routes)
+		if err != nil {
+			return nil, fmt.Errorf("creating distance matrix: %w", err)
+		}
+
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown distance provider: %s", cfg.Distance.Provider)
+	}
 }
@@ -42,10 +42,63 @@ type AppConfig struct {
 		DB       string
 	} `toml:"database"`
 
+	Sweeper struct {
+		IntervalSeconds int `toml:"interval_seconds"`
+		BatchSize       int `toml:"batch_size"`
+		// IdempotencyRetentionHours is how long an idempotency key is kept before the sweeper deletes it
+		IdempotencyRetentionHours int `toml:"idempotency_retention_hours"`
+	} `toml:"sweeper"`
+
+	Allocation struct {
+		// NodeBudget limits the search of cost-optimal and fewest-storehouses strategies, zero means the default
+		NodeBudget int `toml:"node_budget"`
+	} `toml:"allocation"`
+
 	Logger struct {
 		Level             string `toml:"level"`
 		StackTraceEnabled bool   `toml:"stack_trace_enabled"`
 	} `toml:"logger"`
+
+	// Regions are destination areas used by cost rules and the distance matrix
+	Regions []RegionConfig `toml:"regions"`
+
+	Distance struct {
+		// Provider is either "haversine" or "matrix"
+		Provider   string `toml:"provider"`
+		MatrixFile string `toml:"matrix_file"`
+	} `toml:"distance"`
+
+	Cost struct {
+		Default CostCoefficientsConfig `toml:"default"`
+		Rules   []CostRuleConfig       `toml:"rules"`
+	} `toml:"cost"`
+}
+
+type CostCoefficientsConfig struct {
+	Formula           string               `toml:"formula"`
+	K1                float64              `toml:"k1"`
+	K2                float64              `toml:"k2"`
+	VolumetricDivisor float64              `toml:"volumetric_divisor"`
+	Tiers             []DistanceTierConfig `toml:"tiers"`
+}
+
+type DistanceTierConfig struct {
+	UpToKm    float64 `toml:"up_to_km"`
+	RatePerKg float64 `toml:"rate_per_kg"`
+}
+
+type RegionConfig struct {
+	Name         string  `toml:"name"`
+	MinLatitude  float64 `toml:"min_latitude"`
+	MaxLatitude  float64 `toml:"max_latitude"`
+	MinLongitude float64 `toml:"min_longitude"`
+	MaxLongitude float64 `toml:"max_longitude"`
+}
+
+type CostRuleConfig struct {
+	StorehouseID string                 `toml:"storehouse_id"`
+	Region       string                 `toml:"region"`
+	Coefficients CostCoefficientsConfig `toml:"coefficients"`
 }
 
 func LoadDefault() (AppConfig, error) {
@@ -26,7 +26,9 @@ func TestLoadDefault(t *testing.T) {
 
 	assert.NotEmpty(t, cfg.Server)
 	assert.NotEmpty(t, cfg.Database)
+	assert.NotEmpty(t, cfg.Sweeper)
 	assert.NotEmpty(t, cfg.Logger)
+	assert.NotEmpty(t, cfg.Cost.Default)
 
 	// Should not change because app will be executed in container environments only
 	assert.Equal(t, cfg.Server.ListenAddr, "0.0.0.0")
@@ -15,9 +15,59 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/availability": {
+            "get": {
+                "description": "Returns the total number of available units of each item over active storehouses with a breakdown by storehouse",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "item IDs",
+                        "name": "item-id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.AvailabilityResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
         "/get-unreserved-items": {
             "get": {
-                "description": "Returns all unreserved items for given storehouse",
+                "description": "Returns items of given storehouse which have available units with on-hand, reserved and available counts.\nItems may be filtered by repeated item-id parameter. Items are ordered by ID, to get the next page, pass nextCursor as cursor",
                 "consumes": [
                     "application/json"
                 ],
@@ -34,6 +84,28 @@ const docTemplate = `{
                         "name": "storehouse-id",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "item IDs to return",
+                        "name": "item-id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "nextCursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 20 by default, 100 at most",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -46,15 +118,76 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
                         }
                     }
                 }
             }
         },
-        "/release": {
+        "/items": {
+            "get": {
+                "description": "Returns items which name contains the query ordered by ID.\nPages are returned by cursor: nextCursor of the response is passed to get the next page",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "item"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "part of the item name, case-insensitive",
+                        "name": "query",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "cursor of the page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 20 by default, 100 at most",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.SearchItemsResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Releases items for given reservation. If there is no items left, deleted the reservation",
+                "description": "Adds the item to the catalog. All dimensions and the weight must be positive",
                 "consumes": [
                     "application/json"
                 ],
@@ -62,16 +195,16 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "reservation"
+                    "item"
                 ],
                 "parameters": [
                     {
-                        "description": "reservation ID and items to release",
+                        "description": "item",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/ports.ReleaseRequestDTO"
+                            "$ref": "#/definitions/domain.Item"
                         }
                     }
                 ],
@@ -79,21 +212,129 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/ports.ReservationResponseDTO"
+                            "$ref": "#/definitions/domain.Item"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
                         }
                     }
                 }
             }
         },
-        "/reserve": {
+        "/items/{id}": {
+            "get": {
+                "description": "Returns the item",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "item"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Item"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces name, size and weight of the item. Costs of reservations are calculated with the new values",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "item"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "name, size and weight",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.UpdateItemRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Item"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/release": {
             "post": {
-                "description": "Creates a reservation for given items if storehouse have required amount",
+                "description": "Releases items for given reservation. If there is no items left, deleted the reservation.\nEach item to release may contain: only storehouse ID to release everything from the storehouse,\nonly item ID to release units from the most expensive storehouses, or both. Zero count releases all matching units.\nIf reoptimize is set, the rest of the reservation is moved to other storehouses when it lowers the total cost.\nA repeated request with the same Idempotency-Key returns the original response; the key reused with another payload is rejected",
                 "consumes": [
                     "application/json"
                 ],
@@ -105,12 +346,18 @@ const docTemplate = `{
                 ],
                 "parameters": [
                     {
-                        "description": "destination location and items to reserve",
+                        "type": "string",
+                        "description": "key to retry the request safely",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "reservation ID and items to release",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.ReserveRequest"
+                            "$ref": "#/definitions/ports.ReleaseRequestDTO"
                         }
                     }
                 ],
@@ -124,117 +371,1776 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "domain.Item": {
-            "type": "object",
-            "properties": {
-                "id": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "size": {
-                    "$ref": "#/definitions/domain.Size"
-                },
-                "weightKilograms": {
-                    "type": "number"
-                }
-            }
         },
-        "domain.ItemData": {
-            "type": "object",
-            "properties": {
-                "count": {
-                    "type": "integer"
-                },
-                "item": {
-                    "$ref": "#/definitions/domain.Item"
+        "/reservations": {
+            "get": {
+                "description": "Returns reservations ordered by creation time. Reservations are filtered by an entry with the storehouse and item,\nby the destination bounding box and by the creation time range. To get the next page, pass nextCursor as cursor",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "storehouse-id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "item ID",
+                        "name": "item-id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "minimal destination latitude",
+                        "name": "min-latitude",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "maximal destination latitude",
+                        "name": "max-latitude",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "minimal destination longitude",
+                        "name": "min-longitude",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "maximal destination longitude",
+                        "name": "max-longitude",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "inclusive start of the creation time range, RFC 3339",
+                        "name": "created-from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "exclusive end of the creation time range, RFC 3339",
+                        "name": "created-to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "nextCursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 20 by default, 100 at most",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.ListReservationsResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}": {
+            "get": {
+                "description": "Returns the reservation with its current total cost",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.ReservationResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}/cancel": {
+            "post": {
+                "description": "Cancels a reservation which is not shipped yet and returns its items to storehouses",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Reservation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}/confirm": {
+            "post": {
+                "description": "Confirms a held reservation, so it becomes a committed order and doesn't expire anymore",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Reservation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}/pick": {
+            "post": {
+                "description": "Marks a confirmed reservation as picked in storehouses",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Reservation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}/ship": {
+            "post": {
+                "description": "Marks a picked reservation as shipped. Its items are consumed and never return to storehouses",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Reservation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/reserve": {
+            "post": {
+                "description": "Creates a reservation for given items if storehouse have required amount.\nA repeated request with the same Idempotency-Key returns the original response; the key reused with another payload is rejected.\nIf explain is set, the response lists candidate storehouses of each entry ranked by distance and cost with the reason each one was picked or rejected",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key to retry the request safely",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "destination location and items to reserve",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReserveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.ReservationResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/reserve/quote": {
+            "post": {
+                "description": "Returns the allocation and the cost the reservation would get with the current stock, split into shipments by storehouses.\nNothing is reserved, so the real reservation may differ if the stock changes. Explain mode is the same as for reserve",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservation"
+                ],
+                "parameters": [
+                    {
+                        "description": "destination location and items to reserve",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.ReserveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.QuoteResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/stock/adjustments": {
+            "post": {
+                "description": "Corrects the count of the item in the storehouse, a negative quantity decreases it.\nReasons: inventory-count, correction",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "parameters": [
+                    {
+                        "description": "storehouse, item, non-zero quantity, reason and actor",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.StockMovementRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StockMovement"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/stock/receipts": {
+            "post": {
+                "description": "Records items brought to the storehouse. Reasons: supplier-delivery, customer-return",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "parameters": [
+                    {
+                        "description": "storehouse, item, positive quantity, reason and actor",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.StockMovementRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StockMovement"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/stock/write-offs": {
+            "post": {
+                "description": "Records items removed from the storehouse. Reasons: damaged, lost, expired.\nReserved items can't be written off",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "parameters": [
+                    {
+                        "description": "storehouse, item, positive quantity, reason and actor",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.StockMovementRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StockMovement"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/storehouses": {
+            "get": {
+                "description": "Returns all storehouses including deactivated ones ordered by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storehouse"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.StoreHouse"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates an empty storehouse. ID and name must be unique",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storehouse"
+                ],
+                "parameters": [
+                    {
+                        "description": "storehouse ID, name, location, schedule and vehicle profile",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.CreateStorehouseRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StoreHouse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/storehouses/{id}": {
+            "get": {
+                "description": "Returns the storehouse",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storehouse"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StoreHouse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Renames the storehouse and replaces its schedule and vehicle profile",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storehouse"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "new name, schedule and vehicle profile",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.UpdateStorehouseRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StoreHouse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/storehouses/{id}/deactivate": {
+            "post": {
+                "description": "Deactivates the storehouse, so new reservations don't take items from it.\nIf open reservations take items from the storehouse, a transfer plan is required:\nstock left in the storehouse is moved to the target storehouse and the reservations take items from the target instead",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storehouse"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "transfer plan",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/ports.DeactivateStorehouseRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.DeactivationResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/storehouses/{id}/relocate": {
+            "post": {
+                "description": "Changes the location of the storehouse. Costs of reservations are calculated with the new location",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storehouse"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "new location",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.RelocateStorehouseRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StoreHouse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/storehouses/{id}/status": {
+            "post": {
+                "description": "Opens, closes or puts the storehouse into maintenance. Its stock and open reservations are kept,\nbut new reservations take items only from open storehouses",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storehouse"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "new status",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ports.StorehouseStatusRequestDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.StoreHouse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/storehouses/{id}/stock": {
+            "get": {
+                "description": "Returns on-hand, reserved and available units of every item kept in the storehouse.\nReserved units stay on hand until shipped, available = on-hand - reserved",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.GetStockResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        },
+        "/storehouses/{id}/stock-history": {
+            "get": {
+                "description": "Rebuilds available and reserved stock of the storehouse at the moment from the stock ledger",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "storehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "item ID, all items by default",
+                        "name": "item-id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "moment in RFC3339, now by default",
+                        "name": "at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ports.StockAtResponseDTO"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponseDTO"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "domain.AllocationStrategyName": {
+            "type": "string",
+            "enum": [
+                "nearest-first",
+                "fewest-storehouses",
+                "cost-optimal",
+                "single-storehouse",
+                "cost-optimal"
+            ],
+            "x-enum-varnames": [
+                "NearestFirst",
+                "FewestStorehouses",
+                "CostOptimal",
+                "SingleStorehouse",
+                "DefaultAllocationStrategy"
+            ]
+        },
+        "domain.CandidateExplanation": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "description": "Available is the number of available units at the moment of the decision",
+                    "type": "integer"
+                },
+                "costRank": {
+                    "type": "integer"
+                },
+                "distanceKm": {
+                    "type": "number"
+                },
+                "distanceRank": {
+                    "description": "DistanceRank and CostRank start from 1, they are zero for storehouses the allocator didn't consider",
+                    "type": "integer"
+                },
+                "reason": {
+                    "$ref": "#/definitions/domain.CandidateReason"
+                },
+                "storehouseID": {
+                    "type": "string"
+                },
+                "taken": {
+                    "type": "integer"
+                },
+                "unitCost": {
+                    "type": "number"
+                }
+            }
+        },
+        "domain.CandidateReason": {
+            "type": "string",
+            "enum": [
+                "manually-selected",
+                "picked",
+                "demand-satisfied",
+                "higher-total-cost",
+                "incomplete-stock",
+                "storehouse-deactivated",
+                "storehouse-not-open",
+                "outside-dispatch-window",
+                "vehicle-too-small"
+            ],
+            "x-enum-varnames": [
+                "ManuallySelectedCandidate",
+                "PickedCandidate",
+                "DemandSatisfiedCandidate",
+                "HigherTotalCostCandidate",
+                "IncompleteStockCandidate",
+                "DeactivatedCandidate",
+                "NotOpenCandidate",
+                "OutsideDispatchWindowCandidate",
+                "VehicleTooSmallCandidate"
+            ]
+        },
+        "domain.DispatchWindow": {
+            "type": "object",
+            "required": [
+                "to"
+            ],
+            "properties": {
+                "from": {
+                    "description": "From is the moment of the request if empty",
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.EntryExplanation": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "description": "Candidates are ordered by cost rank, storehouses the allocator didn't consider go last",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.CandidateExplanation"
+                    }
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "itemID": {
+                    "type": "string"
+                },
+                "sourceStorehouseID": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.Item": {
+            "type": "object",
+            "required": [
+                "id",
+                "name",
+                "size"
+            ],
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "$ref": "#/definitions/domain.Size"
+                },
+                "weightKilograms": {
+                    "type": "number"
+                }
+            }
+        },
+        "domain.ItemData": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "integer"
+                },
+                "item": {
+                    "$ref": "#/definitions/domain.Item"
+                },
+                "onHand": {
+                    "type": "integer"
+                },
+                "reserved": {
+                    "type": "integer"
                 }
             }
         },
         "domain.Location": {
             "type": "object",
             "required": [
-                "latitude",
-                "longitude"
+                "latitude",
+                "longitude"
+            ],
+            "properties": {
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                }
+            }
+        },
+        "domain.MovementType": {
+            "type": "string",
+            "enum": [
+                "receipt",
+                "write-off",
+                "adjustment",
+                "reserve",
+                "release",
+                "ship",
+                "transfer"
+            ],
+            "x-enum-varnames": [
+                "Receipt",
+                "WriteOff",
+                "Adjustment",
+                "ReserveMovement",
+                "ReleaseMovement",
+                "ShipMovement",
+                "TransferMovement"
+            ]
+        },
+        "domain.OpeningHours": {
+            "type": "object",
+            "properties": {
+                "closes": {
+                    "type": "string",
+                    "example": "18:00"
+                },
+                "opens": {
+                    "type": "string",
+                    "example": "09:00"
+                },
+                "weekday": {
+                    "type": "integer",
+                    "maximum": 6,
+                    "minimum": 0,
+                    "example": 1
+                }
+            }
+        },
+        "domain.ReasonCode": {
+            "type": "string",
+            "enum": [
+                "supplier-delivery",
+                "customer-return",
+                "damaged",
+                "lost",
+                "expired",
+                "inventory-count",
+                "correction"
+            ],
+            "x-enum-varnames": [
+                "SupplierDelivery",
+                "CustomerReturn",
+                "Damaged",
+                "Lost",
+                "Expired",
+                "InventoryCount",
+                "Correction"
+            ]
+        },
+        "domain.ReleaseEntry": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "itemID": {
+                    "type": "string"
+                },
+                "sourceStorehouseID": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.Reservation": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "description": "Approximate is set if the strategy couldn't guarantee the best placement of the entries. It's not stored",
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "destinationLocation": {
+                    "$ref": "#/definitions/domain.Location"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ReserveEntry"
+                    }
+                },
+                "expiresAt": {
+                    "description": "ExpiresAt is the moment when the reservation is released automatically. Nil means it's held until released",
+                    "type": "string"
+                },
+                "gap": {
+                    "description": "Gap bounds how much cheaper the best placement may be than the approximate one. It's not stored",
+                    "type": "number"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.ReservationStatus"
+                },
+                "strategy": {
+                    "description": "Strategy placed the entries which are not manual. Reoptimization uses the same strategy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.AllocationStrategyName"
+                        }
+                    ]
+                }
+            }
+        },
+        "domain.ReservationStatus": {
+            "type": "string",
+            "enum": [
+                "held",
+                "confirmed",
+                "picked",
+                "shipped",
+                "cancelled"
+            ],
+            "x-enum-varnames": [
+                "Held",
+                "Confirmed",
+                "Picked",
+                "Shipped",
+                "Cancelled"
+            ]
+        },
+        "domain.ReserveEntry": {
+            "type": "object",
+            "required": [
+                "count",
+                "itemID"
+            ],
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "itemID": {
+                    "type": "string"
+                },
+                "manual": {
+                    "description": "Manual is set by the service for entries the client placed in the storehouse. Reoptimization doesn't move them",
+                    "type": "boolean"
+                },
+                "sourceStorehouseID": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ReserveRequest": {
+            "type": "object",
+            "properties": {
+                "destinationLocation": {
+                    "$ref": "#/definitions/domain.Location"
+                },
+                "dispatchWindow": {
+                    "description": "DispatchWindow skips storehouses which don't work within it. If empty, only storehouses which are not open are skipped",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.DispatchWindow"
+                        }
+                    ]
+                },
+                "explain": {
+                    "description": "Explain adds to the response why each storehouse was picked or rejected",
+                    "type": "boolean"
+                },
+                "holdFor": {
+                    "description": "HoldFor limits the lifetime of the reservation. If empty, the reservation is held until released",
+                    "type": "string",
+                    "minLength": 0,
+                    "example": "15m"
+                },
+                "itemsToReserve": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ReserveEntry"
+                    }
+                },
+                "strategy": {
+                    "description": "Strategy is the name of the allocation strategy. If empty, DefaultAllocationStrategy is used",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.AllocationStrategyName"
+                        }
+                    ]
+                }
+            }
+        },
+        "domain.Schedule": {
+            "type": "object",
+            "properties": {
+                "holidays": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "2024-01-01"
+                    ]
+                },
+                "hours": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.OpeningHours"
+                    }
+                },
+                "timeZone": {
+                    "description": "TimeZone is an IANA name, empty means UTC",
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                }
+            }
+        },
+        "domain.Shipment": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "number"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ReserveEntry"
+                    }
+                },
+                "storehouseID": {
+                    "type": "string"
+                },
+                "vehicles": {
+                    "description": "Vehicles is the number of vehicles needed by the vehicle profile of the storehouse",
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.Size": {
+            "type": "object",
+            "properties": {
+                "heightMeters": {
+                    "type": "number"
+                },
+                "lengthMeters": {
+                    "type": "number"
+                },
+                "widthMeters": {
+                    "type": "number"
+                }
+            }
+        },
+        "domain.StockLevel": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "integer"
+                },
+                "itemID": {
+                    "type": "string"
+                },
+                "onHand": {
+                    "type": "integer"
+                },
+                "reserved": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.StockMovement": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "availableDelta": {
+                    "type": "integer"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "itemID": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "description": "Quantity is positive for receipts and write-offs, adjustments use the sign for the direction.\nMovements made by reservations have the number of moved units",
+                    "type": "integer"
+                },
+                "reason": {
+                    "$ref": "#/definitions/domain.ReasonCode"
+                },
+                "reservationID": {
+                    "description": "ReservationID is set for movements made by reservations",
+                    "type": "string"
+                },
+                "reservedDelta": {
+                    "type": "integer"
+                },
+                "storehouseID": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/domain.MovementType"
+                }
+            }
+        },
+        "domain.StoreHouse": {
+            "type": "object",
+            "required": [
+                "id",
+                "name"
+            ],
+            "properties": {
+                "deactivated": {
+                    "description": "Deactivated storehouse is kept for the history, but it's not used for new reservations",
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "location": {
+                    "$ref": "#/definitions/domain.Location"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "schedule": {
+                    "$ref": "#/definitions/domain.Schedule"
+                },
+                "status": {
+                    "description": "Status is operational: only open storehouses are used for new reservations. Empty status is open",
+                    "enum": [
+                        "open",
+                        "closed",
+                        "maintenance"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.StorehouseStatus"
+                        }
+                    ]
+                },
+                "vehicle": {
+                    "description": "Vehicle is nil if shipments from the storehouse are not limited",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.VehicleProfile"
+                        }
+                    ]
+                }
+            }
+        },
+        "domain.StorehouseStatus": {
+            "type": "string",
+            "enum": [
+                "open",
+                "closed",
+                "maintenance"
+            ],
+            "x-enum-varnames": [
+                "StorehouseOpen",
+                "StorehouseClosed",
+                "StorehouseMaintenance"
+            ]
+        },
+        "domain.TransferPlan": {
+            "type": "object",
+            "required": [
+                "targetStorehouseID"
             ],
             "properties": {
-                "latitude": {
+                "targetStorehouseID": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.VehicleProfile": {
+            "type": "object",
+            "properties": {
+                "maxLengthMeters": {
+                    "description": "MaxLengthMeters is the length of the cargo bay, an item longer than it in any dimension is never shipped",
                     "type": "number"
                 },
-                "longitude": {
+                "maxVehicles": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "maxVolumeM3": {
+                    "type": "number"
+                },
+                "maxWeightKilograms": {
                     "type": "number"
                 }
             }
         },
-        "domain.Reservation": {
+        "handlers.ErrorDetailDTO": {
             "type": "object",
             "properties": {
-                "destinationLocation": {
-                    "$ref": "#/definitions/domain.Location"
+                "code": {
+                    "type": "string"
                 },
-                "entries": {
+                "field": {
+                    "description": "Field is set for validation errors and contains the path of the invalid field",
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ErrorResponseDTO": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "details": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/domain.ReserveEntry"
+                        "$ref": "#/definitions/handlers.ErrorDetailDTO"
                     }
                 },
-                "id": {
+                "message": {
                     "type": "string"
                 }
             }
         },
-        "domain.ReserveEntry": {
+        "ports.AvailabilityResponseDTO": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ports.ItemAvailabilityDTO"
+                    }
+                }
+            }
+        },
+        "ports.CreateStorehouseRequestDTO": {
             "type": "object",
             "required": [
-                "count",
-                "itemID"
+                "id",
+                "name"
             ],
             "properties": {
-                "count": {
-                    "type": "integer"
-                },
-                "itemID": {
+                "id": {
                     "type": "string"
                 },
-                "sourceStorehouseID": {
+                "location": {
+                    "$ref": "#/definitions/domain.Location"
+                },
+                "name": {
                     "type": "string"
+                },
+                "schedule": {
+                    "description": "Schedule is omitted if the storehouse works around the clock",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.Schedule"
+                        }
+                    ]
+                },
+                "vehicle": {
+                    "description": "Vehicle is omitted if shipments from the storehouse are not limited",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.VehicleProfile"
+                        }
+                    ]
                 }
             }
         },
-        "domain.ReserveRequest": {
+        "ports.DeactivateStorehouseRequestDTO": {
             "type": "object",
             "properties": {
-                "destinationLocation": {
-                    "$ref": "#/definitions/domain.Location"
-                },
-                "itemsToReserve": {
+                "transferPlan": {
+                    "description": "TransferPlan is required if the storehouse has open reservations",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.TransferPlan"
+                        }
+                    ]
+                }
+            }
+        },
+        "ports.DeactivationResponseDTO": {
+            "type": "object",
+            "properties": {
+                "movedReservationIDs": {
+                    "description": "MovedReservationIDs are open reservations which entries were moved to the target storehouse",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/domain.ReserveEntry"
+                        "type": "string"
                     }
+                },
+                "storehouse": {
+                    "$ref": "#/definitions/domain.StoreHouse"
                 }
             }
         },
-        "domain.Size": {
+        "ports.GetStockResponseDTO": {
             "type": "object",
             "properties": {
-                "heightMeters": {
-                    "type": "number"
-                },
-                "lengthMeters": {
-                    "type": "number"
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ItemData"
+                    }
                 },
-                "widthMeters": {
-                    "type": "number"
+                "storehouseID": {
+                    "type": "string"
                 }
             }
         },
@@ -242,16 +2148,88 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "items": {
+                    "description": "Items are ordered by item ID",
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/domain.ItemData"
                     }
                 },
+                "nextCursor": {
+                    "description": "NextCursor is passed to get the next page, empty on the last page",
+                    "type": "string"
+                },
                 "storehouseID": {
                     "type": "string"
                 }
             }
         },
+        "ports.ItemAvailabilityDTO": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "integer"
+                },
+                "itemID": {
+                    "type": "string"
+                },
+                "storehouses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ports.StorehouseAvailabilityDTO"
+                    }
+                }
+            }
+        },
+        "ports.ListReservationsResponseDTO": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "description": "NextCursor is empty on the last page",
+                    "type": "string"
+                },
+                "reservations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Reservation"
+                    }
+                }
+            }
+        },
+        "ports.QuoteResponseDTO": {
+            "type": "object",
+            "properties": {
+                "approximate": {
+                    "description": "Approximate is set if the strategy couldn't guarantee the best placement of the entries",
+                    "type": "boolean"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ReserveEntry"
+                    }
+                },
+                "explanation": {
+                    "description": "Explanation is filled only if it was requested",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.EntryExplanation"
+                    }
+                },
+                "gap": {
+                    "description": "Gap bounds how much cheaper the best placement may be than the approximate one",
+                    "type": "number"
+                },
+                "shipments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Shipment"
+                    }
+                },
+                "totalCost": {
+                    "type": "number"
+                }
+            }
+        },
         "ports.ReleaseRequestDTO": {
             "type": "object",
             "required": [
@@ -261,17 +2239,53 @@ const docTemplate = `{
                 "itemsToRelease": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/domain.ReserveEntry"
+                        "$ref": "#/definitions/domain.ReleaseEntry"
                     }
                 },
+                "reoptimize": {
+                    "description": "Reoptimize allows to move the rest of the reservation to other storehouses if it lowers the total cost",
+                    "type": "boolean"
+                },
                 "reservationID": {
                     "type": "string"
                 }
             }
         },
+        "ports.RelocateStorehouseRequestDTO": {
+            "type": "object",
+            "properties": {
+                "location": {
+                    "$ref": "#/definitions/domain.Location"
+                }
+            }
+        },
+        "ports.ReoptimizationDTO": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "boolean"
+                },
+                "newTotalCost": {
+                    "type": "number"
+                },
+                "oldTotalCost": {
+                    "type": "number"
+                }
+            }
+        },
         "ports.ReservationResponseDTO": {
             "type": "object",
             "properties": {
+                "explanation": {
+                    "description": "Explanation is filled only if it was requested",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.EntryExplanation"
+                    }
+                },
+                "reoptimization": {
+                    "$ref": "#/definitions/ports.ReoptimizationDTO"
+                },
                 "reservation": {
                     "$ref": "#/definitions/domain.Reservation"
                 },
@@ -279,6 +2293,145 @@ const docTemplate = `{
                     "type": "number"
                 }
             }
+        },
+        "ports.SearchItemsResponseDTO": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Item"
+                    }
+                },
+                "nextCursor": {
+                    "description": "NextCursor is passed to get the next page, empty on the last page",
+                    "type": "string"
+                }
+            }
+        },
+        "ports.StockAtResponseDTO": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.StockLevel"
+                    }
+                },
+                "storehouseID": {
+                    "type": "string"
+                }
+            }
+        },
+        "ports.StockMovementRequestDTO": {
+            "type": "object",
+            "required": [
+                "actor",
+                "itemID",
+                "quantity",
+                "reason",
+                "storehouseID"
+            ],
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "itemID": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "description": "Quantity must be positive for receipts and write-offs, a negative adjustment decreases the count",
+                    "type": "integer"
+                },
+                "reason": {
+                    "$ref": "#/definitions/domain.ReasonCode"
+                },
+                "storehouseID": {
+                    "type": "string"
+                }
+            }
+        },
+        "ports.StorehouseAvailabilityDTO": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "integer"
+                },
+                "storehouseID": {
+                    "type": "string"
+                }
+            }
+        },
+        "ports.StorehouseStatusRequestDTO": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "status": {
+                    "enum": [
+                        "open",
+                        "closed",
+                        "maintenance"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.StorehouseStatus"
+                        }
+                    ]
+                }
+            }
+        },
+        "ports.UpdateItemRequestDTO": {
+            "type": "object",
+            "required": [
+                "name",
+                "size"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "$ref": "#/definitions/domain.Size"
+                },
+                "weightKilograms": {
+                    "type": "number"
+                }
+            }
+        },
+        "ports.UpdateStorehouseRequestDTO": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "schedule": {
+                    "description": "Schedule replaces the schedule, omitted one means working around the clock",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.Schedule"
+                        }
+                    ]
+                },
+                "vehicle": {
+                    "description": "Vehicle replaces the vehicle profile, omitted one removes the limits",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.VehicleProfile"
+                        }
+                    ]
+                }
+            }
         }
     }
 }`
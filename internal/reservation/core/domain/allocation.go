@@ -0,0 +1,507 @@
+package domain
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// AllocationInput is everything an allocation strategy may use to place entries
+// which have no source storehouse
+type AllocationInput struct {
+	Destination Location
+	Entries     []ReserveEntry
+	// Storehouses contain items left after manually placed entries were taken
+	Storehouses map[StoreHouseID]StoreHouse
+	Items       map[ItemID]Item
+	Costs       CostModel
+	// OpenedStorehouses are used by manually placed entries, so their fixed cost is already paid.
+	// The entries share vehicles with the allocated ones
+	OpenedStorehouses map[StoreHouseID][]ReserveEntry
+}
+
+// Allocation is the result of a strategy: entries with filled source storehouse.
+// Approximate is set if the strategy couldn't guarantee the best result by its own criteria.
+// Then the best allocation costs at least the cost of the entries minus Gap
+type Allocation struct {
+	Entries     []ReserveEntry
+	Approximate bool
+	Gap         float64
+}
+
+// AllocateFunc places entries of the input
+type AllocateFunc func(input AllocationInput) (Allocation, error)
+
+type itemDemand struct {
+	itemID ItemID
+	count  int
+}
+
+// allocationProblem keeps the precomputed data of one allocation.
+// Candidates are storehouses that can ship at least one of demanded items, sorted by distance.
+// Demands contain only items that can be satisfied by the whole network;
+// all per-demand slices are indexed by candidate index.
+type allocationProblem struct {
+	allDemands []itemDemand
+	itemRanks  map[ItemID][]int
+	infeasible map[ItemID][]int
+	items      map[ItemID]Item
+
+	demands           []itemDemand
+	candidates        []StoreHouse
+	opened            []bool
+	preloaded         [][]ReserveEntry
+	preloadedVehicles []int
+	fixedCosts        []float64
+	stock             [][]int
+	unitCosts         [][]float64
+	ranks             [][]int
+}
+
+func newAllocationProblem(input AllocationInput) (problem *allocationProblem, resultErr error) {
+	problem = &allocationProblem{
+		allDemands: aggregateDemands(input.Entries),
+		itemRanks:  make(map[ItemID][]int),
+		infeasible: make(map[ItemID][]int),
+		items:      input.Items,
+	}
+
+	for _, storehouse := range sortStorehousesByDistance(input.Storehouses, input.Destination, input.Costs) {
+		if !problem.hasAnyDemandedItem(storehouse) {
+			continue
+		}
+
+		preloaded, opened := input.OpenedStorehouses[storehouse.ID]
+		problem.candidates = append(problem.candidates, storehouse)
+		problem.opened = append(problem.opened, opened)
+		problem.preloaded = append(problem.preloaded, preloaded)
+		problem.preloadedVehicles = append(problem.preloadedVehicles, storehouse.Vehicle.CountVehicles(preloaded, input.Items))
+		problem.fixedCosts = append(problem.fixedCosts, input.Costs.StorehouseCost(storehouse, input.Destination))
+	}
+
+	// infeasible demands can't be satisfied at all, so they are excluded from the search
+	// and take everything that is left in the network
+	for _, demand := range problem.allDemands {
+		stock, available := problem.stockOf(demand.itemID)
+		unitCosts := make([]float64, len(problem.candidates))
+
+		item, itemKnown := input.Items[demand.itemID]
+		if itemKnown {
+			for i, storehouse := range problem.candidates {
+				unitCosts[i] = input.Costs.UnitCost(storehouse, input.Destination, item)
+			}
+		}
+
+		ranks := rankByUnitCost(unitCosts)
+		problem.itemRanks[demand.itemID] = ranks
+
+		if available < demand.count {
+			resultErr = errors.Join(resultErr,
+				fmt.Errorf("%w, item: %s", ErrNotEnoughItemsInAllStorehouses, demand.itemID))
+			problem.infeasible[demand.itemID] = takeByRank(stock, ranks, nil, demand.count)
+			continue
+		}
+
+		if !itemKnown {
+			resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s", ErrUnknownItem, demand.itemID))
+			continue
+		}
+
+		problem.demands = append(problem.demands, demand)
+		problem.stock = append(problem.stock, stock)
+		problem.unitCosts = append(problem.unitCosts, unitCosts)
+		problem.ranks = append(problem.ranks, ranks)
+	}
+
+	return problem, resultErr
+}
+
+// allocationScore is compared lexicographically when storehouses count matters, otherwise only by cost.
+// split is set if the plan needs more vehicles than one per storehouse or than the manually placed entries
+// already use, or if the storehouses have not enough vehicles for it
+type allocationScore struct {
+	storehouses int
+	cost        float64
+	split       bool
+}
+
+func (score allocationScore) isBetter(than allocationScore, countStorehousesFirst bool) bool {
+	if countStorehousesFirst && score.storehouses != than.storehouses {
+		return score.storehouses < than.storehouses
+	}
+
+	return score.cost < than.cost
+}
+
+// search finds the best plan over sets of storehouses.
+//
+// When the set of used storehouses is fixed and every shipment fits one vehicle, the problem splits by items:
+// each unit is taken from the allowed storehouse with the lowest per unit cost. So only sets of storehouses
+// are searched, using branch and bound: a branch is cut when even the relaxation that allows all undecided
+// storehouses without paying their fixed cost is not better than the best plan found so far.
+//
+// The result may be not the best one when the search visits more than nodeBudget sets, then the better
+// of the best plan found so far and the greedy plan is taken, or when a visited plan splits a shipment
+// between vehicles: extra vehicles are paid, but units are still assigned by unit cost, so a plan avoiding
+// an extra vehicle by taking more expensive units may be missed. Bounds of the sets left unexplored
+// and of the split plans still hold, so the returned gap proves how much cheaper the best plan may be.
+// The gap is zero when the result is exact.
+//
+// Opened storehouses are always allowed for free.
+func (problem *allocationProblem) search(countStorehousesFirst bool, nodeBudget int) (plan [][]int, gap float64, err error) {
+	searcher := branchAndBound{
+		problem:               problem,
+		countStorehousesFirst: countStorehousesFirst,
+		nodesLeft:             nodeBudget,
+		lowerBounds:           make(map[int]float64),
+		best:                  allocationScore{storehouses: math.MaxInt, cost: math.Inf(1)},
+	}
+
+	searcher.visit(0, slices.Clone(problem.opened))
+
+	if searcher.nodesLeft < 0 {
+		greedyPlan, greedyScore, feasible := problem.greedy(countStorehousesFirst)
+		if feasible && (searcher.bestPlan == nil || greedyScore.isBetter(searcher.best, countStorehousesFirst)) {
+			searcher.best = greedyScore
+			searcher.bestPlan = greedyPlan
+		}
+	}
+
+	// all demands are satisfied by the network, so only vehicles of storehouses can make every plan infeasible
+	if searcher.bestPlan == nil {
+		return nil, 0, ErrNotEnoughVehicles
+	}
+
+	return searcher.bestPlan, searcher.gap(), nil
+}
+
+type branchAndBound struct {
+	problem               *allocationProblem
+	countStorehousesFirst bool
+	// nodesLeft becomes negative when the budget is exhausted
+	nodesLeft int
+	// lowerBounds keep the lowest bound on the cost of plans which may be better than the found ones,
+	// per number of storehouses
+	lowerBounds map[int]float64
+
+	best     allocationScore
+	bestPlan [][]int
+}
+
+func (searcher *branchAndBound) visit(next int, allowed []bool) {
+	problem := searcher.problem
+
+	searcher.nodesLeft--
+
+	// relaxation: every undecided storehouse is allowed, but its fixed cost is not paid
+	relaxed := slices.Clone(allowed)
+	for i := next; i < len(relaxed); i++ {
+		relaxed[i] = true
+	}
+
+	plan, variableCost, feasible := problem.assign(relaxed)
+	if !feasible {
+		return
+	}
+
+	lowerBound, _ := problem.score(allowed, nil, variableCost)
+	if !lowerBound.isBetter(searcher.best, searcher.countStorehousesFirst) {
+		return
+	}
+
+	// the budget is exhausted, so the sets of the branch are left unexplored
+	if searcher.nodesLeft < 0 {
+		searcher.addLowerBound(lowerBound)
+		return
+	}
+
+	if next == len(allowed) {
+		// storehouses that were allowed but not used do not pay the fixed cost, extra vehicles do
+		score, feasible := problem.score(allowed, plan, variableCost)
+		if score.split {
+			// another assignment may need fewer vehicles, so only the bound of the set is known
+			searcher.addLowerBound(lowerBound)
+		}
+		if feasible && score.isBetter(searcher.best, searcher.countStorehousesFirst) {
+			searcher.best = score
+			searcher.bestPlan = plan
+		}
+		return
+	}
+
+	if problem.opened[next] {
+		searcher.visit(next+1, allowed)
+		return
+	}
+
+	allowed[next] = true
+	searcher.visit(next+1, allowed)
+
+	allowed[next] = false
+	searcher.visit(next+1, allowed)
+}
+
+func (searcher *branchAndBound) addLowerBound(bound allocationScore) {
+	if cost, ok := searcher.lowerBounds[bound.storehouses]; !ok || bound.cost < cost {
+		searcher.lowerBounds[bound.storehouses] = bound.cost
+	}
+}
+
+// gap is how much cheaper than the best found plan a better plan may be. Bounds of plans with more storehouses
+// don't count when storehouses are counted first: such plans can't be better.
+// Differences of rounding errors are not reported
+func (searcher *branchAndBound) gap() float64 {
+	bound := searcher.best.cost
+	for storehouses, cost := range searcher.lowerBounds {
+		if searcher.countStorehousesFirst && storehouses > searcher.best.storehouses {
+			continue
+		}
+
+		bound = min(bound, cost)
+	}
+
+	gap := searcher.best.cost - bound
+	if gap <= 1e-9*max(math.Abs(searcher.best.cost), 1) {
+		return 0
+	}
+
+	return gap
+}
+
+// greedy starts with all storehouses allowed and keeps forbidding the used storehouse which improves
+// the plan the most, till no storehouse does. It takes a polynomial number of assignments
+func (problem *allocationProblem) greedy(countStorehousesFirst bool) (bestPlan [][]int, best allocationScore, feasible bool) {
+	allowed := make([]bool, len(problem.candidates))
+	for i := range allowed {
+		allowed[i] = true
+	}
+
+	plan, variableCost, _ := problem.assign(allowed)
+	best, feasible = problem.score(allowed, plan, variableCost)
+	bestPlan = plan
+
+	for {
+		forbidden := -1
+		for candidate := range allowed {
+			if !allowed[candidate] || problem.opened[candidate] || !isUsed(bestPlan, candidate) {
+				continue
+			}
+
+			allowed[candidate] = false
+			plan, variableCost, assigned := problem.assign(allowed)
+			if assigned {
+				score, scoreFeasible := problem.score(allowed, plan, variableCost)
+				if scoreFeasible && (!feasible || score.isBetter(best, countStorehousesFirst)) {
+					forbidden, bestPlan, best, feasible = candidate, plan, score, true
+				}
+			}
+			allowed[candidate] = true
+		}
+
+		if forbidden < 0 {
+			return bestPlan, best, feasible
+		}
+
+		allowed[forbidden] = false
+	}
+}
+
+// assign takes every demanded unit from the cheapest allowed storehouse
+func (problem *allocationProblem) assign(allowed []bool) (plan [][]int, variableCost float64, feasible bool) {
+	plan = make([][]int, len(problem.demands))
+	for i, demand := range problem.demands {
+		plan[i] = takeByRank(problem.stock[i], problem.ranks[i], allowed, demand.count)
+
+		taken := 0
+		for candidate, count := range plan[i] {
+			taken += count
+			variableCost += problem.unitCosts[i][candidate] * float64(count)
+		}
+
+		if taken < demand.count {
+			return nil, 0, false
+		}
+	}
+
+	return plan, variableCost, true
+}
+
+// score counts allowed storehouses which are not opened yet and adds the fixed cost of each of them.
+// If plan is provided, only storehouses used by the plan are counted and the fixed cost is paid
+// for every vehicle that is not paid by manually placed entries yet. The plan is not feasible
+// if a storehouse has not enough vehicles for it
+func (problem *allocationProblem) score(allowed []bool, plan [][]int, variableCost float64) (allocationScore, bool) {
+	score := allocationScore{cost: variableCost}
+	for candidate, isAllowed := range allowed {
+		if !isAllowed {
+			continue
+		}
+
+		if plan == nil {
+			if !problem.opened[candidate] {
+				score.storehouses++
+				score.cost += problem.fixedCosts[candidate]
+			}
+			continue
+		}
+
+		if !isUsed(plan, candidate) {
+			continue
+		}
+
+		vehicle := problem.candidates[candidate].Vehicle
+		vehicles := vehicle.CountVehicles(problem.shipment(plan, candidate), problem.items)
+		if !vehicle.hasEnoughVehicles(vehicles) {
+			return allocationScore{split: true}, false
+		}
+
+		score.split = score.split || vehicles > max(problem.preloadedVehicles[candidate], 1)
+		if !problem.opened[candidate] {
+			score.storehouses++
+		}
+		score.cost += problem.fixedCosts[candidate] * float64(vehicles-problem.preloadedVehicles[candidate])
+	}
+
+	return score, true
+}
+
+// shipment returns manually placed and planned entries of the candidate
+func (problem *allocationProblem) shipment(plan [][]int, candidate int) []ReserveEntry {
+	entries := slices.Clone(problem.preloaded[candidate])
+	for i, demand := range problem.demands {
+		if plan[i][candidate] > 0 {
+			entries = append(entries, ReserveEntry{ItemID: demand.itemID, Count: plan[i][candidate]})
+		}
+	}
+
+	return entries
+}
+
+// entries converts the plan to reserve entries in the order of demands.
+// Entries of the same item are listed starting from the storehouse with the lowest unit cost
+func (problem *allocationProblem) entries(plan [][]int) []ReserveEntry {
+	planned := make(map[ItemID][]int, len(problem.demands))
+	if plan != nil {
+		for i, demand := range problem.demands {
+			planned[demand.itemID] = plan[i]
+		}
+	}
+
+	entries := make([]ReserveEntry, 0)
+	for _, demand := range problem.allDemands {
+		counts, ok := planned[demand.itemID]
+		if !ok {
+			counts, ok = problem.infeasible[demand.itemID]
+		}
+		if !ok {
+			continue
+		}
+
+		for _, candidate := range problem.itemRanks[demand.itemID] {
+			if counts[candidate] == 0 {
+				continue
+			}
+
+			entries = append(entries, ReserveEntry{
+				ItemID:             demand.itemID,
+				Count:              counts[candidate],
+				SourceStorehouseID: problem.candidates[candidate].ID,
+			})
+		}
+	}
+
+	return entries
+}
+
+func (problem *allocationProblem) stockOf(itemID ItemID) (stock []int, total int) {
+	stock = make([]int, len(problem.candidates))
+	for i, storehouse := range problem.candidates {
+		if problem.canShip(storehouse, itemID) {
+			stock[i] = storehouse.ItemsData[itemID].Available
+			total += stock[i]
+		}
+	}
+
+	return stock, total
+}
+
+// canShip reports whether the storehouse has available units of the item and its vehicle can carry them
+func (problem *allocationProblem) canShip(storehouse StoreHouse, itemID ItemID) bool {
+	itemData, ok := storehouse.ItemsData[itemID]
+
+	return ok && itemData.Available > 0 && storehouse.Vehicle.CanCarry(problem.items[itemID])
+}
+
+// takeByRank takes up to count units from the stock in the given order.
+// If allowed is nil, all storehouses are allowed
+func takeByRank(stock []int, ranks []int, allowed []bool, count int) []int {
+	taken := make([]int, len(stock))
+	for _, candidate := range ranks {
+		if count == 0 {
+			break
+		}
+
+		if allowed != nil && !allowed[candidate] {
+			continue
+		}
+
+		taken[candidate] = min(stock[candidate], count)
+		count -= taken[candidate]
+	}
+
+	return taken
+}
+
+// rankByUnitCost returns candidate indices sorted by unit cost.
+// Candidates are sorted by distance, so equal costs keep the nearest storehouse first
+func rankByUnitCost(unitCosts []float64) []int {
+	ranks := make([]int, len(unitCosts))
+	for i := range ranks {
+		ranks[i] = i
+	}
+
+	slices.SortStableFunc(ranks, func(a, b int) int {
+		return cmp.Compare(unitCosts[a], unitCosts[b])
+	})
+
+	return ranks
+}
+
+func isUsed(plan [][]int, candidate int) bool {
+	for _, counts := range plan {
+		if counts[candidate] > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (problem *allocationProblem) hasAnyDemandedItem(storehouse StoreHouse) bool {
+	for _, demand := range problem.allDemands {
+		if problem.canShip(storehouse, demand.itemID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aggregateDemands sums counts of entries with the same item keeping the order of first appearance
+func aggregateDemands(entries []ReserveEntry) []itemDemand {
+	demands := make([]itemDemand, 0, len(entries))
+	indexes := make(map[ItemID]int, len(entries))
+	for _, entry := range entries {
+		if i, ok := indexes[entry.ItemID]; ok {
+			demands[i].count += entry.Count
+			continue
+		}
+
+		indexes[entry.ItemID] = len(demands)
+		demands = append(demands, itemDemand{itemID: entry.ItemID, count: entry.Count})
+	}
+
+	return demands
+}
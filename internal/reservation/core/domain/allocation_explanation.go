@@ -0,0 +1,266 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+)
+
+// CandidateReason tells why the storehouse was picked for the entry or rejected
+type CandidateReason string
+
+const (
+	// ManuallySelectedCandidate is the storehouse given in the request
+	ManuallySelectedCandidate CandidateReason = "manually-selected"
+	PickedCandidate           CandidateReason = "picked"
+	// DemandSatisfiedCandidate comes after all picked storehouses in the order of the strategy,
+	// so the demand was satisfied before it was reached
+	DemandSatisfiedCandidate CandidateReason = "demand-satisfied"
+	// HigherTotalCostCandidate is cheaper per unit than one of picked storehouses, but using it makes
+	// the whole reservation more expensive or uses more storehouses, e.g. because of the fixed cost of one more storehouse
+	HigherTotalCostCandidate CandidateReason = "higher-total-cost"
+	// IncompleteStockCandidate can't give all items of the reservation with the single-storehouse strategy
+	IncompleteStockCandidate CandidateReason = "incomplete-stock"
+	DeactivatedCandidate     CandidateReason = "storehouse-deactivated"
+	// NotOpenCandidate is closed or under maintenance
+	NotOpenCandidate CandidateReason = "storehouse-not-open"
+	// OutsideDispatchWindowCandidate doesn't work within the dispatch window of the request
+	OutsideDispatchWindowCandidate CandidateReason = "outside-dispatch-window"
+	// VehicleTooSmallCandidate has the item, but its vehicle can't carry even one unit of it
+	VehicleTooSmallCandidate CandidateReason = "vehicle-too-small"
+)
+
+// CandidateExplanation describes one storehouse considered for the entry
+type CandidateExplanation struct {
+	StorehouseID StoreHouseID `json:"storehouseID"`
+	// DistanceRank and CostRank start from 1, they are zero for storehouses the allocator didn't consider
+	DistanceRank int     `json:"distanceRank"`
+	CostRank     int     `json:"costRank"`
+	DistanceKm   float64 `json:"distanceKm"`
+	UnitCost     float64 `json:"unitCost"`
+	// Available is the number of available units at the moment of the decision
+	Available int             `json:"available"`
+	Taken     int             `json:"taken"`
+	Reason    CandidateReason `json:"reason"`
+}
+
+// EntryExplanation describes how the entry was placed. Entries of the same item without storehouse
+// are allocated together, so they are explained together
+type EntryExplanation struct {
+	ItemID             ItemID       `json:"itemID"`
+	Count              int          `json:"count"`
+	SourceStorehouseID StoreHouseID `json:"sourceStorehouseID,omitempty"`
+	// Candidates are ordered by cost rank, storehouses the allocator didn't consider go last
+	Candidates []CandidateExplanation `json:"candidates"`
+}
+
+// ExplainReservation rebuilds the decisions made for the reservation by NewReservationFromReserveRequest.
+// Storehouses must be the same as the ones the reservation was built from
+func ExplainReservation(request ReserveRequest, reservation Reservation,
+	storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item, costs CostModel) []EntryExplanation {
+
+	explanations := make([]EntryExplanation, 0, len(request.ItemsToReserve))
+
+	// manual entries are taken first in the order of the request, the rest is allocated from what is left
+	left := CloneStorehouses(storehouses)
+	manuallyTaken := make(map[StoreHouseID]map[ItemID]int)
+	openedStorehouses := make(map[StoreHouseID][]ReserveEntry)
+	var autoEntries []ReserveEntry
+	for _, entry := range request.ItemsToReserve {
+		if entry.SourceStorehouseID.IsEmpty() {
+			autoEntries = append(autoEntries, entry)
+			continue
+		}
+
+		storehouse := left[entry.SourceStorehouseID]
+		itemData := storehouse.ItemsData[entry.ItemID]
+
+		candidate := CandidateExplanation{
+			StorehouseID: storehouse.ID,
+			DistanceKm:   costs.DistanceKm(storehouse, request.DestinationLocation),
+			Available:    itemData.Available,
+			Taken:        entry.Count,
+			Reason:       ManuallySelectedCandidate,
+		}
+		if item, ok := items[entry.ItemID]; ok {
+			candidate.UnitCost = costs.UnitCost(storehouse, request.DestinationLocation, item)
+		}
+
+		explanations = append(explanations, EntryExplanation{
+			ItemID:             entry.ItemID,
+			Count:              entry.Count,
+			SourceStorehouseID: entry.SourceStorehouseID,
+			Candidates:         []CandidateExplanation{candidate},
+		})
+
+		if storehouse.ItemsData != nil {
+			itemData.Available -= entry.Count
+			storehouse.ItemsData[entry.ItemID] = itemData
+		}
+		if manuallyTaken[entry.SourceStorehouseID] == nil {
+			manuallyTaken[entry.SourceStorehouseID] = make(map[ItemID]int)
+		}
+		manuallyTaken[entry.SourceStorehouseID][entry.ItemID] += entry.Count
+		openedStorehouses[entry.SourceStorehouseID] = append(openedStorehouses[entry.SourceStorehouseID], entry)
+	}
+
+	if len(autoEntries) == 0 {
+		return explanations
+	}
+
+	problem, _ := newAllocationProblem(AllocationInput{
+		Destination:       request.DestinationLocation,
+		Entries:           autoEntries,
+		Storehouses:       dispatchingStorehouses(left, request.DispatchWindow),
+		Items:             items,
+		Costs:             costs,
+		OpenedStorehouses: openedStorehouses,
+	})
+
+	taken := make(map[StoreHouseID]map[ItemID]int)
+	for _, entry := range reservation.Entries {
+		if taken[entry.SourceStorehouseID] == nil {
+			taken[entry.SourceStorehouseID] = make(map[ItemID]int)
+		}
+		taken[entry.SourceStorehouseID][entry.ItemID] += entry.Count
+	}
+
+	for _, demand := range problem.allDemands {
+		explanation := EntryExplanation{ItemID: demand.itemID, Count: demand.count}
+
+		for costRank, candidateIndex := range problem.rankedCandidatesOf(demand.itemID) {
+			storehouse := problem.candidates[candidateIndex]
+			candidate := CandidateExplanation{
+				StorehouseID: storehouse.ID,
+				DistanceRank: problem.distanceRankOf(demand.itemID, candidateIndex) + 1,
+				CostRank:     costRank + 1,
+				DistanceKm:   costs.DistanceKm(storehouse, request.DestinationLocation),
+				Available:    storehouse.ItemsData[demand.itemID].Available,
+				Taken:        taken[storehouse.ID][demand.itemID] - manuallyTaken[storehouse.ID][demand.itemID],
+			}
+			if item, ok := items[demand.itemID]; ok {
+				candidate.UnitCost = costs.UnitCost(storehouse, request.DestinationLocation, item)
+			}
+
+			explanation.Candidates = append(explanation.Candidates, candidate)
+		}
+
+		setRejectionReasons(explanation.Candidates, request.Strategy, problem)
+
+		for _, storehouse := range sortStorehousesByDistance(left, request.DestinationLocation, costs) {
+			itemData := storehouse.ItemsData[demand.itemID]
+			if itemData.Available <= 0 {
+				continue
+			}
+
+			reason, rejected := rejectionReasonOf(storehouse, request.DispatchWindow, items[demand.itemID])
+			if !rejected {
+				continue
+			}
+
+			explanation.Candidates = append(explanation.Candidates, CandidateExplanation{
+				StorehouseID: storehouse.ID,
+				DistanceKm:   costs.DistanceKm(storehouse, request.DestinationLocation),
+				Available:    itemData.Available,
+				Reason:       reason,
+			})
+		}
+
+		explanations = append(explanations, explanation)
+	}
+
+	return explanations
+}
+
+// rejectionReasonOf tells why the allocator didn't consider the storehouse having the item
+func rejectionReasonOf(storehouse StoreHouse, window *DispatchWindow, item Item) (CandidateReason, bool) {
+	err := storehouse.CheckDispatch(window)
+	switch {
+	case errors.Is(err, ErrStorehouseDeactivated):
+		return DeactivatedCandidate, true
+	case errors.Is(err, ErrStorehouseNotOpen):
+		return NotOpenCandidate, true
+	case errors.Is(err, ErrStorehouseCannotDispatch):
+		return OutsideDispatchWindowCandidate, true
+	case !storehouse.Vehicle.CanCarry(item):
+		return VehicleTooSmallCandidate, true
+	default:
+		return "", false
+	}
+}
+
+// setRejectionReasons sets reasons of candidates ranked by cost. A candidate that wasn't used is compared
+// with the worst picked candidate in the order the strategy prefers storehouses: by distance for nearest-first,
+// by cost otherwise
+func setRejectionReasons(candidates []CandidateExplanation, strategy AllocationStrategyName, problem *allocationProblem) {
+	preference := func(candidate CandidateExplanation) int {
+		if strategy == NearestFirst {
+			return candidate.DistanceRank
+		}
+
+		return candidate.CostRank
+	}
+
+	worstPicked := 0
+	for _, candidate := range candidates {
+		if candidate.Taken > 0 {
+			worstPicked = max(worstPicked, preference(candidate))
+		}
+	}
+
+	for i, candidate := range candidates {
+		switch {
+		case candidate.Taken > 0:
+			candidates[i].Reason = PickedCandidate
+		case strategy == SingleStorehouse && !problem.hasAllDemands(candidate.StorehouseID):
+			candidates[i].Reason = IncompleteStockCandidate
+		case preference(candidate) > worstPicked:
+			candidates[i].Reason = DemandSatisfiedCandidate
+		default:
+			candidates[i].Reason = HigherTotalCostCandidate
+		}
+	}
+}
+
+// rankedCandidatesOf returns indices of candidates having the item in the order of the allocator's unit cost ranking
+func (problem *allocationProblem) rankedCandidatesOf(itemID ItemID) []int {
+	ranked := make([]int, 0)
+	for _, candidate := range problem.itemRanks[itemID] {
+		if problem.canShip(problem.candidates[candidate], itemID) {
+			ranked = append(ranked, candidate)
+		}
+	}
+
+	return ranked
+}
+
+// distanceRankOf returns the position of the candidate among candidates having the item,
+// candidates are already sorted by distance
+func (problem *allocationProblem) distanceRankOf(itemID ItemID, candidate int) int {
+	rank := 0
+	for i := 0; i < candidate; i++ {
+		if problem.canShip(problem.candidates[i], itemID) {
+			rank++
+		}
+	}
+
+	return rank
+}
+
+// hasAllDemands reports whether the storehouse alone can ship enough units of every demanded item
+func (problem *allocationProblem) hasAllDemands(storehouseID StoreHouseID) bool {
+	index := slices.IndexFunc(problem.candidates, func(storehouse StoreHouse) bool {
+		return storehouse.ID == storehouseID
+	})
+	if index < 0 {
+		return false
+	}
+
+	for _, demand := range problem.allDemands {
+		candidate := problem.candidates[index]
+		if !problem.canShip(candidate, demand.itemID) || candidate.ItemsData[demand.itemID].Available < demand.count {
+			return false
+		}
+	}
+
+	return true
+}
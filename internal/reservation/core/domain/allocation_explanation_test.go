@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainReservation(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+	deactivated := storehouses["c"]
+	deactivated.ID = "d"
+	deactivated.Deactivated = true
+	storehouses["d"] = deactivated
+
+	request := ReserveRequest{
+		DestinationLocation: Location{50, 50},
+		ItemsToReserve:      []ReserveEntry{{ItemID: "2", Count: 1, SourceStorehouseID: "c"}, {ItemID: "1", Count: 5}},
+	}
+
+	reservation, err := NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	explanations := ExplainReservation(request, reservation, storehouses, items, DefaultCostModel())
+	if !assert.Len(t, explanations, 2) {
+		t.FailNow()
+	}
+
+	manual := explanations[0]
+	if assert.Len(t, manual.Candidates, 1) {
+		assert.Equal(t, ManuallySelectedCandidate, manual.Candidates[0].Reason)
+		assert.Equal(t, 1, manual.Candidates[0].Available)
+	}
+
+	// "c" is already opened by the manual entry, so paying k2 for "b" is not worth it, while "a" saves more than k2
+	reasons := make(map[StoreHouseID]CandidateReason)
+	for _, candidate := range explanations[1].Candidates {
+		reasons[candidate.StorehouseID] = candidate.Reason
+	}
+	assert.Equal(t, map[StoreHouseID]CandidateReason{
+		"a": PickedCandidate,
+		"b": HigherTotalCostCandidate,
+		"c": PickedCandidate,
+		"d": DeactivatedCandidate,
+	}, reasons)
+	assert.Equal(t, StoreHouseID("d"), explanations[1].Candidates[3].StorehouseID)
+}
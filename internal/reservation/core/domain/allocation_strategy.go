@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownAllocationStrategy        = errors.New("unknown allocation strategy")
+	ErrNotEnoughItemsInSingleStorehouse = errors.New("no single storehouse has all items")
+	// ErrManualEntriesInSeveralStorehouses is returned by SingleStorehouseStrategy, because such a request
+	// can't be shipped from one storehouse
+	ErrManualEntriesInSeveralStorehouses = errors.New("manually placed entries use several storehouses")
+)
+
+type AllocationStrategyName string
+
+const (
+	NearestFirst      AllocationStrategyName = "nearest-first"
+	FewestStorehouses AllocationStrategyName = "fewest-storehouses"
+	CostOptimal       AllocationStrategyName = "cost-optimal"
+	SingleStorehouse  AllocationStrategyName = "single-storehouse"
+
+	DefaultAllocationStrategy = CostOptimal
+
+	// DefaultNodeBudget bounds the search of CostOptimalStrategy and FewestStorehousesStrategy,
+	// it takes about 100 ms for 24 storehouses and 30 items
+	DefaultNodeBudget = 50_000
+)
+
+// NearestFirstStrategy takes all required items of each entry from the nearest storehouse
+// till either it's enough items or no storehouses left. Storehouses which vehicle can't carry the item are skipped.
+// There are no guarantees about the cost and the number of vehicles
+type NearestFirstStrategy struct{}
+
+func (NearestFirstStrategy) Allocate(input AllocationInput) (Allocation, error) {
+	var distributed []ReserveEntry
+	var err error
+	sortedStorehouses := sortStorehousesByDistance(CloneStorehouses(input.Storehouses), input.Destination, input.Costs)
+
+	for _, entry := range input.Entries {
+		for i, storehouse := range sortedStorehouses {
+			itemData, ok := storehouse.ItemsData[entry.ItemID]
+			if ok && itemData.Available > 0 && storehouse.Vehicle.CanCarry(input.Items[entry.ItemID]) {
+				taken := min(itemData.Available, entry.Count)
+
+				distributed = append(distributed, ReserveEntry{
+					ItemID:             entry.ItemID,
+					Count:              taken,
+					SourceStorehouseID: storehouse.ID,
+				})
+
+				entry.Count -= taken
+				itemData.Available -= taken
+				sortedStorehouses[i].ItemsData[entry.ItemID] = itemData
+			}
+
+			if entry.Count == 0 {
+				break
+			}
+		}
+
+		if entry.Count > 0 {
+			err = errors.Join(err,
+				fmt.Errorf("%w, item: %s", ErrNotEnoughItemsInAllStorehouses, entry.ItemID))
+		}
+	}
+
+	return Allocation{Entries: distributed}, err
+}
+
+// CostOptimalStrategy finds the distribution with the minimal transport cost of the whole reservation.
+// NodeBudget limits the number of sets of storehouses the search visits, zero means DefaultNodeBudget
+type CostOptimalStrategy struct {
+	NodeBudget int
+}
+
+func (strategy CostOptimalStrategy) Allocate(input AllocationInput) (Allocation, error) {
+	problem, err := newAllocationProblem(input)
+	plan, gap, searchErr := problem.search(false, nodeBudgetOrDefault(strategy.NodeBudget))
+
+	return Allocation{Entries: problem.entries(plan), Approximate: gap > 0, Gap: gap}, errors.Join(err, searchErr)
+}
+
+// FewestStorehousesStrategy uses as few new storehouses as possible.
+// Among distributions with the same number of storehouses the cheapest one is chosen.
+// NodeBudget limits the search as in CostOptimalStrategy
+type FewestStorehousesStrategy struct {
+	NodeBudget int
+}
+
+func (strategy FewestStorehousesStrategy) Allocate(input AllocationInput) (Allocation, error) {
+	problem, err := newAllocationProblem(input)
+	plan, gap, searchErr := problem.search(true, nodeBudgetOrDefault(strategy.NodeBudget))
+
+	return Allocation{Entries: problem.entries(plan), Approximate: gap > 0, Gap: gap}, errors.Join(err, searchErr)
+}
+
+func nodeBudgetOrDefault(nodeBudget int) int {
+	if nodeBudget <= 0 {
+		return DefaultNodeBudget
+	}
+
+	return nodeBudget
+}
+
+// SingleStorehouseStrategy takes all entries from one storehouse, choosing the cheapest one.
+// If some entries are placed manually, the rest is taken from their storehouse
+type SingleStorehouseStrategy struct{}
+
+func (SingleStorehouseStrategy) Allocate(input AllocationInput) (Allocation, error) {
+	if len(input.OpenedStorehouses) > 1 {
+		return Allocation{}, fmt.Errorf("%w: %d storehouses", ErrManualEntriesInSeveralStorehouses, len(input.OpenedStorehouses))
+	}
+
+	problem, err := newAllocationProblem(input)
+	if len(problem.demands) == 0 {
+		return Allocation{Entries: problem.entries(nil)}, err
+	}
+
+	var bestPlan [][]int
+	var bestCost float64
+	for candidate := range problem.candidates {
+		if len(input.OpenedStorehouses) > 0 && !problem.opened[candidate] {
+			continue
+		}
+
+		allowed := make([]bool, len(problem.candidates))
+		allowed[candidate] = true
+
+		plan, variableCost, feasible := problem.assign(allowed)
+		if !feasible {
+			continue
+		}
+
+		score, feasible := problem.score(allowed, plan, variableCost)
+		if !feasible {
+			continue
+		}
+
+		if bestPlan == nil || score.cost < bestCost {
+			bestPlan = plan
+			bestCost = score.cost
+		}
+	}
+
+	if bestPlan == nil {
+		err = errors.Join(err, ErrNotEnoughItemsInSingleStorehouse)
+	}
+
+	return Allocation{Entries: problem.entries(bestPlan)}, err
+}
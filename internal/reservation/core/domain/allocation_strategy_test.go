@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocationStrategies(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+
+	testCases := []struct {
+		name            string
+		allocate        AllocateFunc
+		count           int
+		expectedEntries []ReserveEntry
+		expectedErr     error
+	}{
+		{
+			name:     "nearest first",
+			allocate: NearestFirstStrategy{}.Allocate,
+			count:    10,
+			expectedEntries: []ReserveEntry{
+				{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+				{ItemID: "1", Count: 3, SourceStorehouseID: "b"},
+				{ItemID: "1", Count: 3, SourceStorehouseID: "c"},
+			},
+		},
+		{
+			name:     "cost optimal",
+			allocate: CostOptimalStrategy{}.Allocate,
+			count:    10,
+			expectedEntries: []ReserveEntry{
+				{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+				{ItemID: "1", Count: 6, SourceStorehouseID: "c"},
+			},
+		},
+		{
+			name:     "fewest storehouses",
+			allocate: FewestStorehousesStrategy{}.Allocate,
+			count:    10,
+			expectedEntries: []ReserveEntry{
+				{ItemID: "1", Count: 10, SourceStorehouseID: "c"},
+			},
+		},
+		{
+			name:     "fewest storehouses, several needed",
+			allocate: FewestStorehousesStrategy{}.Allocate,
+			count:    13,
+			expectedEntries: []ReserveEntry{
+				{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+				{ItemID: "1", Count: 9, SourceStorehouseID: "c"},
+			},
+		},
+		{
+			name:     "single storehouse",
+			allocate: SingleStorehouseStrategy{}.Allocate,
+			count:    4,
+			expectedEntries: []ReserveEntry{
+				{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+			},
+		},
+		{
+			name:            "single storehouse, not enough",
+			allocate:        SingleStorehouseStrategy{}.Allocate,
+			count:           11,
+			expectedEntries: []ReserveEntry{},
+			expectedErr:     ErrNotEnoughItemsInSingleStorehouse,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			allocation, err := testCase.allocate(AllocationInput{
+				Destination: Location{50, 50},
+				Entries:     []ReserveEntry{{ItemID: "1", Count: testCase.count}},
+				Storehouses: storehouses,
+				Items:       items,
+				Costs:       DefaultCostModel(),
+			})
+
+			if testCase.expectedErr != nil {
+				assert.True(t, errors.Is(err, testCase.expectedErr))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.EqualValues(t, testCase.expectedEntries, allocation.Entries)
+			assert.False(t, allocation.Approximate)
+		})
+	}
+
+	// strategies must not change the given storehouses
+	assert.Equal(t, 4, storehouses["a"].ItemsData["1"].Available)
+	assert.Equal(t, 3, storehouses["b"].ItemsData["1"].Available)
+	assert.Equal(t, 10, storehouses["c"].ItemsData["1"].Available)
+}
+
+func TestSingleStorehouseStrategy_ManualEntries(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+	allocate := SingleStorehouseStrategy{}.Allocate
+
+	// without the manual entry the items would be taken from the nearest "a"
+	request := ReserveRequest{
+		DestinationLocation: Location{50, 50},
+		ItemsToReserve: []ReserveEntry{
+			{ItemID: "2", Count: 1, SourceStorehouseID: "c"},
+			{ItemID: "1", Count: 3},
+		},
+	}
+
+	reservation, err := NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.EqualValues(t, []ReserveEntry{
+		{ItemID: "2", Count: 1, SourceStorehouseID: "c", Manual: true},
+		{ItemID: "1", Count: 3, SourceStorehouseID: "c"},
+	}, reservation.Entries)
+
+	// the storehouse of the manual entry has not enough items
+	request.ItemsToReserve = []ReserveEntry{
+		{ItemID: "1", Count: 1, SourceStorehouseID: "b"},
+		{ItemID: "1", Count: 3},
+	}
+	_, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), allocate)
+	assert.ErrorIs(t, err, ErrNotEnoughItemsInSingleStorehouse)
+
+	request.ItemsToReserve = []ReserveEntry{
+		{ItemID: "1", Count: 1, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 1, SourceStorehouseID: "c"},
+		{ItemID: "1", Count: 1},
+	}
+	_, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), allocate)
+	assert.ErrorIs(t, err, ErrManualEntriesInSeveralStorehouses)
+}
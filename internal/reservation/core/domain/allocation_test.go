@@ -0,0 +1,320 @@
+package domain
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReservationFromReserveRequest_CostOptimal(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+
+	request := ReserveRequest{
+		DestinationLocation: Location{50, 50},
+		ItemsToReserve:      []ReserveEntry{{ItemID: "1", Count: 10}},
+	}
+
+	reservation, err := NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// nearest first would take 4 from "a", 3 from "b" and 3 from "c", paying k2 three times
+	expectedEntries := []ReserveEntry{
+		{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 6, SourceStorehouseID: "c"},
+	}
+	assert.EqualValues(t, expectedEntries, reservation.Entries)
+
+	nearestFirst := Reservation{
+		DestinationLocation: request.DestinationLocation,
+		Entries: []ReserveEntry{
+			{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+			{ItemID: "1", Count: 3, SourceStorehouseID: "b"},
+			{ItemID: "1", Count: 3, SourceStorehouseID: "c"},
+		},
+	}
+
+	optimalCost, err := reservation.GetTotalCost(storehouses, items, DefaultCostModel())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	nearestFirstCost, err := nearestFirst.GetTotalCost(storehouses, items, DefaultCostModel())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Less(t, optimalCost, nearestFirstCost)
+}
+
+func TestNewReservationFromReserveRequest_ManualStorehouseIsFree(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+
+	request := ReserveRequest{
+		DestinationLocation: Location{50, 50},
+		ItemsToReserve:      []ReserveEntry{{ItemID: "1", Count: 5}},
+	}
+
+	reservation, err := NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expectedEntries := []ReserveEntry{
+		{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 1, SourceStorehouseID: "b"},
+	}
+	assert.EqualValues(t, expectedEntries, reservation.Entries)
+
+	// "c" already pays k2 because of the manually placed item, so it's cheaper to take the rest from it
+	request.ItemsToReserve = append(request.ItemsToReserve, ReserveEntry{ItemID: "2", Count: 1, SourceStorehouseID: "c"})
+
+	reservation, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expectedEntries = []ReserveEntry{
+		{ItemID: "2", Count: 1, SourceStorehouseID: "c", Manual: true},
+		{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 1, SourceStorehouseID: "c"},
+	}
+	assert.EqualValues(t, expectedEntries, reservation.Entries)
+
+	// the source storehouses must stay untouched
+	assert.Equal(t, 10, storehouses["c"].ItemsData["1"].Available)
+	assert.Equal(t, 1, storehouses["c"].ItemsData["2"].Available)
+}
+
+// getLineOfStorehouses returns storehouses placed to the east of (50, 50) with about 71 km step
+func getLineOfStorehouses() (map[StoreHouseID]StoreHouse, map[ItemID]Item) {
+	items := map[ItemID]Item{
+		"1": {ID: "1", Name: "1", Size: &Size{0.1, 0.1, 0.1}, WeightKilograms: 10},
+		"2": {ID: "2", Name: "2", Size: &Size{0.1, 0.1, 0.1}, WeightKilograms: 10},
+	}
+
+	storehouses := map[StoreHouseID]StoreHouse{
+		"a": {ID: "a", Name: "a", Location: Location{Latitude: 50, Longitude: 51}, ItemsData: map[ItemID]ItemData{
+			"1": {Item: items["1"], OnHand: 4, Available: 4},
+		}},
+		"b": {ID: "b", Name: "b", Location: Location{Latitude: 50, Longitude: 52}, ItemsData: map[ItemID]ItemData{
+			"1": {Item: items["1"], OnHand: 3, Available: 3},
+		}},
+		"c": {ID: "c", Name: "c", Location: Location{Latitude: 50, Longitude: 53}, ItemsData: map[ItemID]ItemData{
+			"1": {Item: items["1"], OnHand: 10, Available: 10},
+			"2": {Item: items["2"], OnHand: 1, Available: 1},
+		}},
+	}
+
+	return storehouses, items
+}
+
+func TestAllocationStrategies_MatchBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+		storehouses, items, demands := getRandomNetwork(random)
+		input := AllocationInput{
+			Destination: Location{50, 50},
+			Entries:     demands,
+			Storehouses: storehouses,
+			Items:       items,
+			Costs:       DefaultCostModel(),
+		}
+
+		bestCost, bestFewest := math.Inf(1), allocationScore{storehouses: math.MaxInt, cost: math.Inf(1)}
+		for _, entries := range enumerateAllocations(demands, storehouses) {
+			reservation := Reservation{DestinationLocation: input.Destination, Entries: entries}
+			cost, err := reservation.GetTotalCost(storehouses, items, input.Costs)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			bestCost = min(bestCost, cost)
+			score := allocationScore{storehouses: len(groupEntriesPerStorehouse(entries)), cost: cost}
+			if score.isBetter(bestFewest, true) {
+				bestFewest = score
+			}
+		}
+
+		costOptimal, err := CostOptimalStrategy{}.Allocate(input)
+		if !assert.NoError(t, err, "network %d", i) {
+			t.FailNow()
+		}
+		reservation := Reservation{DestinationLocation: input.Destination, Entries: costOptimal.Entries}
+		cost, _ := reservation.GetTotalCost(storehouses, items, input.Costs)
+		assert.InDelta(t, bestCost, cost, 1e-6, "network %d", i)
+		assert.False(t, costOptimal.Approximate)
+
+		fewest, err := FewestStorehousesStrategy{}.Allocate(input)
+		if !assert.NoError(t, err, "network %d", i) {
+			t.FailNow()
+		}
+		reservation = Reservation{DestinationLocation: input.Destination, Entries: fewest.Entries}
+		cost, _ = reservation.GetTotalCost(storehouses, items, input.Costs)
+		assert.Equal(t, bestFewest.storehouses, len(groupEntriesPerStorehouse(fewest.Entries)), "network %d", i)
+		assert.InDelta(t, bestFewest.cost, cost, 1e-6, "network %d", i)
+
+		// a tiny budget leaves most sets unexplored, but the gap still bounds the best cost
+		for _, limited := range []struct {
+			allocate AllocateFunc
+			bestCost float64
+		}{
+			{allocate: CostOptimalStrategy{NodeBudget: 3}.Allocate, bestCost: bestCost},
+			{allocate: FewestStorehousesStrategy{NodeBudget: 3}.Allocate, bestCost: bestFewest.cost},
+		} {
+			allocation, err := limited.allocate(input)
+			if !assert.NoError(t, err, "network %d", i) {
+				t.FailNow()
+			}
+			reservation = Reservation{DestinationLocation: input.Destination, Entries: allocation.Entries}
+			cost, _ = reservation.GetTotalCost(storehouses, items, input.Costs)
+			assert.GreaterOrEqual(t, limited.bestCost, cost-allocation.Gap-1e-6, "network %d", i)
+			assert.Equal(t, allocation.Gap > 0, allocation.Approximate, "network %d", i)
+		}
+	}
+}
+
+func TestCostOptimalStrategy_NodeBudget(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	items := map[ItemID]Item{}
+	storehouses := map[StoreHouseID]StoreHouse{}
+	demands := make([]ReserveEntry, 0)
+	for i := 0; i < 10; i++ {
+		item := Item{ID: ItemID(strconv.Itoa(i)), Size: &Size{0.1, 0.1, 0.1}, WeightKilograms: 1 + random.Float64()*10}
+		items[item.ID] = item
+		demands = append(demands, ReserveEntry{ItemID: item.ID, Count: 3})
+	}
+	for i := 0; i < 24; i++ {
+		storehouse := StoreHouse{
+			ID:        StoreHouseID(strconv.Itoa(i)),
+			Location:  Location{Latitude: 45 + random.Float64()*10, Longitude: 45 + random.Float64()*10},
+			ItemsData: map[ItemID]ItemData{},
+		}
+		for id, item := range items {
+			if random.Intn(3) == 0 {
+				storehouse.ItemsData[id] = ItemData{Item: item, OnHand: 2, Available: 2}
+			}
+		}
+		storehouses[storehouse.ID] = storehouse
+	}
+
+	input := AllocationInput{
+		Destination: Location{50, 50},
+		Entries:     demands,
+		Storehouses: storehouses,
+		Items:       items,
+		Costs:       DefaultCostModel(),
+	}
+
+	started := time.Now()
+	allocation, err := CostOptimalStrategy{}.Allocate(input)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Less(t, time.Since(started), 5*time.Second)
+	assertAllocated(t, demands, allocation.Entries)
+
+	allocation, err = CostOptimalStrategy{NodeBudget: 10}.Allocate(input)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.True(t, allocation.Approximate)
+	assert.Positive(t, allocation.Gap)
+	assertAllocated(t, demands, allocation.Entries)
+}
+
+func assertAllocated(t *testing.T, demands, entries []ReserveEntry) {
+	allocated := make(map[ItemID]int)
+	for _, entry := range entries {
+		allocated[entry.ItemID] += entry.Count
+	}
+
+	for _, demand := range demands {
+		assert.Equal(t, demand.Count, allocated[demand.ItemID], "item: %s", demand.ItemID)
+	}
+}
+
+// getRandomNetwork returns up to 5 storehouses and up to 2 demanded items with up to 4 units,
+// every demand can be satisfied
+func getRandomNetwork(random *rand.Rand) (map[StoreHouseID]StoreHouse, map[ItemID]Item, []ReserveEntry) {
+	items := map[ItemID]Item{
+		"1": {ID: "1", Size: &Size{0.1, 0.2, 0.3}, WeightKilograms: 1 + random.Float64()*20},
+		"2": {ID: "2", Size: &Size{0.5, 0.5, 0.5}, WeightKilograms: 1 + random.Float64()*20},
+	}
+
+	storehouses := make(map[StoreHouseID]StoreHouse)
+	stock := make(map[ItemID]int)
+	for i := 0; i < 2+random.Intn(4); i++ {
+		storehouse := StoreHouse{
+			ID:        StoreHouseID(strconv.Itoa(i)),
+			Location:  Location{Latitude: 48 + random.Float64()*4, Longitude: 48 + random.Float64()*4},
+			ItemsData: map[ItemID]ItemData{},
+		}
+		for id, item := range items {
+			if available := random.Intn(4); available > 0 {
+				storehouse.ItemsData[id] = ItemData{Item: item, OnHand: available, Available: available}
+				stock[id] += available
+			}
+		}
+		storehouses[storehouse.ID] = storehouse
+	}
+
+	demands := make([]ReserveEntry, 0)
+	for _, id := range []ItemID{"1", "2"} {
+		if stock[id] > 0 {
+			demands = append(demands, ReserveEntry{ItemID: id, Count: 1 + random.Intn(min(stock[id], 4))})
+		}
+	}
+
+	return storehouses, items, demands
+}
+
+// enumerateAllocations returns every way to take the demanded units from the storehouses
+func enumerateAllocations(demands []ReserveEntry, storehouses map[StoreHouseID]StoreHouse) [][]ReserveEntry {
+	ids := make([]StoreHouseID, 0, len(storehouses))
+	for id := range storehouses {
+		ids = append(ids, id)
+	}
+
+	var allocations [][]ReserveEntry
+	var place func(demand, storehouse, left int, entries []ReserveEntry)
+	place = func(demand, storehouse, left int, entries []ReserveEntry) {
+		if demand == len(demands) {
+			allocations = append(allocations, slices.Clone(entries))
+			return
+		}
+
+		if storehouse == len(ids) {
+			if left == 0 && demand+1 < len(demands) {
+				place(demand+1, 0, demands[demand+1].Count, entries)
+			} else if left == 0 {
+				place(demand+1, 0, 0, entries)
+			}
+			return
+		}
+
+		itemID := demands[demand].ItemID
+		available := storehouses[ids[storehouse]].ItemsData[itemID].Available
+		for taken := 0; taken <= min(available, left); taken++ {
+			next := entries
+			if taken > 0 {
+				next = append(slices.Clone(entries), ReserveEntry{ItemID: itemID, Count: taken, SourceStorehouseID: ids[storehouse]})
+			}
+			place(demand, storehouse+1, left-taken, next)
+		}
+	}
+
+	if len(demands) > 0 {
+		place(0, 0, demands[0].Count, nil)
+	}
+
+	return allocations
+}
@@ -0,0 +1,204 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrInvalidCostModel = errors.New("invalid cost model")
+
+// CostModel calculates transport cost of a reservation. Every vehicle leaving a storehouse used by the reservation
+// costs StorehouseCost and every unit taken from the storehouse costs UnitCost.
+// Storehouses are ranked by the same distances the cost is based on
+type CostModel interface {
+	DistanceProvider
+	UnitCost(storehouse StoreHouse, destination Location, item Item) float64
+	StorehouseCost(storehouse StoreHouse, destination Location) float64
+}
+
+type CostFormula string
+
+const (
+	// LogMaxFormula is k1 * distance_km * ln(max(mass_kg, volume_m3, 1)). The logarithm is not allowed to be negative,
+	// otherwise a farther storehouse would be cheaper for items lighter than 1 kg and smaller than 1 m3
+	LogMaxFormula CostFormula = "log-max"
+	// LinearWeightFormula is k1 * distance_km * mass_kg
+	LinearWeightFormula CostFormula = "linear-weight"
+	// VolumetricWeightFormula is k1 * distance_km * max(mass_kg, volume_cm3 / divisor)
+	VolumetricWeightFormula CostFormula = "volumetric-weight"
+	// TieredDistanceFormula is rate_per_kg of the distance tier * mass_kg
+	TieredDistanceFormula CostFormula = "tiered-distance"
+)
+
+// DistanceTier is applied to distances up to UpToKm which are longer than the ones of the previous tier.
+// The last tier also covers all longer distances
+type DistanceTier struct {
+	UpToKm    float64
+	RatePerKg float64
+}
+
+// CostCoefficients are parameters of one formula. K2 is paid per vehicle with any formula
+type CostCoefficients struct {
+	Formula CostFormula
+	K1      float64
+	K2      float64
+	// VolumetricDivisor is the number of cubic centimeters per one kilogram of volumetric weight
+	VolumetricDivisor float64
+	Tiers             []DistanceTier
+}
+
+func (coefficients CostCoefficients) Validate() error {
+	if coefficients.K1 < 0 || coefficients.K2 < 0 {
+		return fmt.Errorf("%w: coefficients must not be negative", ErrInvalidCostModel)
+	}
+
+	switch coefficients.Formula {
+	case LogMaxFormula, LinearWeightFormula:
+	case VolumetricWeightFormula:
+		if coefficients.VolumetricDivisor <= 0 {
+			return fmt.Errorf("%w: volumetric divisor must be positive", ErrInvalidCostModel)
+		}
+	case TieredDistanceFormula:
+		if len(coefficients.Tiers) == 0 {
+			return fmt.Errorf("%w: tiered distance formula requires tiers", ErrInvalidCostModel)
+		}
+
+		for i, tier := range coefficients.Tiers {
+			if tier.RatePerKg < 0 || tier.UpToKm <= 0 || (i > 0 && tier.UpToKm <= coefficients.Tiers[i-1].UpToKm) {
+				return fmt.Errorf("%w: tiers must have increasing positive distances and not negative rates",
+					ErrInvalidCostModel)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown formula: %s", ErrInvalidCostModel, coefficients.Formula)
+	}
+
+	return nil
+}
+
+func (coefficients CostCoefficients) unitCost(distance float64, item Item) float64 {
+	switch coefficients.Formula {
+	case LinearWeightFormula:
+		return coefficients.K1 * distance * item.WeightKilograms
+	case VolumetricWeightFormula:
+		volumetricWeight := item.VolumeM2() * 1e6 / coefficients.VolumetricDivisor
+		return coefficients.K1 * distance * max(item.WeightKilograms, volumetricWeight)
+	case TieredDistanceFormula:
+		tier := coefficients.Tiers[len(coefficients.Tiers)-1]
+		for _, candidate := range coefficients.Tiers {
+			if distance <= candidate.UpToKm {
+				tier = candidate
+				break
+			}
+		}
+
+		return tier.RatePerKg * item.WeightKilograms
+	default:
+		return coefficients.K1 * distance * math.Log(max(item.WeightKilograms, item.VolumeM2(), 1))
+	}
+}
+
+// CostRule replaces default coefficients for shipments from the storehouse, to the region or both.
+// An empty storehouse ID or region matches everything
+type CostRule struct {
+	StorehouseID StoreHouseID
+	Region       string
+	Coefficients CostCoefficients
+}
+
+// RuleCostModel takes coefficients of the most specific matching rule: a rule for both the storehouse
+// and the region is preferred to a rule for the storehouse only, which is preferred to a rule for the region only.
+// Among equally specific rules the first one wins. Without matching rules the defaults are used
+type RuleCostModel struct {
+	DistanceProvider
+
+	defaults CostCoefficients
+	regions  map[string]Region
+	rules    []CostRule
+}
+
+func NewRuleCostModel(
+	defaults CostCoefficients, regions []Region, rules []CostRule, distances DistanceProvider) (*RuleCostModel, error) {
+
+	err := defaults.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("default coefficients: %w", err)
+	}
+
+	model := &RuleCostModel{
+		DistanceProvider: distances,
+		defaults:         defaults,
+		regions:          make(map[string]Region, len(regions)),
+		rules:            rules,
+	}
+	for _, region := range regions {
+		if region.Name == "" || region.MinLatitude > region.MaxLatitude || region.MinLongitude > region.MaxLongitude {
+			return nil, fmt.Errorf("%w: region must have a name and not empty bounds: %+v", ErrInvalidCostModel, region)
+		}
+
+		model.regions[region.Name] = region
+	}
+
+	for i, rule := range rules {
+		if rule.StorehouseID.IsEmpty() && rule.Region == "" {
+			return nil, fmt.Errorf("%w: rule %d matches everything, use defaults instead", ErrInvalidCostModel, i)
+		}
+
+		if _, ok := model.regions[rule.Region]; rule.Region != "" && !ok {
+			return nil, fmt.Errorf("%w: rule %d has unknown region: %s", ErrInvalidCostModel, i, rule.Region)
+		}
+
+		err = rule.Coefficients.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+
+	return model, nil
+}
+
+// DefaultCostModel is k1 * distance_km * ln(max(mass_kg, volume_m3, 1)) per unit and k2 per vehicle
+// with k1 = 1, k2 = 1000 and the great-circle distance
+func DefaultCostModel() *RuleCostModel {
+	return &RuleCostModel{
+		DistanceProvider: HaversineDistanceProvider{},
+		defaults:         CostCoefficients{Formula: LogMaxFormula, K1: 1, K2: 1e3},
+	}
+}
+
+func (model *RuleCostModel) UnitCost(storehouse StoreHouse, destination Location, item Item) float64 {
+	return model.coefficients(storehouse.ID, destination).unitCost(model.DistanceKm(storehouse, destination), item)
+}
+
+func (model *RuleCostModel) StorehouseCost(storehouse StoreHouse, destination Location) float64 {
+	return model.coefficients(storehouse.ID, destination).K2
+}
+
+func (model *RuleCostModel) coefficients(storehouseID StoreHouseID, destination Location) CostCoefficients {
+	coefficients := model.defaults
+	bestSpecificity := 0
+	for _, rule := range model.rules {
+		specificity := 0
+		if !rule.StorehouseID.IsEmpty() {
+			if rule.StorehouseID != storehouseID {
+				continue
+			}
+			specificity += 2
+		}
+
+		if rule.Region != "" {
+			if !model.regions[rule.Region].Contains(destination) {
+				continue
+			}
+			specificity++
+		}
+
+		if specificity > bestSpecificity {
+			coefficients = rule.Coefficients
+			bestSpecificity = specificity
+		}
+	}
+
+	return coefficients
+}
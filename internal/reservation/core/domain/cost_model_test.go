@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleCostModel(t *testing.T) {
+	item := Item{ID: "1", Size: &Size{LengthMeters: 0.5, WidthMeters: 0.4, HeightMeters: 0.5}, WeightKilograms: 10}
+	storehouse := StoreHouse{ID: "a", Location: Location{Latitude: 50, Longitude: 50}}
+	near := Location{Latitude: 50, Longitude: 51}
+	far := Location{Latitude: 60, Longitude: 51}
+	distance := getDistance(storehouse.Location, near)
+
+	model, err := NewRuleCostModel(
+		CostCoefficients{Formula: LogMaxFormula, K1: 1, K2: 1000},
+		[]Region{{Name: "south", MinLatitude: 40, MaxLatitude: 55, MinLongitude: 40, MaxLongitude: 60}},
+		[]CostRule{
+			{Region: "south", Coefficients: CostCoefficients{Formula: LinearWeightFormula, K1: 2, K2: 500}},
+			{StorehouseID: "a", Coefficients: CostCoefficients{Formula: VolumetricWeightFormula, K1: 1, K2: 300, VolumetricDivisor: 5000}},
+			{StorehouseID: "a", Region: "south", Coefficients: CostCoefficients{
+				Formula: TieredDistanceFormula, K2: 100, Tiers: []DistanceTier{{UpToKm: 10, RatePerKg: 1}, {UpToKm: 100, RatePerKg: 3}},
+			}},
+		}, HaversineDistanceProvider{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the rule for both the storehouse and the region is the most specific one
+	assert.InDelta(t, 30, model.UnitCost(storehouse, near, item), 1e-9)
+	assert.InDelta(t, 100, model.StorehouseCost(storehouse, near), 1e-9)
+
+	// the destination is out of the region, so the storehouse rule with volumetric weight 0.1 m3 = 20 kg is used
+	assert.InDelta(t, getDistance(storehouse.Location, far)*20, model.UnitCost(storehouse, far, item), 1e-9)
+
+	other := StoreHouse{ID: "b", Location: storehouse.Location}
+	assert.InDelta(t, 2*distance*10, model.UnitCost(other, near, item), 1e-9)
+	assert.InDelta(t, 1000, model.StorehouseCost(other, far), 1e-9)
+	assert.InDelta(t, getDistance(other.Location, far)*math.Log(10), model.UnitCost(other, far, item), 1e-9)
+
+	_, err = NewRuleCostModel(CostCoefficients{Formula: "unknown"}, nil, nil, HaversineDistanceProvider{})
+	assert.ErrorIs(t, err, ErrInvalidCostModel)
+
+	_, err = NewRuleCostModel(CostCoefficients{Formula: LogMaxFormula}, nil,
+		[]CostRule{{Region: "north", Coefficients: CostCoefficients{Formula: LogMaxFormula}}}, HaversineDistanceProvider{})
+	assert.ErrorIs(t, err, ErrInvalidCostModel)
+
+	_, err = NewRuleCostModel(CostCoefficients{Formula: TieredDistanceFormula,
+		Tiers: []DistanceTier{{UpToKm: 100, RatePerKg: 1}, {UpToKm: 50, RatePerKg: 2}}}, nil, nil, HaversineDistanceProvider{})
+	assert.ErrorIs(t, err, ErrInvalidCostModel)
+}
+
+func TestDefaultCostModel_LightItem(t *testing.T) {
+	// the logarithm of both the mass and the volume is negative, which used to make farther storehouses cheaper
+	item := Item{ID: "1", Name: "1", Size: &Size{0.1, 0.1, 0.1}, WeightKilograms: 0.3}
+	storehouses := map[StoreHouseID]StoreHouse{
+		"near": {ID: "near", Name: "near", Location: Location{Latitude: 50, Longitude: 50}, ItemsData: map[ItemID]ItemData{
+			"1": {Item: item, OnHand: 5, Available: 5},
+		}},
+		"far": {ID: "far", Name: "far", Location: Location{Latitude: -30, Longitude: -100}, ItemsData: map[ItemID]ItemData{
+			"1": {Item: item, OnHand: 5, Available: 5},
+		}},
+	}
+	destination := Location{Latitude: 50.1, Longitude: 50.1}
+
+	model := DefaultCostModel()
+	assert.Zero(t, model.UnitCost(storehouses["far"], destination, item))
+
+	allocation, err := CostOptimalStrategy{}.Allocate(AllocationInput{
+		Destination: destination,
+		Entries:     []ReserveEntry{{ItemID: "1", Count: 2}},
+		Storehouses: storehouses,
+		Items:       map[ItemID]Item{"1": item},
+		Costs:       model,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []ReserveEntry{{ItemID: "1", Count: 2, SourceStorehouseID: "near"}}, allocation.Entries)
+
+	reservation := Reservation{DestinationLocation: destination, Entries: allocation.Entries}
+	cost, err := reservation.GetTotalCost(storehouses, map[ItemID]Item{"1": item}, model)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, cost, 0.0)
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidDistanceMatrix = errors.New("invalid distance matrix")
+
+// DistanceProvider returns delivery distances from storehouses to destinations
+type DistanceProvider interface {
+	DistanceKm(storehouse StoreHouse, destination Location) float64
+}
+
+// HaversineDistanceProvider uses the great-circle distance, so real routes are usually longer
+type HaversineDistanceProvider struct{}
+
+func (HaversineDistanceProvider) DistanceKm(storehouse StoreHouse, destination Location) float64 {
+	return getDistance(storehouse.Location, destination)
+}
+
+// MatrixRoute is a precomputed road route from the storehouse to any destination of the region
+type MatrixRoute struct {
+	StorehouseID StoreHouseID
+	Region       string
+	DistanceKm   float64
+}
+
+type matrixKey struct {
+	storehouseID StoreHouseID
+	region       string
+}
+
+// MatrixDistanceProvider returns the route from the storehouse to the first region containing the destination.
+// Destinations out of all regions and storehouses without a route to the region fall back to the great-circle distance
+type MatrixDistanceProvider struct {
+	regions []Region
+	routes  map[matrixKey]MatrixRoute
+}
+
+func NewMatrixDistanceProvider(regions []Region, routes []MatrixRoute) (*MatrixDistanceProvider, error) {
+	provider := &MatrixDistanceProvider{regions: regions, routes: make(map[matrixKey]MatrixRoute, len(routes))}
+
+	for _, route := range routes {
+		known := false
+		for _, region := range regions {
+			known = known || region.Name == route.Region
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: unknown region: %s", ErrInvalidDistanceMatrix, route.Region)
+		}
+
+		if route.StorehouseID.IsEmpty() || route.DistanceKm < 0 {
+			return nil, fmt.Errorf("%w: route must have a storehouse and not negative distance: %+v",
+				ErrInvalidDistanceMatrix, route)
+		}
+
+		key := matrixKey{storehouseID: route.StorehouseID, region: route.Region}
+		if _, ok := provider.routes[key]; ok {
+			return nil, fmt.Errorf("%w: duplicated route: storehouse: %s, region: %s",
+				ErrInvalidDistanceMatrix, route.StorehouseID, route.Region)
+		}
+
+		provider.routes[key] = route
+	}
+
+	return provider, nil
+}
+
+func (provider *MatrixDistanceProvider) DistanceKm(storehouse StoreHouse, destination Location) float64 {
+	if route, ok := provider.route(storehouse, destination); ok {
+		return route.DistanceKm
+	}
+
+	return getDistance(storehouse.Location, destination)
+}
+
+func (provider *MatrixDistanceProvider) route(storehouse StoreHouse, destination Location) (MatrixRoute, bool) {
+	for _, region := range provider.regions {
+		if region.Contains(destination) {
+			route, ok := provider.routes[matrixKey{storehouseID: storehouse.ID, region: region.Name}]
+			return route, ok
+		}
+	}
+
+	return MatrixRoute{}, false
+}
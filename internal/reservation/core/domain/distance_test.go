@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatrixDistanceProvider(t *testing.T) {
+	regions := []Region{{Name: "south", MinLatitude: 40, MaxLatitude: 55, MinLongitude: 40, MaxLongitude: 60}}
+	storehouses, _ := getLineOfStorehouses()
+	inRegion := Location{Latitude: 50, Longitude: 50}
+	outOfRegion := Location{Latitude: 60, Longitude: 50}
+
+	provider, err := NewMatrixDistanceProvider(regions, []MatrixRoute{
+		{StorehouseID: "a", Region: "south", DistanceKm: 500},
+		{StorehouseID: "c", Region: "south", DistanceKm: 300},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, 500.0, provider.DistanceKm(storehouses["a"], inRegion))
+	assert.Equal(t, 300.0, provider.DistanceKm(storehouses["c"], inRegion))
+
+	// no route from "b" and no region for the other destination
+	assert.Equal(t, getDistance(storehouses["b"].Location, inRegion), provider.DistanceKm(storehouses["b"], inRegion))
+	assert.Equal(t, getDistance(storehouses["a"].Location, outOfRegion), provider.DistanceKm(storehouses["a"], outOfRegion))
+
+	// the road to "a" is longer than to "c", so "c" becomes the nearest one
+	sorted := sortStorehousesByDistance(storehouses, inRegion, provider)
+	assert.Equal(t, []StoreHouseID{"b", "c", "a"}, []StoreHouseID{sorted[0].ID, sorted[1].ID, sorted[2].ID})
+
+	_, err = NewMatrixDistanceProvider(regions, []MatrixRoute{{StorehouseID: "a", Region: "north", DistanceKm: 1}})
+	assert.ErrorIs(t, err, ErrInvalidDistanceMatrix)
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidDuration = errors.New("invalid duration")
+)
+
+// Duration is time.Duration written in JSON as a string like "1h30m"
+type Duration time.Duration
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	err := json.Unmarshal(data, &str)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDuration, string(data))
+	}
+
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDuration, str)
+	}
+
+	*duration = Duration(parsed)
+
+	return nil
+}
@@ -1,12 +1,19 @@
 package domain
 
+import "errors"
+
+var (
+	ErrItemAlreadyExists = errors.New("item already exists")
+)
+
 type ItemID string
 
+// Item is a catalog entry. Size and weight must be positive, otherwise the transport cost is not defined
 type Item struct {
-	ID              ItemID  `json:"id"`
-	Name            string  `json:"name,omitempty"`
-	Size            *Size   `json:"size,omitempty"`
-	WeightKilograms float64 `json:"weightKilograms,omitempty"`
+	ID              ItemID  `json:"id" validate:"required"`
+	Name            string  `json:"name,omitempty" validate:"required"`
+	Size            *Size   `json:"size,omitempty" validate:"required"`
+	WeightKilograms float64 `json:"weightKilograms,omitempty" validate:"gt=0"`
 }
 
 func (item *Item) VolumeM2() float64 {
@@ -14,7 +21,7 @@ func (item *Item) VolumeM2() float64 {
 }
 
 type Size struct {
-	LengthMeters float64 `json:"lengthMeters"`
-	WidthMeters  float64 `json:"widthMeters"`
-	HeightMeters float64 `json:"heightMeters"`
+	LengthMeters float64 `json:"lengthMeters" validate:"gt=0"`
+	WidthMeters  float64 `json:"widthMeters" validate:"gt=0"`
+	HeightMeters float64 `json:"heightMeters" validate:"gt=0"`
 }
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItem_Validation(t *testing.T) {
+	validate := validator.New()
+
+	item := Item{
+		ID:              "1",
+		Name:            "1",
+		Size:            &Size{LengthMeters: 1, WidthMeters: 0.5, HeightMeters: 0.1},
+		WeightKilograms: 2,
+	}
+	assert.NoError(t, validate.Struct(item))
+
+	item.Size.WidthMeters = 0
+	assert.Error(t, validate.Struct(item))
+
+	item.Size = nil
+	assert.Error(t, validate.Struct(item))
+
+	item.Size = &Size{LengthMeters: 1, WidthMeters: 1, HeightMeters: 1}
+	item.WeightKilograms = -1
+	assert.Error(t, validate.Struct(item))
+}
@@ -32,3 +32,17 @@ func hav(x float64) float64 {
 func deg2rad(deg float64) float64 {
 	return deg * math.Pi / 180
 }
+
+// Region is a destination area bounded by latitudes and longitudes
+type Region struct {
+	Name         string
+	MinLatitude  float64
+	MaxLatitude  float64
+	MinLongitude float64
+	MaxLongitude float64
+}
+
+func (region Region) Contains(location Location) bool {
+	return location.Latitude >= region.MinLatitude && location.Latitude <= region.MaxLatitude &&
+		location.Longitude >= region.MinLongitude && location.Longitude <= region.MaxLongitude
+}
@@ -5,4 +5,6 @@ type OperationType string
 const (
 	Reserve OperationType = "reserve"
 	Release OperationType = "release"
+	// Ship takes reserved items away from storehouses for good
+	Ship OperationType = "ship"
 )
@@ -0,0 +1,25 @@
+package domain
+
+// ReleaseEntry describes a part of a reservation to release:
+//   - storehouse only: all entries from the storehouse;
+//   - item only: Count units of the item, starting from the storehouse with the highest transport cost;
+//   - item and storehouse: Count units of the item from the storehouse.
+//
+// Zero Count releases all matching units
+type ReleaseEntry struct {
+	ItemID             ItemID       `json:"itemID"`
+	Count              int          `json:"count" validate:"min=0"`
+	SourceStorehouseID StoreHouseID `json:"sourceStorehouseID"`
+}
+
+func (entry ReleaseEntry) matches(reserveEntry ReserveEntry) bool {
+	if entry.ItemID != "" && entry.ItemID != reserveEntry.ItemID {
+		return false
+	}
+
+	if !entry.SourceStorehouseID.IsEmpty() && entry.SourceStorehouseID != reserveEntry.SourceStorehouseID {
+		return false
+	}
+
+	return true
+}
@@ -4,9 +4,8 @@ import (
 	"cmp"
 	"errors"
 	"fmt"
-	"maps"
-	"math"
 	"slices"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -20,52 +19,57 @@ var (
 	ErrNotEnoughItemsInReservation    = errors.New("not enough items in reservation")
 )
 
-const (
-	k1 float64 = 1
-	k2 float64 = 1e3
-)
-
 type Reservation struct {
-	ID                  string         `json:"id"`
-	DestinationLocation Location       `json:"destinationLocation"`
-	Entries             []ReserveEntry `json:"entries"`
+	ID                  string            `json:"id"`
+	DestinationLocation Location          `json:"destinationLocation"`
+	Entries             []ReserveEntry    `json:"entries"`
+	Status              ReservationStatus `json:"status"`
+	// Strategy placed the entries which are not manual. Reoptimization uses the same strategy
+	Strategy  AllocationStrategyName `json:"strategy"`
+	CreatedAt time.Time              `json:"createdAt"`
+	// ExpiresAt is the moment when the reservation is released automatically. Nil means it's held until released
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Version is increased on every change of the reservation and is used to detect concurrent updates
+	Version int64 `json:"-"`
+	// Approximate is set if the strategy couldn't guarantee the best placement of the entries. It's not stored
+	Approximate bool `json:"approximate,omitempty"`
+	// Gap bounds how much cheaper the best placement may be than the approximate one. It's not stored
+	Gap float64 `json:"gap,omitempty"`
 }
 
-// GetTotalCost returns transport cost of the reservation.
-// The transport cost is calculated with the formula:
-//
-//	k1 * distance_km * ln(max(mass_kg, volume_m2)) + k2
-//
-// where k1 * ... is added per item and k2 is added per storehouse
-func (reservation *Reservation) GetTotalCost(storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item) (float64, error) {
-	storehouseEntries := groupEntriesPerStorehouse(reservation.Entries)
+// ItemIDs returns IDs of all items of the reservation
+func (reservation *Reservation) ItemIDs() []ItemID {
+	return entriesItemIDs(reservation.Entries)
+}
 
-	var totalCost float64 = 0
-	var resultErr error
-	for storehouseID, entries := range storehouseEntries {
-		storehouse, ok := storehouses[storehouseID]
-		if !ok {
-			resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s", ErrUnknownStorehouse, storehouseID))
-			continue
+func entriesItemIDs(entries []ReserveEntry) []ItemID {
+	ids := make([]ItemID, 0, len(entries))
+	for _, entry := range entries {
+		if !slices.Contains(ids, entry.ItemID) {
+			ids = append(ids, entry.ItemID)
 		}
+	}
 
-		distance := getDistance(storehouse.Location, reservation.DestinationLocation)
+	return ids
+}
 
-		var totalEntryMetric float64 = 0
-		for _, entry := range entries {
-			item, ok := items[entry.ItemID]
-			if !ok {
-				resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s", ErrUnknownItem, entry.ItemID))
-				continue
-			}
+// GetTotalCost returns transport cost of the reservation calculated with the cost model.
+// With the default model the transport cost is calculated with the formula:
+//
+//	k1 * distance_km * ln(max(mass_kg, volume_m3, 1)) + k2
+//
+// where k1 * ... is added per item and k2 is added per vehicle leaving a storehouse
+func (reservation *Reservation) GetTotalCost(
+	storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item, costs CostModel) (float64, error) {
 
-			totalEntryMetric += math.Log(max(item.WeightKilograms, item.VolumeM2())) * float64(entry.Count)
-		}
+	shipments, err := reservation.GetShipments(storehouses, items, costs)
 
-		totalCost += k1*distance*totalEntryMetric + k2
+	var totalCost float64 = 0
+	for _, shipment := range shipments {
+		totalCost += shipment.Cost
 	}
 
-	return totalCost, resultErr
+	return totalCost, err
 }
 
 func groupEntriesPerStorehouse(entries []ReserveEntry) map[StoreHouseID][]ReserveEntry {
@@ -80,12 +84,7 @@ func groupEntriesPerStorehouse(entries []ReserveEntry) map[StoreHouseID][]Reserv
 }
 
 func (reservation *Reservation) GetUpdatedStorehouses(oldStorehouses map[StoreHouseID]StoreHouse, op OperationType, items map[ItemID]Item) (map[StoreHouseID]StoreHouse, error) {
-	updatedSH := maps.Clone(oldStorehouses)
-	for storehouseID, storehouse := range oldStorehouses {
-		updatedStorehouse := updatedSH[storehouseID]
-		updatedStorehouse.ItemsData = maps.Clone(storehouse.ItemsData)
-		updatedSH[storehouseID] = updatedStorehouse
-	}
+	updatedSH := CloneStorehouses(oldStorehouses)
 
 	for _, entry := range reservation.Entries {
 		storehouse, ok := updatedSH[entry.SourceStorehouseID]
@@ -99,23 +98,20 @@ func (reservation *Reservation) GetUpdatedStorehouses(oldStorehouses map[StoreHo
 		}
 		// TODO: make simpler
 		if itemInfo, itemExists := items[entry.ItemID]; op == Release && !ok && itemExists {
-			itemData = ItemData{
-				Item:  itemInfo,
-				Count: 0,
-			}
+			itemData = ItemData{Item: itemInfo}
 		}
 
 		if op == Reserve {
-			if itemData.Count < entry.Count {
-				return nil, fmt.Errorf("%w: expected at least: %d, have: %d", ErrNotEnoughItemsInStorehouse, entry.Count, itemData.Count)
+			if itemData.Available < entry.Count {
+				return nil, fmt.Errorf("%w: expected at least: %d, have: %d", ErrNotEnoughItemsInStorehouse, entry.Count, itemData.Available)
 			}
 
-			itemData.Count -= entry.Count
+			itemData.Available -= entry.Count
 		} else if op == Release {
-			itemData.Count += entry.Count
+			itemData.Available += entry.Count
 		}
 
-		if itemData.Count == 0 {
+		if itemData.Available == 0 {
 			delete(storehouse.ItemsData, entry.ItemID)
 		} else {
 			storehouse.ItemsData[entry.ItemID] = itemData
@@ -127,64 +123,110 @@ func (reservation *Reservation) GetUpdatedStorehouses(oldStorehouses map[StoreHo
 	return updatedSH, nil
 }
 
-func NewReservationFromReserveRequest(request ReserveRequest, storehouses map[StoreHouseID]StoreHouse) (Reservation, error) {
+// NewReservationFromReserveRequest takes manually placed entries from their storehouses
+// and places the rest with the given allocation function
+func NewReservationFromReserveRequest(request ReserveRequest, storehouses map[StoreHouseID]StoreHouse,
+	items map[ItemID]Item, costs CostModel, allocate AllocateFunc) (Reservation, error) {
+
 	reservation := Reservation{
 		ID:                  uuid.New().String(),
 		DestinationLocation: request.DestinationLocation,
 		Entries:             make([]ReserveEntry, 0),
+		Status:              Held,
+		Strategy:            request.Strategy,
+	}
+	if reservation.Strategy == "" {
+		reservation.Strategy = DefaultAllocationStrategy
 	}
 
 	var resultErr error
 
-	knownEntries, leftEntries, updatedStorehouses, err := filterKnownDistributions(request.ItemsToReserve, storehouses)
+	if request.DispatchWindow != nil {
+		err := request.DispatchWindow.Validate()
+		if err != nil {
+			return Reservation{}, err
+		}
+	}
+
+	knownEntries, leftEntries, updatedStorehouses, err := filterKnownDistributions(
+		request.ItemsToReserve, storehouses, request.DispatchWindow)
 	resultErr = errors.Join(resultErr, err)
 
 	reservation.Entries = knownEntries
 
-	sortedStorehouses := sortStorehousesByDistance(updatedStorehouses, request.DestinationLocation)
+	openedStorehouses := make(map[StoreHouseID][]ReserveEntry)
+	for _, entry := range knownEntries {
+		openedStorehouses[entry.SourceStorehouseID] = append(openedStorehouses[entry.SourceStorehouseID], entry)
+	}
 
-	distributedEntries, err := distribute(leftEntries, sortedStorehouses)
+	allocation, err := allocate(AllocationInput{
+		Destination:       request.DestinationLocation,
+		Entries:           leftEntries,
+		Storehouses:       dispatchingStorehouses(updatedStorehouses, request.DispatchWindow),
+		Items:             items,
+		Costs:             costs,
+		OpenedStorehouses: openedStorehouses,
+	})
 	resultErr = errors.Join(resultErr, err)
 
-	reservation.Entries = append(reservation.Entries, distributedEntries...)
+	reservation.Entries = append(reservation.Entries, allocation.Entries...)
+	reservation.Approximate = allocation.Approximate
+	reservation.Gap = allocation.Gap
+
+	resultErr = errors.Join(resultErr, checkVehicles(reservation.Entries, storehouses, items))
 
 	return reservation, resultErr
 }
 
+// checkVehicles returns an error for every storehouse which vehicles can't carry its part of the entries
+func checkVehicles(entries []ReserveEntry, storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item) (resultErr error) {
+	for storehouseID, storehouseEntries := range groupEntriesPerStorehouse(entries) {
+		err := storehouses[storehouseID].Vehicle.CheckShipment(storehouseEntries, items)
+		if err != nil {
+			resultErr = errors.Join(resultErr, fmt.Errorf("storehouse: %s: %w", storehouseID, err))
+		}
+	}
+
+	return resultErr
+}
+
 func filterKnownDistributions(
-	entriesToFilter []ReserveEntry, storehouses map[StoreHouseID]StoreHouse) (
+	entriesToFilter []ReserveEntry, storehouses map[StoreHouseID]StoreHouse, window *DispatchWindow) (
 	known, left []ReserveEntry, updatedStorehouses map[StoreHouseID]StoreHouse, resultErr error) {
 
-	updatedStorehouses = maps.Clone(storehouses)
-	for storehouseID, storehouse := range storehouses {
-		storehouseDst := updatedStorehouses[storehouseID]
-		storehouseDst.ItemsData = maps.Clone(storehouse.ItemsData)
-		updatedStorehouses[storehouseID] = storehouseDst
-	}
+	updatedStorehouses = CloneStorehouses(storehouses)
 
 	for _, entry := range entriesToFilter {
 		if entry.SourceStorehouseID.IsEmpty() {
+			entry.Manual = false
 			left = append(left, entry)
 			continue
 		}
 
-		storehouse, ok := storehouses[entry.SourceStorehouseID]
+		storehouse, ok := updatedStorehouses[entry.SourceStorehouseID]
 		if !ok {
 			err := fmt.Errorf("%w: %s", ErrUnknownStorehouse, entry.SourceStorehouseID)
 			resultErr = errors.Join(resultErr, err)
 			continue
 		}
 
+		err := storehouse.CheckDispatch(window)
+		if err != nil {
+			resultErr = errors.Join(resultErr, err)
+			continue
+		}
+
 		itemData, ok := storehouse.ItemsData[entry.ItemID]
-		if !ok || itemData.Count < entry.Count {
+		if !ok || itemData.Available < entry.Count {
 			err := fmt.Errorf("%w: storehouse id: %s, item id: %s", ErrNotEnoughItemsInStorehouse, storehouse.ID, entry.ItemID)
 			resultErr = errors.Join(resultErr, err)
 			continue
 		}
 
+		entry.Manual = true
 		known = append(known, entry)
 
-		itemData.Count -= entry.Count
+		itemData.Available -= entry.Count
 		storehouse.ItemsData[entry.ItemID] = itemData
 		updatedStorehouses[entry.SourceStorehouseID] = storehouse
 	}
@@ -192,107 +234,152 @@ func filterKnownDistributions(
 	return known, left, updatedStorehouses, resultErr
 }
 
-func sortStorehousesByDistance(storehouses map[StoreHouseID]StoreHouse, from Location) []StoreHouse {
+func sortStorehousesByDistance(
+	storehouses map[StoreHouseID]StoreHouse, from Location, distances DistanceProvider) []StoreHouse {
+
 	slice := make([]StoreHouse, 0, len(storehouses))
 	for _, storehouse := range storehouses {
 		slice = append(slice, storehouse)
 	}
 
 	slices.SortFunc(slice, func(a, b StoreHouse) int {
-		return cmp.Compare(getDistance(a.Location, from), getDistance(b.Location, from))
+		return cmp.Compare(distances.DistanceKm(a, from), distances.DistanceKm(b, from))
 	})
 
 	return slice
 }
 
-func distribute(entries []ReserveEntry, sortedStorehouses []StoreHouse) (distributed []ReserveEntry, err error) {
-	for _, entry := range entries {
-		// using greedy algorithm for each entry: just take all required items from the nearest left storehouse
-		// till either it's enough items or no storehouses left
-
-		for i, storehouse := range sortedStorehouses {
-			if itemData, ok := storehouse.ItemsData[entry.ItemID]; ok {
-				if itemData.Count >= entry.Count {
-					itemData.Count -= entry.Count
-					sortedStorehouses[i].ItemsData[entry.ItemID] = itemData
-
-					distributed = append(distributed, ReserveEntry{
-						ItemID:             entry.ItemID,
-						Count:              entry.Count,
-						SourceStorehouseID: storehouse.ID,
-					})
-
-					entry.Count = 0
-				} else {
-					entry.Count -= itemData.Count
-
-					distributed = append(distributed, ReserveEntry{
-						ItemID:             entry.ItemID,
-						Count:              itemData.Count,
-						SourceStorehouseID: storehouse.ID,
-					})
-
-					itemData.Count = 0
-					sortedStorehouses[i].ItemsData[entry.ItemID] = itemData
-				}
-			}
+// Release removes the given parts from the reservation. Entries left with zero count are deleted.
+// Storehouses and items are used to find the most expensive entries when storehouse is not given
+func (reservation *Reservation) Release(
+	toRelease []ReleaseEntry, storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item, costs CostModel) error {
 
-			if entry.Count == 0 {
-				break
-			}
-		}
-
-		if entry.Count > 0 {
-			err = errors.Join(err,
-				fmt.Errorf("%w, item: %s", ErrNotEnoughItemsInAllStorehouses, entry.ItemID))
+	for _, releaseEntry := range toRelease {
+		err := reservation.releaseEntry(releaseEntry, storehouses, items, costs)
+		if err != nil {
+			return err
 		}
 	}
 
-	return distributed, err
+	// get rid of all reservation items that has count == 0
+	reservation.Entries = slices.DeleteFunc(reservation.Entries, func(entry ReserveEntry) bool {
+		return entry.Count == 0
+	})
+
+	return nil
 }
 
-func (reservation *Reservation) Release(items []ReserveEntry) error {
-	// TODO: handle cases when storehouse id is not provided
+func (reservation *Reservation) releaseEntry(
+	releaseEntry ReleaseEntry, storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item, costs CostModel) error {
 
-	type key struct {
-		ItemID       ItemID
-		StorehouseID StoreHouseID
+	if releaseEntry.ItemID == "" && (releaseEntry.SourceStorehouseID.IsEmpty() || releaseEntry.Count != 0) {
+		return fmt.Errorf("%w: either item or only storehouse must be set, element: %+v", ErrInvalidReleaseItems, releaseEntry)
 	}
 
-	toRelease := make(map[key]ReserveEntry)
-	for _, item := range items {
-		toRelease[key{ItemID: item.ItemID, StorehouseID: item.SourceStorehouseID}] = item
+	if releaseEntry.Count < 0 {
+		return fmt.Errorf("%w: count must not be negative, element: %+v", ErrInvalidReleaseItems, releaseEntry)
 	}
 
+	matched := make([]int, 0)
+	available := 0
 	for i, entry := range reservation.Entries {
-		itemKey := key{ItemID: entry.ItemID, StorehouseID: entry.SourceStorehouseID}
-		itemToRelease, ok := toRelease[itemKey]
+		if entry.Count > 0 && releaseEntry.matches(entry) {
+			matched = append(matched, i)
+			available += entry.Count
+		}
+	}
+
+	if len(matched) == 0 {
+		return fmt.Errorf("%w, element: %+v", ErrInvalidReleaseItems, releaseEntry)
+	}
+
+	toRelease := releaseEntry.Count
+	if toRelease == 0 {
+		toRelease = available
+	}
+
+	if available < toRelease {
+		return fmt.Errorf("%w: expected at least %d, got %d", ErrNotEnoughItemsInReservation, toRelease, available)
+	}
+
+	// units are released from the most expensive storehouses first
+	unitCosts := make(map[int]float64, len(matched))
+	for _, i := range matched {
+		entry := reservation.Entries[i]
+		storehouse, ok := storehouses[entry.SourceStorehouseID]
 		if !ok {
-			// this item is not in release list
-			continue
+			return fmt.Errorf("%w: %s", ErrUnknownStorehouse, entry.SourceStorehouseID)
 		}
 
-		if entry.Count < itemToRelease.Count {
-			return fmt.Errorf("%w: expected at least %d, got %d", ErrNotEnoughItemsInReservation, itemToRelease.Count, entry.Count)
+		item, ok := items[entry.ItemID]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownItem, entry.ItemID)
 		}
 
-		reservation.Entries[i].Count -= itemToRelease.Count
-		delete(toRelease, itemKey)
+		unitCosts[i] = costs.UnitCost(storehouse, reservation.DestinationLocation, item)
 	}
 
-	if len(toRelease) > 0 {
-		return fmt.Errorf("%w, elements: %v", ErrInvalidReleaseItems, toRelease)
+	slices.SortStableFunc(matched, func(a, b int) int {
+		return cmp.Compare(unitCosts[b], unitCosts[a])
+	})
+
+	for _, i := range matched {
+		released := min(reservation.Entries[i].Count, toRelease)
+		reservation.Entries[i].Count -= released
+		toRelease -= released
 	}
 
-	// get rid of all reservation items that has count == 0
-	i := 0
-	for i < len(reservation.Entries) {
-		if reservation.Entries[i].Count == 0 {
-			reservation.Entries = append(reservation.Entries[:i], reservation.Entries[:i+1]...)
-		} else {
-			i++
+	return nil
+}
+
+// Reallocate returns the reservation with the same items placed anew by the allocate function.
+// Manual entries stay in their storehouses, the rest is placed around them as in a new reservation.
+// Storehouses must contain items of the reservation as if they were not reserved.
+// The dispatch window of the original request is not kept, only storehouses which are not open are skipped
+func (reservation *Reservation) Reallocate(storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item,
+	costs CostModel, allocate AllocateFunc) (Reservation, error) {
+
+	manual := Reservation{Entries: make([]ReserveEntry, 0)}
+	openedStorehouses := make(map[StoreHouseID][]ReserveEntry)
+	entries := make([]ReserveEntry, 0, len(reservation.Entries))
+	for _, entry := range reservation.Entries {
+		if entry.Manual {
+			manual.Entries = append(manual.Entries, entry)
+			openedStorehouses[entry.SourceStorehouseID] = append(openedStorehouses[entry.SourceStorehouseID], entry)
+			continue
 		}
+
+		entries = append(entries, ReserveEntry{ItemID: entry.ItemID, Count: entry.Count})
 	}
 
-	return nil
+	leftStorehouses, err := manual.GetUpdatedStorehouses(storehouses, Reserve, items)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	allocation, err := allocate(AllocationInput{
+		Destination:       reservation.DestinationLocation,
+		Entries:           entries,
+		Storehouses:       dispatchingStorehouses(leftStorehouses, nil),
+		Items:             items,
+		Costs:             costs,
+		OpenedStorehouses: openedStorehouses,
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	allEntries := append(manual.Entries, allocation.Entries...)
+
+	err = checkVehicles(allEntries, storehouses, items)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	reallocated := *reservation
+	reallocated.Entries = allEntries
+	reallocated.Approximate = allocation.Approximate
+	reallocated.Gap = allocation.Gap
+
+	return reallocated, nil
 }
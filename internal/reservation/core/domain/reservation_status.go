@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrUnknownReservationStatus = errors.New("unknown reservation status")
+	ErrInvalidStatusTransition  = errors.New("invalid reservation status transition")
+	ErrReservationNotModifiable = errors.New("reservation can't be modified in its status")
+)
+
+type ReservationStatus string
+
+const (
+	// Held reservation keeps items for the client and may expire
+	Held ReservationStatus = "held"
+	// Confirmed reservation is a committed order
+	Confirmed ReservationStatus = "confirmed"
+	// Picked reservation is collected in storehouses
+	Picked ReservationStatus = "picked"
+	// Shipped reservation has left storehouses, its items are consumed
+	Shipped ReservationStatus = "shipped"
+	// Cancelled reservation has returned its items to storehouses
+	Cancelled ReservationStatus = "cancelled"
+)
+
+var statusTransitions = map[ReservationStatus][]ReservationStatus{
+	Held:      {Confirmed, Cancelled},
+	Confirmed: {Picked, Cancelled},
+	Picked:    {Shipped, Cancelled},
+	Shipped:   {},
+	Cancelled: {},
+}
+
+// TransitTo changes the status of the reservation if the transition is allowed.
+// Confirmed reservation doesn't expire anymore
+func (reservation *Reservation) TransitTo(status ReservationStatus) error {
+	if _, ok := statusTransitions[status]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownReservationStatus, status)
+	}
+
+	if !slices.Contains(statusTransitions[reservation.Status], status) {
+		return fmt.Errorf("%w: from %s to %s", ErrInvalidStatusTransition, reservation.Status, status)
+	}
+
+	reservation.Status = status
+	if status == Confirmed {
+		reservation.ExpiresAt = nil
+	}
+
+	return nil
+}
+
+// IsOpen reports whether the reservation still keeps items in storehouses
+func (reservation *Reservation) IsOpen() bool {
+	return reservation.Status == Held || reservation.Status == Confirmed || reservation.Status == Picked
+}
+
+// IsModifiable reports whether items of the reservation may still be released.
+// Picked items are already collected, so they can only be shipped or cancelled as a whole
+func (reservation *Reservation) IsModifiable() bool {
+	return reservation.Status == Held || reservation.Status == Confirmed
+}
+
+// IsExpired reports whether the reservation is still held when its hold time is over
+func (reservation *Reservation) IsExpired(now time.Time) bool {
+	return reservation.Status == Held && reservation.ExpiresAt != nil && !reservation.ExpiresAt.After(now)
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservation_TransitTo(t *testing.T) {
+	expiresAt := time.Now()
+	reservation := Reservation{Status: Held, ExpiresAt: &expiresAt}
+
+	assert.ErrorIs(t, reservation.TransitTo(Shipped), ErrInvalidStatusTransition)
+	assert.ErrorIs(t, reservation.TransitTo("lost"), ErrUnknownReservationStatus)
+
+	assert.NoError(t, reservation.TransitTo(Confirmed))
+	assert.Nil(t, reservation.ExpiresAt)
+	assert.True(t, reservation.IsModifiable())
+
+	assert.NoError(t, reservation.TransitTo(Picked))
+	assert.False(t, reservation.IsModifiable())
+
+	assert.NoError(t, reservation.TransitTo(Shipped))
+	assert.ErrorIs(t, reservation.TransitTo(Cancelled), ErrInvalidStatusTransition)
+}
@@ -26,7 +26,7 @@ func TestReservation_GetTotalCost(t *testing.T) {
 		ReserveEntry{ItemID: "2", Count: 0, SourceStorehouseID: "AAA"}, // unknown storehouse
 	)
 
-	cost, err := reservation.GetTotalCost(storehouses, items)
+	cost, err := reservation.GetTotalCost(storehouses, items, DefaultCostModel())
 	if !assert.Error(t, err) {
 		t.FailNow()
 	}
@@ -48,7 +48,7 @@ func TestNewReservationFromReserveRequest(t *testing.T) {
 		ItemsToReserve:      itemsToReserve,
 	}
 
-	reservation, err := NewReservationFromReserveRequest(request, storehouses)
+	reservation, err := NewReservationFromReserveRequest(request, storehouses, getItems(), DefaultCostModel(), CostOptimalStrategy{}.Allocate)
 
 	expectedErr := errors.Join(
 		fmt.Errorf("%w: storehouse id: %s, item id: %d", ErrNotEnoughItemsInStorehouse, "a", 1),
@@ -63,6 +63,96 @@ func TestNewReservationFromReserveRequest(t *testing.T) {
 	assert.EqualValues(t, expectedEntries, reservation.Entries)
 }
 
+func TestReservation_Release(t *testing.T) {
+	_, storehouses := getReservedItemsAndStorehouses()
+	items := getItems()
+
+	reservation := Reservation{DestinationLocation: Location{40, 40}, Entries: getExpectedEntries()}
+
+	err := reservation.Release([]ReleaseEntry{
+		{ItemID: "5", Count: 1, SourceStorehouseID: "a"}, // item and storehouse
+		{ItemID: "8", Count: 3},                          // item only, "b" is further so it goes first
+	}, storehouses, items, DefaultCostModel())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expectedEntries := []ReserveEntry{
+		{ItemID: "3", Count: 5, SourceStorehouseID: "a", Manual: true},
+		{ItemID: "5", Count: 1, SourceStorehouseID: "b"},
+		{ItemID: "6", Count: 5, SourceStorehouseID: "a"},
+		{ItemID: "7", Count: 5, SourceStorehouseID: "b"},
+		{ItemID: "8", Count: 2, SourceStorehouseID: "a"},
+	}
+	assert.EqualValues(t, expectedEntries, reservation.Entries)
+
+	err = reservation.Release([]ReleaseEntry{{SourceStorehouseID: "b"}}, storehouses, items, DefaultCostModel()) // storehouse only
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expectedEntries = []ReserveEntry{
+		{ItemID: "3", Count: 5, SourceStorehouseID: "a", Manual: true},
+		{ItemID: "6", Count: 5, SourceStorehouseID: "a"},
+		{ItemID: "8", Count: 2, SourceStorehouseID: "a"},
+	}
+	assert.EqualValues(t, expectedEntries, reservation.Entries)
+
+	err = reservation.Release([]ReleaseEntry{{ItemID: "3", Count: 6, SourceStorehouseID: "a"}}, storehouses, items, DefaultCostModel())
+	assert.True(t, errors.Is(err, ErrNotEnoughItemsInReservation))
+
+	err = reservation.Release([]ReleaseEntry{{ItemID: "7"}}, storehouses, items, DefaultCostModel())
+	assert.True(t, errors.Is(err, ErrInvalidReleaseItems))
+
+	err = reservation.Release([]ReleaseEntry{{Count: 1}}, storehouses, items, DefaultCostModel())
+	assert.True(t, errors.Is(err, ErrInvalidReleaseItems))
+}
+
+func TestReservation_Reallocate(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+
+	reservation := Reservation{
+		ID:                  "id",
+		DestinationLocation: Location{50, 50},
+		Entries: []ReserveEntry{
+			{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+			{ItemID: "1", Count: 3, SourceStorehouseID: "b"},
+			{ItemID: "1", Count: 3, SourceStorehouseID: "c"},
+		},
+	}
+
+	reallocated, err := reservation.Reallocate(storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expectedEntries := []ReserveEntry{
+		{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 6, SourceStorehouseID: "c"},
+	}
+	assert.EqualValues(t, expectedEntries, reallocated.Entries)
+	assert.Equal(t, reservation.ID, reallocated.ID)
+	assert.Equal(t, reservation.DestinationLocation, reallocated.DestinationLocation)
+
+	// the source reservation is not changed
+	assert.Len(t, reservation.Entries, 3)
+
+	// the manual entry stays in "b", the rest is placed around it
+	reservation.Entries[1].Manual = true
+
+	reallocated, err = reservation.Reallocate(storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expectedEntries = []ReserveEntry{
+		{ItemID: "1", Count: 3, SourceStorehouseID: "b", Manual: true},
+		{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 3, SourceStorehouseID: "c"},
+	}
+	assert.EqualValues(t, expectedEntries, reallocated.Entries)
+}
+
 func getReservedItemsAndStorehouses() ([]ReserveEntry, map[StoreHouseID]StoreHouse) {
 	items := []ReserveEntry{
 		{ItemID: "1", Count: 5, SourceStorehouseID: "a"}, // Storehouse filled, it's not enough items
@@ -83,16 +173,16 @@ func getReservedItemsAndStorehouses() ([]ReserveEntry, map[StoreHouseID]StoreHou
 
 	storehouses := map[StoreHouseID]StoreHouse{
 		"a": {ID: "a", Name: "a", Location: Location{Latitude: 50, Longitude: 50}, ItemsData: map[ItemID]ItemData{
-			"1": {Item: Item{ID: "1", Name: "1", Size: &sizes[0], WeightKilograms: 1}, Count: 2},
-			"3": {Item: Item{ID: "3", Name: "3", Size: &sizes[2], WeightKilograms: 3}, Count: 7},
-			"5": {Item: Item{ID: "5", Name: "5", Size: &sizes[4], WeightKilograms: 5}, Count: 1},
-			"6": {Item: Item{ID: "6", Name: "6", Size: &sizes[5], WeightKilograms: 6}, Count: 6},
-			"8": {Item: Item{ID: "8", Name: "8", Size: &sizes[7], WeightKilograms: 8}, Count: 3},
+			"1": {Item: Item{ID: "1", Name: "1", Size: &sizes[0], WeightKilograms: 1}, OnHand: 2, Available: 2},
+			"3": {Item: Item{ID: "3", Name: "3", Size: &sizes[2], WeightKilograms: 3}, OnHand: 7, Available: 7},
+			"5": {Item: Item{ID: "5", Name: "5", Size: &sizes[4], WeightKilograms: 5}, OnHand: 1, Available: 1},
+			"6": {Item: Item{ID: "6", Name: "6", Size: &sizes[5], WeightKilograms: 6}, OnHand: 6, Available: 6},
+			"8": {Item: Item{ID: "8", Name: "8", Size: &sizes[7], WeightKilograms: 8}, OnHand: 3, Available: 3},
 		}},
 		"b": {ID: "b", Name: "b", Location: Location{Latitude: 60, Longitude: 60}, ItemsData: map[ItemID]ItemData{
-			"5": {Item: Item{ID: "5", Name: "5", Size: &sizes[4], WeightKilograms: 5}, Count: 1},
-			"7": {Item: Item{ID: "7", Name: "7", Size: &sizes[6], WeightKilograms: 7}, Count: 6},
-			"8": {Item: Item{ID: "8", Name: "8", Size: &sizes[7], WeightKilograms: 8}, Count: 3},
+			"5": {Item: Item{ID: "5", Name: "5", Size: &sizes[4], WeightKilograms: 5}, OnHand: 1, Available: 1},
+			"7": {Item: Item{ID: "7", Name: "7", Size: &sizes[6], WeightKilograms: 7}, OnHand: 6, Available: 6},
+			"8": {Item: Item{ID: "8", Name: "8", Size: &sizes[7], WeightKilograms: 8}, OnHand: 3, Available: 3},
 		}},
 	}
 
@@ -125,7 +215,7 @@ func getItems() map[ItemID]Item {
 
 func getExpectedEntries() []ReserveEntry {
 	return []ReserveEntry{
-		{ItemID: "3", Count: 5, SourceStorehouseID: "a"},
+		{ItemID: "3", Count: 5, SourceStorehouseID: "a", Manual: true},
 		{ItemID: "5", Count: 1, SourceStorehouseID: "a"},
 		{ItemID: "5", Count: 1, SourceStorehouseID: "b"},
 		{ItemID: "6", Count: 5, SourceStorehouseID: "a"},
@@ -4,4 +4,6 @@ type ReserveEntry struct {
 	ItemID             ItemID       `json:"itemID" validate:"required"`
 	Count              int          `json:"count" validate:"required"`
 	SourceStorehouseID StoreHouseID `json:"sourceStorehouseID"`
+	// Manual is set by the service for entries the client placed in the storehouse. Reoptimization doesn't move them
+	Manual bool `json:"manual,omitempty"`
 }
@@ -1,6 +1,45 @@
 package domain
 
+import "time"
+
 type ReserveRequest struct {
 	DestinationLocation Location       `json:"destinationLocation"`
 	ItemsToReserve      []ReserveEntry `json:"itemsToReserve"`
+	// Strategy is the name of the allocation strategy. If empty, DefaultAllocationStrategy is used
+	Strategy AllocationStrategyName `json:"strategy,omitempty"`
+	// HoldFor limits the lifetime of the reservation. If empty, the reservation is held until released
+	HoldFor Duration `json:"holdFor,omitempty" swaggertype:"string" example:"15m" validate:"min=0"`
+	// Explain adds to the response why each storehouse was picked or rejected
+	Explain bool `json:"explain,omitempty"`
+	// DispatchWindow skips storehouses which don't work within it. If empty, only storehouses which are not open are skipped
+	DispatchWindow *DispatchWindow `json:"dispatchWindow,omitempty"`
+}
+
+// WithDispatchStart returns the request which dispatch window starts at now if its start is not given
+func (request ReserveRequest) WithDispatchStart(now time.Time) ReserveRequest {
+	if request.DispatchWindow == nil || !request.DispatchWindow.From.IsZero() {
+		return request
+	}
+
+	window := *request.DispatchWindow
+	window.From = now
+	request.DispatchWindow = &window
+
+	return request
+}
+
+// GetExpiresAt returns the moment when the reservation made at now expires, or nil if it's held until released
+func (request ReserveRequest) GetExpiresAt(now time.Time) *time.Time {
+	if request.HoldFor <= 0 {
+		return nil
+	}
+
+	expiresAt := now.Add(time.Duration(request.HoldFor))
+
+	return &expiresAt
+}
+
+// ItemIDs returns IDs of all items to reserve
+func (request ReserveRequest) ItemIDs() []ItemID {
+	return entriesItemIDs(request.ItemsToReserve)
 }
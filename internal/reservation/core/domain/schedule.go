@@ -0,0 +1,179 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrStorehouseNotOpen        = errors.New("storehouse is not open")
+	ErrStorehouseCannotDispatch = errors.New("storehouse can't dispatch within the window")
+	ErrUnknownStorehouseStatus  = errors.New("unknown storehouse status")
+	ErrInvalidSchedule          = errors.New("invalid storehouse schedule")
+	ErrInvalidDispatchWindow    = errors.New("invalid dispatch window")
+	ErrInvalidClockTime         = errors.New("invalid clock time")
+)
+
+const (
+	clockTimeLayout       = "15:04"
+	holidayLayout         = "2006-01-02"
+	endOfDay              = ClockTime(24 * 60)
+	maxDispatchWindowDays = 366
+)
+
+type StorehouseStatus string
+
+const (
+	StorehouseOpen   StorehouseStatus = "open"
+	StorehouseClosed StorehouseStatus = "closed"
+	// StorehouseMaintenance keeps the stock and open reservations, but new reservations don't use the storehouse
+	StorehouseMaintenance StorehouseStatus = "maintenance"
+)
+
+func (status StorehouseStatus) Validate() error {
+	switch status {
+	case StorehouseOpen, StorehouseClosed, StorehouseMaintenance:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownStorehouseStatus, status)
+	}
+}
+
+// ClockTime is the number of minutes since midnight written in JSON as "HH:MM". "24:00" is the end of the day
+type ClockTime int
+
+func (clock ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%02d:%02d", clock/60, clock%60))
+}
+
+func (clock *ClockTime) UnmarshalJSON(data []byte) error {
+	var str string
+	err := json.Unmarshal(data, &str)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidClockTime, string(data))
+	}
+
+	if str == "24:00" {
+		*clock = endOfDay
+		return nil
+	}
+
+	parsed, err := time.Parse(clockTimeLayout, str)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidClockTime, str)
+	}
+
+	*clock = ClockTime(parsed.Hour()*60 + parsed.Minute())
+
+	return nil
+}
+
+// OpeningHours is a working interval of one day of the week in the time zone of the schedule
+type OpeningHours struct {
+	Weekday time.Weekday `json:"weekday" swaggertype:"integer" example:"1" validate:"min=0,max=6"`
+	Opens   ClockTime    `json:"opens" swaggertype:"string" example:"09:00"`
+	Closes  ClockTime    `json:"closes" swaggertype:"string" example:"18:00"`
+}
+
+// Schedule tells when the storehouse dispatches shipments. Empty hours mean it works around the clock,
+// holidays are dates in the time zone of the schedule when it doesn't work at all
+type Schedule struct {
+	// TimeZone is an IANA name, empty means UTC
+	TimeZone string         `json:"timeZone,omitempty" example:"Europe/Moscow"`
+	Hours    []OpeningHours `json:"hours,omitempty" validate:"dive"`
+	Holidays []string       `json:"holidays,omitempty" example:"2024-01-01" validate:"dive,datetime=2006-01-02"`
+}
+
+func (schedule Schedule) Validate() error {
+	_, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		return fmt.Errorf("%w: unknown time zone: %s", ErrInvalidSchedule, schedule.TimeZone)
+	}
+
+	for _, hours := range schedule.Hours {
+		if hours.Weekday < time.Sunday || hours.Weekday > time.Saturday || hours.Opens < 0 || hours.Closes > endOfDay ||
+			hours.Opens >= hours.Closes {
+			return fmt.Errorf("%w: hours must be within one day and open before closing: %+v", ErrInvalidSchedule, hours)
+		}
+	}
+
+	for _, holiday := range schedule.Holidays {
+		_, err = time.Parse(holidayLayout, holiday)
+		if err != nil {
+			return fmt.Errorf("%w: holiday must be a date like 2006-01-02: %s", ErrInvalidSchedule, holiday)
+		}
+	}
+
+	return nil
+}
+
+// CanDispatch reports whether the storehouse works at any moment of the window.
+// Only the first maxDispatchWindowDays days of the window are checked
+func (schedule Schedule) CanDispatch(window DispatchWindow) bool {
+	location, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	from := window.From.In(location)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location)
+	for i := 0; i < maxDispatchWindowDays && day.Before(window.To); i++ {
+		if !schedule.isHoliday(day) {
+			for _, hours := range schedule.hoursOf(day.Weekday()) {
+				// time.Date normalizes minutes, so the hours keep the wall clock on days when the offset changes
+				opens := time.Date(day.Year(), day.Month(), day.Day(), 0, int(hours.Opens), 0, 0, location)
+				closes := time.Date(day.Year(), day.Month(), day.Day(), 0, int(hours.Closes), 0, 0, location)
+				if opens.Before(window.To) && closes.After(window.From) {
+					return true
+				}
+			}
+		}
+
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return false
+}
+
+func (schedule Schedule) hoursOf(weekday time.Weekday) []OpeningHours {
+	if len(schedule.Hours) == 0 {
+		return []OpeningHours{{Weekday: weekday, Opens: 0, Closes: endOfDay}}
+	}
+
+	hours := make([]OpeningHours, 0)
+	for _, candidate := range schedule.Hours {
+		if candidate.Weekday == weekday {
+			hours = append(hours, candidate)
+		}
+	}
+
+	return hours
+}
+
+func (schedule Schedule) isHoliday(day time.Time) bool {
+	date := day.Format(holidayLayout)
+	for _, holiday := range schedule.Holidays {
+		if holiday == date {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DispatchWindow is the time range when shipments of the reservation must leave storehouses
+type DispatchWindow struct {
+	// From is the moment of the request if empty
+	From time.Time `json:"from,omitempty"`
+	To   time.Time `json:"to" validate:"required"`
+}
+
+func (window DispatchWindow) Validate() error {
+	if !window.To.After(window.From) {
+		return fmt.Errorf("%w: window must end after it starts", ErrInvalidDispatchWindow)
+	}
+
+	return nil
+}
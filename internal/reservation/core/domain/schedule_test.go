@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule_CanDispatch(t *testing.T) {
+	var schedule Schedule
+	err := json.Unmarshal([]byte(`{
+		"timeZone": "Europe/Moscow",
+		"hours": [{"weekday": 1, "opens": "09:00", "closes": "18:00"}, {"weekday": 2, "opens": "09:00", "closes": "24:00"}],
+		"holidays": ["2024-01-08"]
+	}`), &schedule)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, schedule.Validate())
+
+	// 2024-01-15 is Monday, 09:00 in Moscow is 06:00 UTC
+	monday := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		window   DispatchWindow
+		expected bool
+	}{
+		{"before opening", DispatchWindow{From: monday, To: monday.Add(6 * time.Hour)}, false},
+		{"overlaps opening", DispatchWindow{From: monday, To: monday.Add(6*time.Hour + time.Minute)}, true},
+		{"after closing till tuesday", DispatchWindow{From: monday.Add(15 * time.Hour), To: monday.Add(30 * time.Hour)}, false},
+		{"weekend", DispatchWindow{From: monday.AddDate(0, 0, -2), To: monday}, false},
+		{"holiday", DispatchWindow{From: monday.AddDate(0, 0, -7), To: monday.AddDate(0, 0, -7).Add(20 * time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, schedule.CanDispatch(tt.window))
+		})
+	}
+
+	assert.True(t, Schedule{}.CanDispatch(DispatchWindow{From: monday, To: monday.Add(time.Minute)}))
+
+	schedule.Hours[0].Closes = schedule.Hours[0].Opens
+	assert.ErrorIs(t, schedule.Validate(), ErrInvalidSchedule)
+	assert.ErrorIs(t, Schedule{TimeZone: "Mars/Olympus"}.Validate(), ErrInvalidSchedule)
+
+	var clock ClockTime
+	assert.ErrorIs(t, json.Unmarshal([]byte(`"25:00"`), &clock), ErrInvalidClockTime)
+}
+
+func TestNewReservationFromReserveRequest_DispatchWindow(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+
+	// "a" works only on Sundays and "b" is closed, so the items are taken from "c"
+	a := storehouses["a"]
+	a.Schedule = Schedule{Hours: []OpeningHours{{Weekday: time.Sunday, Opens: 0, Closes: endOfDay}}}
+	storehouses["a"] = a
+
+	b := storehouses["b"]
+	b.Status = StorehouseClosed
+	storehouses["b"] = b
+
+	monday := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	request := ReserveRequest{
+		DestinationLocation: Location{50, 50},
+		ItemsToReserve:      []ReserveEntry{{ItemID: "1", Count: 5}},
+		DispatchWindow:      &DispatchWindow{From: monday, To: monday.Add(48 * time.Hour)},
+	}
+
+	reservation, err := NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.EqualValues(t, []ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "c"}}, reservation.Entries)
+
+	explanation := ExplainReservation(request, reservation, storehouses, items, DefaultCostModel())
+	reasons := make(map[StoreHouseID]CandidateReason)
+	for _, candidate := range explanation[0].Candidates {
+		reasons[candidate.StorehouseID] = candidate.Reason
+	}
+	assert.Equal(t, map[StoreHouseID]CandidateReason{
+		"a": OutsideDispatchWindowCandidate,
+		"b": NotOpenCandidate,
+		"c": PickedCandidate,
+	}, reasons)
+
+	request.ItemsToReserve = []ReserveEntry{{ItemID: "1", Count: 1, SourceStorehouseID: "a"}}
+	_, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	assert.ErrorIs(t, err, ErrStorehouseCannotDispatch)
+
+	// without the window only the status matters
+	request.DispatchWindow = nil
+	_, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	assert.NoError(t, err)
+}
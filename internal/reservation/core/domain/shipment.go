@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Shipment is a part of the reservation delivered from one storehouse
+type Shipment struct {
+	StorehouseID StoreHouseID   `json:"storehouseID"`
+	Entries      []ReserveEntry `json:"entries"`
+	// Vehicles is the number of vehicles needed by the vehicle profile of the storehouse
+	Vehicles int     `json:"vehicles"`
+	Cost     float64 `json:"cost"`
+}
+
+// GetShipments splits the reservation by storehouses and calculates transport cost of each part.
+// The storehouse cost is paid for every vehicle of the shipment. Shipments are ordered by storehouse ID
+func (reservation *Reservation) GetShipments(
+	storehouses map[StoreHouseID]StoreHouse, items map[ItemID]Item, costs CostModel) ([]Shipment, error) {
+
+	storehouseEntries := groupEntriesPerStorehouse(reservation.Entries)
+
+	shipments := make([]Shipment, 0, len(storehouseEntries))
+	var resultErr error
+	for storehouseID, entries := range storehouseEntries {
+		storehouse, ok := storehouses[storehouseID]
+		if !ok {
+			resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s", ErrUnknownStorehouse, storehouseID))
+			continue
+		}
+
+		vehicles := max(storehouse.Vehicle.CountVehicles(entries, items), 1)
+
+		cost := costs.StorehouseCost(storehouse, reservation.DestinationLocation) * float64(vehicles)
+		for _, entry := range entries {
+			item, ok := items[entry.ItemID]
+			if !ok {
+				resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s", ErrUnknownItem, entry.ItemID))
+				continue
+			}
+
+			cost += costs.UnitCost(storehouse, reservation.DestinationLocation, item) * float64(entry.Count)
+		}
+
+		shipments = append(shipments, Shipment{
+			StorehouseID: storehouseID,
+			Entries:      entries,
+			Vehicles:     vehicles,
+			Cost:         cost,
+		})
+	}
+
+	slices.SortFunc(shipments, func(a, b Shipment) int {
+		return cmp.Compare(a.StorehouseID, b.StorehouseID)
+	})
+
+	return shipments, resultErr
+}
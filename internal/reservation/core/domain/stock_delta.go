@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+)
+
+// StockDelta is a change of the item stock in the storehouse: Count changes the available units
+// and Reserved changes the reserved ones. The units on hand change by their sum
+type StockDelta struct {
+	StorehouseID StoreHouseID
+	ItemID       ItemID
+	Count        int
+	Reserved     int
+}
+
+// OnHand returns the change of units physically kept in the storehouse
+func (delta StockDelta) OnHand() int {
+	return delta.Count + delta.Reserved
+}
+
+// GetStockDeltas returns changes of storehouses stock made by the operation over the reservation.
+// Reserved units stay on hand until they are shipped
+func (reservation *Reservation) GetStockDeltas(op OperationType) []StockDelta {
+	available, reserved := 1, -1
+	switch op {
+	case Reserve:
+		available, reserved = -1, 1
+	case Ship:
+		available, reserved = 0, -1
+	}
+
+	deltas := make([]StockDelta, 0, len(reservation.Entries))
+	for _, entry := range reservation.Entries {
+		deltas = append(deltas, StockDelta{
+			StorehouseID: entry.SourceStorehouseID,
+			ItemID:       entry.ItemID,
+			Count:        available * entry.Count,
+			Reserved:     reserved * entry.Count,
+		})
+	}
+
+	return MergeStockDeltas(deltas)
+}
+
+// MergeStockDeltas sums deltas of the same storehouse and item and drops zero ones.
+// The result is sorted by storehouse and item, so concurrent writers touch rows in the same order
+func MergeStockDeltas(deltas ...[]StockDelta) []StockDelta {
+	merged := make([]StockDelta, 0)
+	for _, part := range deltas {
+		for _, delta := range part {
+			i := slices.IndexFunc(merged, func(other StockDelta) bool {
+				return other.StorehouseID == delta.StorehouseID && other.ItemID == delta.ItemID
+			})
+			if i == -1 {
+				merged = append(merged, delta)
+				continue
+			}
+
+			merged[i].Count += delta.Count
+			merged[i].Reserved += delta.Reserved
+		}
+	}
+
+	merged = slices.DeleteFunc(merged, func(delta StockDelta) bool {
+		return delta.Count == 0 && delta.Reserved == 0
+	})
+
+	slices.SortFunc(merged, func(a, b StockDelta) int {
+		if a.StorehouseID != b.StorehouseID {
+			return cmp.Compare(a.StorehouseID, b.StorehouseID)
+		}
+
+		return cmp.Compare(a.ItemID, b.ItemID)
+	})
+
+	return merged
+}
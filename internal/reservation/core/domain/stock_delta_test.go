@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeStockDeltas(t *testing.T) {
+	oldReservation := Reservation{Entries: []ReserveEntry{
+		{ItemID: "1", Count: 4, SourceStorehouseID: "c"},
+		{ItemID: "2", Count: 1, SourceStorehouseID: "c"},
+	}}
+	newReservation := Reservation{Entries: []ReserveEntry{
+		{ItemID: "1", Count: 3, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 1, SourceStorehouseID: "c"},
+		{ItemID: "2", Count: 1, SourceStorehouseID: "c"},
+	}}
+
+	deltas := MergeStockDeltas(oldReservation.GetStockDeltas(Release), newReservation.GetStockDeltas(Reserve))
+
+	expectedDeltas := []StockDelta{
+		{StorehouseID: "a", ItemID: "1", Count: -3, Reserved: 3},
+		{StorehouseID: "c", ItemID: "1", Count: 3, Reserved: -3},
+	}
+	assert.EqualValues(t, expectedDeltas, deltas)
+}
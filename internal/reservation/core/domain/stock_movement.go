@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownMovementType = errors.New("unknown stock movement type")
+	ErrInvalidReasonCode   = errors.New("reason code is not allowed for the stock movement type")
+	ErrInvalidQuantity     = errors.New("invalid stock movement quantity")
+)
+
+type MovementType string
+
+const (
+	// Receipt brings items to the storehouse
+	Receipt MovementType = "receipt"
+	// WriteOff removes items which can't be sold anymore
+	WriteOff MovementType = "write-off"
+	// Adjustment corrects the count in either direction after a count
+	Adjustment MovementType = "adjustment"
+	// ReserveMovement makes available items reserved
+	ReserveMovement MovementType = "reserve"
+	// ReleaseMovement makes reserved items available again
+	ReleaseMovement MovementType = "release"
+	// ShipMovement takes reserved items away for good
+	ShipMovement MovementType = "ship"
+	// TransferMovement moves items between storehouses when one of them is deactivated
+	TransferMovement MovementType = "transfer"
+)
+
+type ReasonCode string
+
+const (
+	SupplierDelivery ReasonCode = "supplier-delivery"
+	CustomerReturn   ReasonCode = "customer-return"
+	Damaged          ReasonCode = "damaged"
+	Lost             ReasonCode = "lost"
+	Expired          ReasonCode = "expired"
+	InventoryCount   ReasonCode = "inventory-count"
+	Correction       ReasonCode = "correction"
+)
+
+var movementReasons = map[MovementType][]ReasonCode{
+	Receipt:    {SupplierDelivery, CustomerReturn},
+	WriteOff:   {Damaged, Lost, Expired},
+	Adjustment: {InventoryCount, Correction},
+}
+
+// StockMovement is an entry of the append-only stock ledger. Sums of available and reserved deltas
+// of all movements up to a moment give the stock at that moment
+type StockMovement struct {
+	ID           string       `json:"id"`
+	Type         MovementType `json:"type"`
+	StorehouseID StoreHouseID `json:"storehouseID"`
+	ItemID       ItemID       `json:"itemID"`
+	// Quantity is positive for receipts and write-offs, adjustments use the sign for the direction.
+	// Movements made by reservations have the number of moved units
+	Quantity       int `json:"quantity"`
+	AvailableDelta int `json:"availableDelta"`
+	ReservedDelta  int `json:"reservedDelta"`
+	// ReservationID is set for movements made by reservations
+	ReservationID string     `json:"reservationID,omitempty"`
+	Reason        ReasonCode `json:"reason,omitempty"`
+	Actor         string     `json:"actor,omitempty"`
+	Comment       string     `json:"comment,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// StockLevel is the stock of the item in a storehouse at some moment
+type StockLevel struct {
+	ItemID    ItemID `json:"itemID"`
+	OnHand    int    `json:"onHand"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+}
+
+func NewStockMovement(movementType MovementType, storehouseID StoreHouseID, itemID ItemID, quantity int,
+	reason ReasonCode, actor, comment string, now time.Time) (StockMovement, error) {
+
+	movement := StockMovement{
+		ID:           uuid.New().String(),
+		Type:         movementType,
+		StorehouseID: storehouseID,
+		ItemID:       itemID,
+		Quantity:     quantity,
+		Reason:       reason,
+		Actor:        actor,
+		Comment:      comment,
+		CreatedAt:    now,
+	}
+
+	err := movement.Validate()
+	if err != nil {
+		return StockMovement{}, err
+	}
+
+	movement.AvailableDelta = movement.GetStockDelta().Count
+
+	return movement, nil
+}
+
+func (movement StockMovement) Validate() error {
+	reasons, ok := movementReasons[movement.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownMovementType, movement.Type)
+	}
+
+	var resultErr error
+	if !slices.Contains(reasons, movement.Reason) {
+		resultErr = errors.Join(resultErr, fmt.Errorf("%w: %s for %s", ErrInvalidReasonCode, movement.Reason, movement.Type))
+	}
+
+	if movement.Quantity == 0 || (movement.Type != Adjustment && movement.Quantity < 0) {
+		resultErr = errors.Join(resultErr, fmt.Errorf("%w: %d for %s", ErrInvalidQuantity, movement.Quantity, movement.Type))
+	}
+
+	return resultErr
+}
+
+// GetStockDelta returns the change of the stock made by the movement
+func (movement StockMovement) GetStockDelta() StockDelta {
+	count := movement.Quantity
+	if movement.Type == WriteOff {
+		count = -count
+	}
+
+	return StockDelta{StorehouseID: movement.StorehouseID, ItemID: movement.ItemID, Count: count}
+}
+
+// NewLedgerMovements records stock changes made by the reservation or, if reservationID is empty,
+// by moving stock between storehouses
+func NewLedgerMovements(movementType MovementType, reservationID string, deltas []StockDelta, now time.Time) []StockMovement {
+	movements := make([]StockMovement, 0, len(deltas))
+	for _, delta := range deltas {
+		movements = append(movements, StockMovement{
+			ID:             uuid.New().String(),
+			Type:           movementType,
+			StorehouseID:   delta.StorehouseID,
+			ItemID:         delta.ItemID,
+			Quantity:       max(abs(delta.Count), abs(delta.Reserved)),
+			AvailableDelta: delta.Count,
+			ReservedDelta:  delta.Reserved,
+			ReservationID:  reservationID,
+			CreatedAt:      now,
+		})
+	}
+
+	return movements
+}
+
+// ReservationMovements records changes made by reserving and releasing items of the reservation.
+// A reoptimized reservation may take items in one storehouse and return them in another at once
+func ReservationMovements(reservationID string, deltas []StockDelta, now time.Time) []StockMovement {
+	movements := make([]StockMovement, 0, len(deltas))
+	for _, delta := range deltas {
+		movementType := ReleaseMovement
+		if delta.Reserved > 0 {
+			movementType = ReserveMovement
+		}
+
+		movements = append(movements, NewLedgerMovements(movementType, reservationID, []StockDelta{delta}, now)...)
+	}
+
+	return movements
+}
+
+func abs(x int) int {
+	return max(x, -x)
+}
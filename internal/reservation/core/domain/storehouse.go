@@ -1,5 +1,18 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+	"maps"
+)
+
+var (
+	ErrStorehouseAlreadyExists       = errors.New("storehouse already exists")
+	ErrStorehouseDeactivated         = errors.New("storehouse is deactivated")
+	ErrStorehouseHasOpenReservations = errors.New("storehouse has open reservations")
+	ErrInvalidTransferPlan           = errors.New("invalid transfer plan")
+)
+
 type StoreHouseID string
 
 func (id StoreHouseID) IsEmpty() bool {
@@ -7,13 +20,65 @@ func (id StoreHouseID) IsEmpty() bool {
 }
 
 type StoreHouse struct {
-	ID        StoreHouseID
-	Name      string
-	Location  Location
-	ItemsData map[ItemID]ItemData
+	ID       StoreHouseID `json:"id" validate:"required"`
+	Name     string       `json:"name" validate:"required"`
+	Location Location     `json:"location"`
+	// Deactivated storehouse is kept for the history, but it's not used for new reservations
+	Deactivated bool `json:"deactivated"`
+	// Status is operational: only open storehouses are used for new reservations. Empty status is open
+	Status   StorehouseStatus `json:"status" enums:"open,closed,maintenance"`
+	Schedule Schedule         `json:"schedule"`
+	// Vehicle is nil if shipments from the storehouse are not limited
+	Vehicle   *VehicleProfile     `json:"vehicle,omitempty"`
+	ItemsData map[ItemID]ItemData `json:"-"`
 }
 
+// ItemData is the stock of the item in a storehouse. Reserved units are still on hand until shipped,
+// only available ones can be reserved: Available = OnHand - Reserved
 type ItemData struct {
-	Item  Item `json:"item"`
-	Count int  `json:"count"`
+	Item      Item `json:"item"`
+	OnHand    int  `json:"onHand"`
+	Reserved  int  `json:"reserved"`
+	Available int  `json:"available"`
+}
+
+// CloneStorehouses returns a copy of storehouses with their own items data maps
+func CloneStorehouses(storehouses map[StoreHouseID]StoreHouse) map[StoreHouseID]StoreHouse {
+	cloned := maps.Clone(storehouses)
+	for storehouseID, storehouse := range storehouses {
+		storehouse.ItemsData = maps.Clone(storehouse.ItemsData)
+		cloned[storehouseID] = storehouse
+	}
+
+	return cloned
+}
+
+// CheckDispatch returns an error if new reservations can't take items from the storehouse:
+// it's deactivated, not open or, if the window is given, doesn't work within it
+func (storehouse StoreHouse) CheckDispatch(window *DispatchWindow) error {
+	if storehouse.Deactivated {
+		return fmt.Errorf("%w: %s", ErrStorehouseDeactivated, storehouse.ID)
+	}
+
+	if storehouse.Status != "" && storehouse.Status != StorehouseOpen {
+		return fmt.Errorf("%w: %s is %s", ErrStorehouseNotOpen, storehouse.ID, storehouse.Status)
+	}
+
+	if window != nil && !storehouse.Schedule.CanDispatch(*window) {
+		return fmt.Errorf("%w: %s", ErrStorehouseCannotDispatch, storehouse.ID)
+	}
+
+	return nil
+}
+
+// dispatchingStorehouses returns storehouses which can be used by new reservations within the window
+func dispatchingStorehouses(storehouses map[StoreHouseID]StoreHouse, window *DispatchWindow) map[StoreHouseID]StoreHouse {
+	dispatching := make(map[StoreHouseID]StoreHouse, len(storehouses))
+	for id, storehouse := range storehouses {
+		if storehouse.CheckDispatch(window) == nil {
+			dispatching[id] = storehouse
+		}
+	}
+
+	return dispatching
 }
@@ -0,0 +1,77 @@
+package domain
+
+import "fmt"
+
+// TransferPlan tells where stock and open reservations of a deactivated storehouse are moved
+type TransferPlan struct {
+	TargetStorehouseID StoreHouseID `json:"targetStorehouseID" validate:"required"`
+}
+
+// Validate checks that the target can take over the source storehouse
+func (plan TransferPlan) Validate(source StoreHouse, storehouses map[StoreHouseID]StoreHouse) error {
+	if plan.TargetStorehouseID == source.ID {
+		return fmt.Errorf("%w: target is the deactivated storehouse %s", ErrInvalidTransferPlan, source.ID)
+	}
+
+	target, ok := storehouses[plan.TargetStorehouseID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownStorehouse, plan.TargetStorehouseID)
+	}
+
+	if target.Deactivated {
+		return fmt.Errorf("%w: target %s", ErrStorehouseDeactivated, target.ID)
+	}
+
+	return nil
+}
+
+// GetStockDeltas moves all items kept in the source storehouse to the target. Reserved units stay reserved,
+// because entries of open reservations are moved to the target too
+func (plan TransferPlan) GetStockDeltas(source StoreHouse) []StockDelta {
+	deltas := make([]StockDelta, 0, 2*len(source.ItemsData))
+	for itemID, itemData := range source.ItemsData {
+		if itemData.OnHand <= 0 {
+			continue
+		}
+
+		deltas = append(deltas,
+			StockDelta{StorehouseID: source.ID, ItemID: itemID, Count: -itemData.Available, Reserved: -itemData.Reserved},
+			StockDelta{StorehouseID: plan.TargetStorehouseID, ItemID: itemID, Count: itemData.Available, Reserved: itemData.Reserved},
+		)
+	}
+
+	return MergeStockDeltas(deltas)
+}
+
+// MoveEntries makes entries taken from the source storehouse be taken from the target one.
+// Entries of the same item are merged unless only one of them is manual: moved manual entries stay manual
+func (reservation *Reservation) MoveEntries(source, target StoreHouseID) {
+	type mergeKey struct {
+		itemID ItemID
+		manual bool
+	}
+
+	entries := make([]ReserveEntry, 0, len(reservation.Entries))
+	indexes := make(map[mergeKey]int)
+	for _, entry := range reservation.Entries {
+		if entry.SourceStorehouseID == source {
+			entry.SourceStorehouseID = target
+		}
+
+		if entry.SourceStorehouseID != target {
+			entries = append(entries, entry)
+			continue
+		}
+
+		key := mergeKey{itemID: entry.ItemID, manual: entry.Manual}
+		if i, ok := indexes[key]; ok {
+			entries[i].Count += entry.Count
+			continue
+		}
+
+		indexes[key] = len(entries)
+		entries = append(entries, entry)
+	}
+
+	reservation.Entries = entries
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservation_MoveEntries(t *testing.T) {
+	reservation := Reservation{Entries: []ReserveEntry{
+		{ItemID: "1", Count: 2, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 3, SourceStorehouseID: "b"},
+		{ItemID: "2", Count: 1, SourceStorehouseID: "a"},
+		{ItemID: "2", Count: 4, SourceStorehouseID: "c"},
+		{ItemID: "3", Count: 1, SourceStorehouseID: "a", Manual: true},
+		{ItemID: "3", Count: 2, SourceStorehouseID: "b"},
+	}}
+
+	reservation.MoveEntries("a", "b")
+
+	expectedEntries := []ReserveEntry{
+		{ItemID: "1", Count: 5, SourceStorehouseID: "b"},
+		{ItemID: "2", Count: 1, SourceStorehouseID: "b"},
+		{ItemID: "2", Count: 4, SourceStorehouseID: "c"},
+		// the manual entry is not merged, so it's still not moved by reoptimization
+		{ItemID: "3", Count: 1, SourceStorehouseID: "b", Manual: true},
+		{ItemID: "3", Count: 2, SourceStorehouseID: "b"},
+	}
+	assert.Equal(t, expectedEntries, reservation.Entries)
+}
+
+func TestTransferPlan_Validate(t *testing.T) {
+	storehouses := map[StoreHouseID]StoreHouse{
+		"a": {ID: "a"},
+		"b": {ID: "b"},
+		"c": {ID: "c", Deactivated: true},
+	}
+
+	assert.NoError(t, TransferPlan{TargetStorehouseID: "b"}.Validate(storehouses["a"], storehouses))
+	assert.ErrorIs(t, TransferPlan{TargetStorehouseID: "a"}.Validate(storehouses["a"], storehouses), ErrInvalidTransferPlan)
+	assert.ErrorIs(t, TransferPlan{TargetStorehouseID: "c"}.Validate(storehouses["a"], storehouses), ErrStorehouseDeactivated)
+	assert.ErrorIs(t, TransferPlan{TargetStorehouseID: "d"}.Validate(storehouses["a"], storehouses), ErrUnknownStorehouse)
+}
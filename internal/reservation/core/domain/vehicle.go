@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+var (
+	ErrItemDoesNotFitVehicle = errors.New("item does not fit the vehicle of the storehouse")
+	ErrNotEnoughVehicles     = errors.New("shipment needs more vehicles than the storehouse has")
+)
+
+// VehicleProfile limits one vehicle leaving the storehouse. A shipment which doesn't fit one vehicle
+// is split into several ones, each of them costs as one more storehouse.
+// MaxVehicles limits vehicles per shipment, zero means there is no limit
+type VehicleProfile struct {
+	MaxWeightKilograms float64 `json:"maxWeightKilograms" validate:"gt=0"`
+	MaxVolumeM3        float64 `json:"maxVolumeM3" validate:"gt=0"`
+	// MaxLengthMeters is the length of the cargo bay, an item longer than it in any dimension is never shipped
+	MaxLengthMeters float64 `json:"maxLengthMeters" validate:"gt=0"`
+	MaxVehicles     int     `json:"maxVehicles,omitempty" validate:"min=0"`
+}
+
+// CanCarry reports whether one unit of the item fits an empty vehicle. Nil profile carries everything
+func (profile *VehicleProfile) CanCarry(item Item) bool {
+	if profile == nil || item.Size == nil {
+		return true
+	}
+
+	longestSide := max(item.Size.LengthMeters, item.Size.WidthMeters, item.Size.HeightMeters)
+
+	return longestSide <= profile.MaxLengthMeters && item.WeightKilograms <= profile.MaxWeightKilograms &&
+		item.VolumeM2() <= profile.MaxVolumeM3
+}
+
+type vehicleLoad struct {
+	weight float64
+	volume float64
+}
+
+// loadedVehicles are vehicles with the same load
+type loadedVehicles struct {
+	load  vehicleLoad
+	count int
+}
+
+// CountVehicles returns the number of vehicles needed to carry the entries. Units are packed by weight and volume
+// with the first fit decreasing heuristic, so the result may be greater than the optimal one.
+// Units of one item are placed together: each vehicle takes as many of them as fit, and vehicles with the same load
+// are kept together, so the time depends on the number of entries, not units.
+// Units which don't fit any vehicle and unknown items are expected to be rejected before
+func (profile *VehicleProfile) CountVehicles(entries []ReserveEntry, items map[ItemID]Item) int {
+	units := make([]vehicleLoad, 0, len(entries))
+	counts := make([]int, 0, len(entries))
+	var total vehicleLoad
+	for _, entry := range entries {
+		item, ok := items[entry.ItemID]
+		if !ok || entry.Count <= 0 {
+			continue
+		}
+
+		unit := vehicleLoad{weight: item.WeightKilograms}
+		if item.Size != nil {
+			unit.volume = item.VolumeM2()
+		}
+
+		units = append(units, unit)
+		counts = append(counts, entry.Count)
+		total.weight += unit.weight * float64(entry.Count)
+		total.volume += unit.volume * float64(entry.Count)
+	}
+
+	if len(units) == 0 {
+		return 0
+	}
+
+	if profile == nil || profile.fits(total) {
+		return 1
+	}
+
+	order := make([]int, len(units))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(profile.share(units[b]), profile.share(units[a]))
+	})
+
+	vehicles := make([]loadedVehicles, 0)
+	for _, i := range order {
+		unit, left := units[i], counts[i]
+
+		// a unit placed into a vehicle is never placed into an earlier one, so vehicles are filled one by one
+		for j := 0; j < len(vehicles) && left > 0; j++ {
+			room := profile.room(vehicles[j].load, unit, left)
+			if room == 0 {
+				continue
+			}
+
+			filled := min(vehicles[j].count, left/room)
+			if filled > 0 {
+				vehicles = splitVehicles(vehicles, j, filled, vehicleLoad{
+					weight: vehicles[j].load.weight + unit.weight*float64(room),
+					volume: vehicles[j].load.volume + unit.volume*float64(room),
+				})
+				left -= filled * room
+				continue
+			}
+
+			// the rest fits one vehicle of the group
+			vehicles = splitVehicles(vehicles, j, 1, vehicleLoad{
+				weight: vehicles[j].load.weight + unit.weight*float64(left),
+				volume: vehicles[j].load.volume + unit.volume*float64(left),
+			})
+			left = 0
+		}
+
+		if left == 0 {
+			continue
+		}
+
+		// a unit which doesn't fit an empty vehicle still takes a whole one
+		perVehicle := max(profile.room(vehicleLoad{}, unit, left), 1)
+		if full := left / perVehicle; full > 0 {
+			vehicles = append(vehicles, loadedVehicles{
+				load:  vehicleLoad{weight: unit.weight * float64(perVehicle), volume: unit.volume * float64(perVehicle)},
+				count: full,
+			})
+		}
+
+		if rest := left % perVehicle; rest > 0 {
+			vehicles = append(vehicles, loadedVehicles{
+				load:  vehicleLoad{weight: unit.weight * float64(rest), volume: unit.volume * float64(rest)},
+				count: 1,
+			})
+		}
+	}
+
+	count := 0
+	for _, group := range vehicles {
+		count += group.count
+	}
+
+	return count
+}
+
+// splitVehicles gives the new load to the first count vehicles of the group at index i, keeping their order
+func splitVehicles(vehicles []loadedVehicles, i, count int, load vehicleLoad) []loadedVehicles {
+	if count == vehicles[i].count {
+		vehicles[i].load = load
+		return vehicles
+	}
+
+	vehicles[i].count -= count
+
+	return slices.Insert(vehicles, i, loadedVehicles{load: load, count: count})
+}
+
+// room returns how many units, but not more than limit, can be added to the load
+func (profile *VehicleProfile) room(load, unit vehicleLoad, limit int) int {
+	// the tolerance keeps units which exactly fill the vehicle from being lost to rounding
+	const tolerance = 1e-9
+
+	room := float64(limit)
+	if unit.weight > 0 {
+		room = min(room, math.Floor((profile.MaxWeightKilograms-load.weight)/unit.weight+tolerance))
+	}
+	if unit.volume > 0 {
+		room = min(room, math.Floor((profile.MaxVolumeM3-load.volume)/unit.volume+tolerance))
+	}
+
+	return int(max(room, 0))
+}
+
+// CheckShipment returns an error if an item doesn't fit the vehicle or the storehouse has not enough vehicles
+func (profile *VehicleProfile) CheckShipment(entries []ReserveEntry, items map[ItemID]Item) error {
+	if profile == nil {
+		return nil
+	}
+
+	for _, entry := range entries {
+		if item, ok := items[entry.ItemID]; ok && !profile.CanCarry(item) {
+			return fmt.Errorf("%w: item: %s", ErrItemDoesNotFitVehicle, entry.ItemID)
+		}
+	}
+
+	if !profile.hasEnoughVehicles(profile.CountVehicles(entries, items)) {
+		return fmt.Errorf("%w: max vehicles: %d", ErrNotEnoughVehicles, profile.MaxVehicles)
+	}
+
+	return nil
+}
+
+func (profile *VehicleProfile) hasEnoughVehicles(vehicles int) bool {
+	return profile == nil || profile.MaxVehicles == 0 || vehicles <= profile.MaxVehicles
+}
+
+func (profile *VehicleProfile) fits(load vehicleLoad) bool {
+	return load.weight <= profile.MaxWeightKilograms && load.volume <= profile.MaxVolumeM3
+}
+
+// share is the biggest part of the vehicle capacity the load takes
+func (profile *VehicleProfile) share(load vehicleLoad) float64 {
+	return max(load.weight/profile.MaxWeightKilograms, load.volume/profile.MaxVolumeM3)
+}
@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"cmp"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVehicleProfile(t *testing.T) {
+	profile := &VehicleProfile{MaxWeightKilograms: 25, MaxVolumeM3: 1, MaxLengthMeters: 1.5}
+
+	item := Item{ID: "1", Size: &Size{0.1, 0.1, 0.1}, WeightKilograms: 10}
+	long := Item{ID: "2", Size: &Size{0.1, 2, 0.1}, WeightKilograms: 1}
+	items := map[ItemID]Item{"1": item, "2": long}
+
+	assert.True(t, profile.CanCarry(item))
+	assert.False(t, profile.CanCarry(long))
+	assert.True(t, (*VehicleProfile)(nil).CanCarry(long))
+
+	// two units of 10 kg fit one vehicle
+	assert.Equal(t, 3, profile.CountVehicles([]ReserveEntry{{ItemID: "1", Count: 5}}, items))
+	assert.Equal(t, 1, profile.CountVehicles([]ReserveEntry{{ItemID: "1", Count: 2}}, items))
+	assert.Equal(t, 1, (*VehicleProfile)(nil).CountVehicles([]ReserveEntry{{ItemID: "1", Count: 5}}, items))
+
+	assert.ErrorIs(t, profile.CheckShipment([]ReserveEntry{{ItemID: "2", Count: 1}}, items), ErrItemDoesNotFitVehicle)
+
+	profile.MaxVehicles = 2
+	assert.ErrorIs(t, profile.CheckShipment([]ReserveEntry{{ItemID: "1", Count: 5}}, items), ErrNotEnoughVehicles)
+	assert.NoError(t, profile.CheckShipment([]ReserveEntry{{ItemID: "1", Count: 4}}, items))
+}
+
+func TestVehicleProfile_CountVehiclesMatchesUnitPacking(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		profile := &VehicleProfile{MaxWeightKilograms: 10 + random.Float64()*40, MaxVolumeM3: 0.5 + random.Float64(), MaxLengthMeters: 2}
+
+		items := make(map[ItemID]Item)
+		entries := make([]ReserveEntry, 0)
+		for j := 0; j < 1+random.Intn(4); j++ {
+			item := Item{
+				ID:              ItemID(strconv.Itoa(j)),
+				Size:            &Size{0.1 + random.Float64()*0.5, 0.1 + random.Float64()*0.5, 0.1 + random.Float64()*0.5},
+				WeightKilograms: 1 + random.Float64()*9,
+			}
+			items[item.ID] = item
+			entries = append(entries, ReserveEntry{ItemID: item.ID, Count: 1 + random.Intn(20)})
+		}
+
+		assert.Equal(t, countVehiclesByUnits(profile, entries, items), profile.CountVehicles(entries, items),
+			"case %d", i)
+	}
+
+	// the time doesn't depend on the number of units
+	profile := &VehicleProfile{MaxWeightKilograms: 25, MaxVolumeM3: 1, MaxLengthMeters: 1.5}
+	items := map[ItemID]Item{"1": {ID: "1", Size: &Size{0.1, 0.1, 0.1}, WeightKilograms: 10}}
+
+	started := time.Now()
+	assert.Equal(t, math.MaxInt32/2+1, profile.CountVehicles([]ReserveEntry{{ItemID: "1", Count: math.MaxInt32}}, items))
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+// countVehiclesByUnits is the first fit decreasing packing of every unit separately
+func countVehiclesByUnits(profile *VehicleProfile, entries []ReserveEntry, items map[ItemID]Item) int {
+	units := make([]vehicleLoad, 0)
+	for _, entry := range entries {
+		item := items[entry.ItemID]
+		for i := 0; i < entry.Count; i++ {
+			units = append(units, vehicleLoad{weight: item.WeightKilograms, volume: item.VolumeM2()})
+		}
+	}
+
+	slices.SortStableFunc(units, func(a, b vehicleLoad) int {
+		return cmp.Compare(profile.share(b), profile.share(a))
+	})
+
+	vehicles := make([]vehicleLoad, 0)
+	for _, unit := range units {
+		placed := false
+		for i, vehicle := range vehicles {
+			loaded := vehicleLoad{weight: vehicle.weight + unit.weight, volume: vehicle.volume + unit.volume}
+			if profile.fits(loaded) {
+				vehicles[i] = loaded
+				placed = true
+				break
+			}
+		}
+
+		if !placed {
+			vehicles = append(vehicles, unit)
+		}
+	}
+
+	return len(vehicles)
+}
+
+func TestNewReservationFromReserveRequest_Vehicles(t *testing.T) {
+	storehouses, items := getLineOfStorehouses()
+	request := ReserveRequest{
+		DestinationLocation: Location{50, 50},
+		ItemsToReserve:      []ReserveEntry{{ItemID: "1", Count: 10}},
+	}
+
+	// without vehicles the cheapest is 4 from "a" and 6 from "c", but 6 units need two vehicles of "c",
+	// so taking 3 units from "b" instead is cheaper
+	c := storehouses["c"]
+	c.Vehicle = &VehicleProfile{MaxWeightKilograms: 30, MaxVolumeM3: 1, MaxLengthMeters: 1}
+	storehouses["c"] = c
+
+	reservation, err := NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expectedEntries := []ReserveEntry{
+		{ItemID: "1", Count: 4, SourceStorehouseID: "a"},
+		{ItemID: "1", Count: 3, SourceStorehouseID: "b"},
+		{ItemID: "1", Count: 3, SourceStorehouseID: "c"},
+	}
+	assert.EqualValues(t, expectedEntries, reservation.Entries)
+
+	twoVehicles := Reservation{
+		DestinationLocation: request.DestinationLocation,
+		Entries:             []ReserveEntry{{ItemID: "1", Count: 6, SourceStorehouseID: "c"}},
+	}
+	shipments, err := twoVehicles.GetShipments(storehouses, items, DefaultCostModel())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 2, shipments[0].Vehicles)
+
+	// the only vehicle of "c" can't take the 4 units left
+	c.Vehicle.MaxVehicles = 1
+	request.ItemsToReserve[0].Count = 11
+
+	_, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	assert.ErrorIs(t, err, ErrNotEnoughVehicles)
+
+	// items longer than the cargo bay are never taken from the storehouse
+	c.Vehicle = &VehicleProfile{MaxWeightKilograms: 30, MaxVolumeM3: 1, MaxLengthMeters: 0.05}
+	storehouses["c"] = c
+	request.ItemsToReserve[0].Count = 7
+
+	reservation, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), NearestFirstStrategy{}.Allocate)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.EqualValues(t, expectedEntries[:2], reservation.Entries)
+
+	request.ItemsToReserve = []ReserveEntry{{ItemID: "2", Count: 1, SourceStorehouseID: "c"}}
+	_, err = NewReservationFromReserveRequest(request, storehouses, items, DefaultCostModel(), CostOptimalStrategy{}.Allocate)
+	assert.ErrorIs(t, err, ErrItemDoesNotFitVehicle)
+}
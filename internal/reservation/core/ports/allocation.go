@@ -0,0 +1,11 @@
+package ports
+
+import (
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+)
+
+// AllocationStrategy places reserve entries which have no source storehouse.
+// Strategies are selected per request by domain.ReserveRequest.Strategy
+type AllocationStrategy interface {
+	Allocate(input domain.AllocationInput) (domain.Allocation, error)
+}
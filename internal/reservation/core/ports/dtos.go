@@ -1,24 +1,201 @@
 package ports
 
 import (
+	"time"
+
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
 )
 
 type ReleaseRequestDTO struct {
 	ReservationID  string                `json:"reservationID" validate:"required"`
-	ItemsToRelease []domain.ReserveEntry `json:"itemsToRelease"`
+	ItemsToRelease []domain.ReleaseEntry `json:"itemsToRelease" validate:"dive"`
+	// Reoptimize allows to move the rest of the reservation to other storehouses if it lowers the total cost
+	Reoptimize bool `json:"reoptimize"`
 }
 
 type ReservationResponseDTO struct {
-	Reservation domain.Reservation `json:"reservation"`
-	TotalCost   float64            `json:"totalCost"`
+	Reservation    domain.Reservation `json:"reservation"`
+	TotalCost      float64            `json:"totalCost"`
+	Reoptimization *ReoptimizationDTO `json:"reoptimization,omitempty"`
+	// Explanation is filled only if it was requested
+	Explanation []domain.EntryExplanation `json:"explanation,omitempty"`
+}
+
+// QuoteResponseDTO is the allocation the reservation would get with the current stock
+type QuoteResponseDTO struct {
+	Entries   []domain.ReserveEntry `json:"entries"`
+	Shipments []domain.Shipment     `json:"shipments"`
+	TotalCost float64               `json:"totalCost"`
+	// Approximate is set if the strategy couldn't guarantee the best placement of the entries
+	Approximate bool `json:"approximate,omitempty"`
+	// Gap bounds how much cheaper the best placement may be than the approximate one
+	Gap float64 `json:"gap,omitempty"`
+	// Explanation is filled only if it was requested
+	Explanation []domain.EntryExplanation `json:"explanation,omitempty"`
+}
+
+type ReoptimizationDTO struct {
+	OldTotalCost float64 `json:"oldTotalCost"`
+	NewTotalCost float64 `json:"newTotalCost"`
+	Applied      bool    `json:"applied"`
+}
+
+type ReservationIDRequestDTO struct {
+	ReservationID string `uri:"id" validate:"required"`
+}
+
+// ListReservationsRequestDTO filters reservations. Empty filters are not applied,
+// storehouse and item filters match reservations having such an entry
+type ListReservationsRequestDTO struct {
+	StorehouseID domain.StoreHouseID `form:"storehouse-id"`
+	ItemID       domain.ItemID       `form:"item-id"`
+	// bounding box of the destination location, any of bounds may be omitted
+	MinLatitude  *float64 `form:"min-latitude" validate:"omitempty,min=-90,max=90"`
+	MaxLatitude  *float64 `form:"max-latitude" validate:"omitempty,min=-90,max=90"`
+	MinLongitude *float64 `form:"min-longitude" validate:"omitempty,min=-180,max=180"`
+	MaxLongitude *float64 `form:"max-longitude" validate:"omitempty,min=-180,max=180"`
+	// creation time range in RFC 3339, From is inclusive and To is exclusive
+	CreatedFrom *time.Time `form:"created-from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   *time.Time `form:"created-to" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Cursor is NextCursor of the previous page
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+type ListReservationsResponseDTO struct {
+	Reservations []domain.Reservation `json:"reservations"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type GetUnreservedRequestDTO struct {
 	StorehouseID domain.StoreHouseID `form:"storehouse-id" validate:"required"`
+	// ItemIDs filters the items, empty means all items
+	ItemIDs []domain.ItemID `form:"item-id" validate:"dive,required"`
+	Cursor  string          `form:"cursor"`
+	Limit   int             `form:"limit" validate:"omitempty,min=1,max=100"`
 }
 
 type GetUnreservedResponseDTO struct {
+	StorehouseID domain.StoreHouseID `json:"storehouseID"`
+	// Items are ordered by item ID
+	Items []domain.ItemData `json:"items"`
+	// NextCursor is passed to get the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type AvailabilityRequestDTO struct {
+	ItemIDs []domain.ItemID `form:"item-id" validate:"min=1,dive,required"`
+}
+
+type AvailabilityResponseDTO struct {
+	Items []ItemAvailabilityDTO `json:"items"`
+}
+
+// ItemAvailabilityDTO is the total number of available units of the item in active storehouses
+type ItemAvailabilityDTO struct {
+	ItemID      domain.ItemID               `json:"itemID"`
+	Available   int                         `json:"available"`
+	Storehouses []StorehouseAvailabilityDTO `json:"storehouses"`
+}
+
+type StorehouseAvailabilityDTO struct {
+	StorehouseID domain.StoreHouseID `json:"storehouseID"`
+	Available    int                 `json:"available"`
+}
+
+type CreateStorehouseRequestDTO struct {
+	ID       domain.StoreHouseID `json:"id" validate:"required"`
+	Name     string              `json:"name" validate:"required"`
+	Location domain.Location     `json:"location"`
+	// Schedule is omitted if the storehouse works around the clock
+	Schedule domain.Schedule `json:"schedule"`
+	// Vehicle is omitted if shipments from the storehouse are not limited
+	Vehicle *domain.VehicleProfile `json:"vehicle,omitempty"`
+}
+
+type StorehouseIDRequestDTO struct {
+	StorehouseID domain.StoreHouseID `uri:"id" validate:"required"`
+}
+
+type UpdateStorehouseRequestDTO struct {
+	StorehouseID domain.StoreHouseID `uri:"id" json:"-" validate:"required"`
+	Name         string              `json:"name" validate:"required"`
+	// Schedule replaces the schedule, omitted one means working around the clock
+	Schedule domain.Schedule `json:"schedule"`
+	// Vehicle replaces the vehicle profile, omitted one removes the limits
+	Vehicle *domain.VehicleProfile `json:"vehicle,omitempty"`
+}
+
+type RelocateStorehouseRequestDTO struct {
+	StorehouseID domain.StoreHouseID `uri:"id" json:"-" validate:"required"`
+	Location     domain.Location     `json:"location"`
+}
+
+type StorehouseStatusRequestDTO struct {
+	StorehouseID domain.StoreHouseID     `uri:"id" json:"-" validate:"required"`
+	Status       domain.StorehouseStatus `json:"status" enums:"open,closed,maintenance" validate:"required"`
+}
+
+type DeactivateStorehouseRequestDTO struct {
+	StorehouseID domain.StoreHouseID `uri:"id" json:"-" validate:"required"`
+	// TransferPlan is required if the storehouse has open reservations
+	TransferPlan *domain.TransferPlan `json:"transferPlan,omitempty"`
+}
+
+type DeactivationResponseDTO struct {
+	Storehouse domain.StoreHouse `json:"storehouse"`
+	// MovedReservationIDs are open reservations which entries were moved to the target storehouse
+	MovedReservationIDs []string `json:"movedReservationIDs"`
+}
+
+type ItemIDRequestDTO struct {
+	ItemID domain.ItemID `uri:"id" validate:"required"`
+}
+
+type UpdateItemRequestDTO struct {
+	ItemID          domain.ItemID `uri:"id" json:"-" validate:"required"`
+	Name            string        `json:"name" validate:"required"`
+	Size            *domain.Size  `json:"size" validate:"required"`
+	WeightKilograms float64       `json:"weightKilograms" validate:"gt=0"`
+}
+
+type SearchItemsRequestDTO struct {
+	Query  string `form:"query"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+type SearchItemsResponseDTO struct {
+	Items []domain.Item `json:"items"`
+	// NextCursor is passed to get the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type StockMovementRequestDTO struct {
+	StorehouseID domain.StoreHouseID `json:"storehouseID" validate:"required"`
+	ItemID       domain.ItemID       `json:"itemID" validate:"required"`
+	// Quantity must be positive for receipts and write-offs, a negative adjustment decreases the count
+	Quantity int               `json:"quantity" validate:"required"`
+	Reason   domain.ReasonCode `json:"reason" validate:"required"`
+	Actor    string            `json:"actor" validate:"required"`
+	Comment  string            `json:"comment"`
+}
+
+type StockAtRequestDTO struct {
+	StorehouseID domain.StoreHouseID `uri:"id" validate:"required"`
+	ItemID       domain.ItemID       `form:"item-id"`
+	// At is the moment to rebuild the stock at, now by default
+	At *time.Time `form:"at" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type StockAtResponseDTO struct {
+	StorehouseID domain.StoreHouseID `json:"storehouseID"`
+	At           time.Time           `json:"at"`
+	Items        []domain.StockLevel `json:"items"`
+}
+
+type GetStockResponseDTO struct {
 	StorehouseID domain.StoreHouseID `json:"storehouseID"`
 	Items        []domain.ItemData   `json:"items"`
 }
@@ -0,0 +1,27 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyConflict = errors.New("idempotency key is already used for another request")
+)
+
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Response    ReservationResponseDTO
+}
+
+type IdempotencyRepository interface {
+	// Claim stores the key with the request fingerprint if the key is new and returns claimed = true.
+	// Otherwise, it waits until the transaction that claimed the key is finished and returns the stored record.
+	// It must be called within a transaction, so the key is freed if the operation fails
+	Claim(ctx context.Context, key string, fingerprint string) (record IdempotencyRecord, claimed bool, err error)
+	SaveResponse(ctx context.Context, key string, response ReservationResponseDTO) error
+	// DeleteCreatedBefore deletes up to limit keys claimed before the moment and returns how many were deleted
+	DeleteCreatedBefore(ctx context.Context, before time.Time, limit int) (int, error)
+}
@@ -2,23 +2,111 @@ package ports
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
 )
 
+var (
+	// ErrConcurrentModification is returned when the stored version differs from the one that was read,
+	// so the operation should be repeated with fresh data
+	ErrConcurrentModification = errors.New("concurrent modification")
+	ErrReservationNotFound    = errors.New("reservation not found")
+	ErrInvalidCursor          = errors.New("invalid cursor")
+)
+
 type StorehouseRepository interface {
-	GetItemsByID(ctx context.Context, id domain.StoreHouseID) (map[domain.ItemID]domain.ItemData, error)
+	// GetByID returns the storehouse with its items or domain.ErrUnknownStorehouse
+	GetByID(ctx context.Context, id domain.StoreHouseID) (domain.StoreHouse, error)
+	// GetItemsByID returns stock of the storehouse filtered by item IDs. No item IDs means all items
+	GetItemsByID(ctx context.Context, id domain.StoreHouseID, itemIDs []domain.ItemID) (map[domain.ItemID]domain.ItemData, error)
+	// GetUnreserved returns stock of items with available units in the storehouse matching the filter ordered by item ID
+	GetUnreserved(ctx context.Context, filter UnreservedFilter) ([]domain.ItemData, error)
+	// GetItemsInActiveStorehouses is GetItemsByID for all open storehouses which are not deactivated
+	GetItemsInActiveStorehouses(ctx context.Context, itemIDs []domain.ItemID) (map[domain.StoreHouseID]map[domain.ItemID]domain.ItemData, error)
 	GetAllAsMap(ctx context.Context) (map[domain.StoreHouseID]domain.StoreHouse, error)
-	UpdateAll(ctx context.Context, storehouses map[domain.StoreHouseID]domain.StoreHouse) error
+	// Create fails with domain.ErrStorehouseAlreadyExists if ID or name is taken
+	Create(ctx context.Context, storehouse domain.StoreHouse) error
+	// Update saves name, location and deactivation flag of the storehouse
+	Update(ctx context.Context, storehouse domain.StoreHouse) error
+	// LockItems returns stock of the given items in all storehouses and locks it until the end of the transaction,
+	// so concurrent updates of the same items wait for each other. Storehouses are locked in shared mode,
+	// so they are not changed by LockStorehouses holders meanwhile. It must be called within a transaction
+	LockItems(ctx context.Context, itemIDs []domain.ItemID) (map[domain.StoreHouseID]domain.StoreHouse, error)
+	// LockStorehouses locks all storehouses exclusively until the end of the transaction,
+	// so it waits for transactions holding LockItems and blocks new ones. It must be called within a transaction
+	LockStorehouses(ctx context.Context) error
+	// ApplyDeltas changes stock by the deltas. A delta that would make available or reserved units negative
+	// fails the whole call with domain.ErrNotEnoughItemsInStorehouse
+	ApplyDeltas(ctx context.Context, deltas []domain.StockDelta) error
+}
+
+type UnreservedFilter struct {
+	StorehouseID domain.StoreHouseID
+	// ItemIDs limit the items, empty means all items
+	ItemIDs []domain.ItemID
+	AfterID domain.ItemID
+	Limit   int
 }
 
 type ItemsRepository interface {
 	GetAllAsMap(ctx context.Context) (map[domain.ItemID]domain.Item, error)
+	// GetByID returns the item or domain.ErrUnknownItem
+	GetByID(ctx context.Context, id domain.ItemID) (domain.Item, error)
+	// Create fails with domain.ErrItemAlreadyExists if the ID is taken
+	Create(ctx context.Context, item domain.Item) error
+	// Update fails with domain.ErrUnknownItem if there is no such item
+	Update(ctx context.Context, item domain.Item) error
+	// Search returns items matching the filter ordered by ID
+	Search(ctx context.Context, filter ItemFilter) ([]domain.Item, error)
+}
+
+type ItemFilter struct {
+	// Query is a case-insensitive part of the item name, empty matches everything
+	Query   string
+	AfterID domain.ItemID
+	Limit   int
+}
+
+// StockMovementRepository is the append-only stock ledger. Stock itself is changed with StorehouseRepository.ApplyDeltas
+// in the same transaction, the ledger keeps the history of changes
+type StockMovementRepository interface {
+	Append(ctx context.Context, movements []domain.StockMovement) error
+	// GetStockAt sums movements of the storehouse made up to the moment. Empty item ID means all items
+	GetStockAt(ctx context.Context, storehouseID domain.StoreHouseID, itemID domain.ItemID, at time.Time) ([]domain.StockLevel, error)
 }
 
 type ReservationRepository interface {
 	GetByID(ctx context.Context, id string) (domain.Reservation, error)
 	Save(ctx context.Context, reservation domain.Reservation) error
+	// Update and Delete fail with ErrConcurrentModification if the reservation version was changed since reading
 	Update(ctx context.Context, reservation domain.Reservation) error
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, reservation domain.Reservation) error
+	// GetOpenByStorehouse returns held, confirmed and picked reservations with entries from the storehouse
+	GetOpenByStorehouse(ctx context.Context, storehouseID domain.StoreHouseID) ([]domain.Reservation, error)
+	// List returns reservations matching the filter ordered by creation time and ID
+	List(ctx context.Context, filter ReservationFilter) ([]domain.Reservation, error)
+	// GetExpiredIDs returns up to limit IDs of held reservations that expired by now, the oldest first
+	GetExpiredIDs(ctx context.Context, now time.Time, limit int) ([]string, error)
+}
+
+// ReservationFilter selects reservations for listing, nil and empty fields are not applied
+type ReservationFilter struct {
+	StorehouseID domain.StoreHouseID
+	ItemID       domain.ItemID
+	MinLatitude  *float64
+	MaxLatitude  *float64
+	MinLongitude *float64
+	MaxLongitude *float64
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	// After is the position of the last reservation of the previous page
+	After *ReservationCursor
+	Limit int
+}
+
+type ReservationCursor struct {
+	CreatedAt time.Time
+	ID        string
 }
@@ -1,11 +1,55 @@
 package ports
 
 import (
+	"time"
+
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
 )
 
 type ReservationService interface {
-	Reserve(request domain.ReserveRequest) (ReservationResponseDTO, error)
-	Release(reservationID string, itemsToRelease []domain.ReserveEntry) (ReservationResponseDTO, error)
-	GetUnreserved(storehouseID domain.StoreHouseID) ([]domain.ItemData, error)
+	// Reserve and Release with not empty idempotencyKey are performed once per key
+	Reserve(request domain.ReserveRequest, idempotencyKey string) (ReservationResponseDTO, error)
+	Release(request ReleaseRequestDTO, idempotencyKey string) (ReservationResponseDTO, error)
+	// Quote allocates items like Reserve does, but doesn't save anything
+	Quote(request domain.ReserveRequest) (QuoteResponseDTO, error)
+	// GetReservation returns the reservation with its current total cost
+	GetReservation(id string) (ReservationResponseDTO, error)
+	ListReservations(request ListReservationsRequestDTO) (ListReservationsResponseDTO, error)
+	// ChangeStatus moves the reservation to the given lifecycle status if the transition is allowed
+	ChangeStatus(id string, status domain.ReservationStatus) (domain.Reservation, error)
+	// GetUnreserved returns a page of items with available units in the storehouse or domain.ErrUnknownStorehouse
+	GetUnreserved(request GetUnreservedRequestDTO) (GetUnreservedResponseDTO, error)
+	// GetAvailability sums available units of the items over active storehouses
+	GetAvailability(request AvailabilityRequestDTO) (AvailabilityResponseDTO, error)
+	// ReleaseExpired releases up to limit reservations expired by now and returns how many were released
+	ReleaseExpired(now time.Time, limit int) (int, error)
+	// DeleteIdempotencyKeys forgets up to limit idempotency keys used before the moment and returns how many were deleted
+	DeleteIdempotencyKeys(before time.Time, limit int) (int, error)
+}
+
+type StorehouseService interface {
+	Create(request CreateStorehouseRequestDTO) (domain.StoreHouse, error)
+	Get(id domain.StoreHouseID) (domain.StoreHouse, error)
+	GetAll() ([]domain.StoreHouse, error)
+	Update(request UpdateStorehouseRequestDTO) (domain.StoreHouse, error)
+	Relocate(request RelocateStorehouseRequestDTO) (domain.StoreHouse, error)
+	SetStatus(request StorehouseStatusRequestDTO) (domain.StoreHouse, error)
+	// Deactivate refuses to deactivate the storehouse with open reservations unless a transfer plan is given
+	Deactivate(request DeactivateStorehouseRequestDTO) (DeactivationResponseDTO, error)
+}
+
+type ItemService interface {
+	Create(item domain.Item) (domain.Item, error)
+	Get(id domain.ItemID) (domain.Item, error)
+	Update(request UpdateItemRequestDTO) (domain.Item, error)
+	Search(request SearchItemsRequestDTO) (SearchItemsResponseDTO, error)
+}
+
+type StockService interface {
+	// RecordMovement applies the movement to the stock and saves it with the reason and the actor
+	RecordMovement(movementType domain.MovementType, request StockMovementRequestDTO) (domain.StockMovement, error)
+	// GetStock returns on-hand, reserved and available units of every item kept in the storehouse
+	GetStock(storehouseID domain.StoreHouseID) (GetStockResponseDTO, error)
+	// GetStockAt rebuilds available and reserved stock of the storehouse at the moment from the ledger
+	GetStockAt(request StockAtRequestDTO) (StockAtResponseDTO, error)
 }
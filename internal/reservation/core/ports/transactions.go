@@ -0,0 +1,11 @@
+package ports
+
+import "context"
+
+// TransactionManager runs several repository calls as a single unit of work.
+// Repositories join the transaction when they receive the context passed to the operation
+type TransactionManager interface {
+	// WithinTransaction commits all changes made by operation if it returns nil and rolls them back otherwise.
+	// Nested calls join the outer transaction
+	WithinTransaction(ctx context.Context, operation func(ctx context.Context) error) error
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+const (
+	defaultPageSize = 20
+)
+
+// encodeCursor hides the position of the reservation in the listing behind an opaque string
+func encodeCursor(reservation domain.Reservation) string {
+	position := reservation.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + reservation.ID
+
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+func decodeCursor(cursor string) (*ports.ReservationCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ports.ErrInvalidCursor, cursor)
+	}
+
+	createdAt, id, found := strings.Cut(string(position), "|")
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ports.ErrInvalidCursor, cursor)
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ports.ErrInvalidCursor, cursor)
+	}
+
+	return &ports.ReservationCursor{CreatedAt: parsed, ID: id}, nil
+}
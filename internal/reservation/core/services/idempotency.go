@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+// withIdempotency runs perform once per idempotency key. The key is claimed in the same transaction as the operation,
+// so a failed operation frees the key, and a concurrent call with the same key waits and returns the stored response.
+// Empty key disables the check
+func (service Service) withIdempotency(key string, operation string, request any,
+	perform func(ctx context.Context) (ports.ReservationResponseDTO, error)) (ports.ReservationResponseDTO, error) {
+
+	if key == "" {
+		return perform(context.TODO())
+	}
+
+	requestFingerprint, err := fingerprint(operation, request)
+	if err != nil {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("%s: %w", operation, err)
+	}
+
+	var response ports.ReservationResponseDTO
+
+	err = service.transactions.WithinTransaction(context.TODO(), func(ctx context.Context) error {
+		record, claimed, err := service.idempotencyRepo.Claim(ctx, key, requestFingerprint)
+		if err != nil {
+			return fmt.Errorf("%s: claiming idempotency key: %w", operation, err)
+		}
+
+		if !claimed {
+			if record.Fingerprint != requestFingerprint {
+				return fmt.Errorf("%w: %s", ports.ErrIdempotencyKeyConflict, key)
+			}
+
+			response = record.Response
+			return nil
+		}
+
+		response, err = perform(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = service.idempotencyRepo.SaveResponse(ctx, key, response)
+		if err != nil {
+			return fmt.Errorf("%s: saving idempotent response: %w", operation, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ports.ReservationResponseDTO{}, err
+	}
+
+	return response, nil
+}
+
+// fingerprint identifies the operation with its payload, so a key reused for another request is detected
+func fingerprint(operation string, request any) (string, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("calculating request fingerprint: %w", err)
+	}
+
+	hash := sha256.Sum256(append([]byte(operation+":"), payload...))
+
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+// ItemService manages the item catalog. Items are read from the repository on every cost calculation,
+// so changes of size and weight affect costs immediately
+type ItemService struct {
+	itemsRepo ports.ItemsRepository
+}
+
+func NewItemService(itemsRepo ports.ItemsRepository) *ItemService {
+	return &ItemService{itemsRepo: itemsRepo}
+}
+
+func (service ItemService) Create(item domain.Item) (domain.Item, error) {
+	err := service.itemsRepo.Create(context.TODO(), item)
+	if err != nil {
+		return domain.Item{}, fmt.Errorf("create item: %w", err)
+	}
+
+	return item, nil
+}
+
+func (service ItemService) Get(id domain.ItemID) (domain.Item, error) {
+	item, err := service.itemsRepo.GetByID(context.TODO(), id)
+	if err != nil {
+		return domain.Item{}, fmt.Errorf("get item: %w", err)
+	}
+
+	return item, nil
+}
+
+func (service ItemService) Update(request ports.UpdateItemRequestDTO) (domain.Item, error) {
+	item := domain.Item{
+		ID:              request.ItemID,
+		Name:            request.Name,
+		Size:            request.Size,
+		WeightKilograms: request.WeightKilograms,
+	}
+
+	err := service.itemsRepo.Update(context.TODO(), item)
+	if err != nil {
+		return domain.Item{}, fmt.Errorf("update item: %w", err)
+	}
+
+	return item, nil
+}
+
+func (service ItemService) Search(request ports.SearchItemsRequestDTO) (ports.SearchItemsResponseDTO, error) {
+	afterID, err := decodeItemCursor(request.Cursor)
+	if err != nil {
+		return ports.SearchItemsResponseDTO{}, fmt.Errorf("search items: %w", err)
+	}
+
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	// one more item is requested to know whether there is the next page
+	items, err := service.itemsRepo.Search(context.TODO(), ports.ItemFilter{
+		Query:   request.Query,
+		AfterID: afterID,
+		Limit:   limit + 1,
+	})
+	if err != nil {
+		return ports.SearchItemsResponseDTO{}, fmt.Errorf("search items: %w", err)
+	}
+
+	response := ports.SearchItemsResponseDTO{Items: items}
+	if len(items) > limit {
+		response.Items = items[:limit]
+		response.NextCursor = encodeItemCursor(items[limit-1].ID)
+	}
+
+	return response, nil
+}
+
+func encodeItemCursor(id domain.ItemID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func decodeItemCursor(cursor string) (domain.ItemID, error) {
+	id, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ports.ErrInvalidCursor, cursor)
+	}
+
+	return domain.ItemID(id), nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+func TestItemService_UpdateChangesCost(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, _, _ := newMemoryService(storehouses, items)
+	itemService := NewItemService(service.itemsRepo)
+
+	reserved, err := service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "a"}},
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	_, err = itemService.Update(ports.UpdateItemRequestDTO{
+		ItemID:          "1",
+		Name:            "heavier 1",
+		Size:            items["1"].Size,
+		WeightKilograms: 2 * items["1"].WeightKilograms,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	reservation, err := service.GetReservation(reserved.Reservation.ID)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Greater(t, reservation.TotalCost, reserved.TotalCost)
+
+	_, err = itemService.Update(ports.UpdateItemRequestDTO{ItemID: "3", Name: "3", Size: items["1"].Size, WeightKilograms: 1})
+	assert.ErrorIs(t, err, domain.ErrUnknownItem)
+}
+
+func TestItemService_Search(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, _, _ := newMemoryService(storehouses, items)
+	itemService := NewItemService(service.itemsRepo)
+
+	for _, id := range []domain.ItemID{"3", "4", "5"} {
+		_, err := itemService.Create(domain.Item{
+			ID:              id,
+			Name:            "Coat " + string(id),
+			Size:            &domain.Size{LengthMeters: 1, WidthMeters: 1, HeightMeters: 1},
+			WeightKilograms: 1,
+		})
+		assert.NoError(t, err)
+	}
+
+	_, err := itemService.Create(domain.Item{ID: "3", Name: "duplicate"})
+	assert.ErrorIs(t, err, domain.ErrItemAlreadyExists)
+
+	found := make([]domain.ItemID, 0)
+	request := ports.SearchItemsRequestDTO{Query: "coat", Limit: 2}
+	for {
+		page, err := itemService.Search(request)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		for _, item := range page.Items {
+			found = append(found, item.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		request.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, []domain.ItemID{"3", "4", "5"}, found)
+}
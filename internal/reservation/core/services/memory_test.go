@@ -0,0 +1,720 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+type memoryTxKey struct{}
+
+// memoryTransaction keeps locks and undo operations of one unit of work
+type memoryTransaction struct {
+	// storehousesLocked is set when the transaction holds storehousesLock in any mode
+	storehousesLocked bool
+	lockedItems       []domain.ItemID
+	unlocks           []func()
+	undo              []func()
+}
+
+type memoryTransactionManager struct{}
+
+func (memoryTransactionManager) WithinTransaction(ctx context.Context, operation func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTransaction); ok {
+		return operation(ctx)
+	}
+
+	tx := &memoryTransaction{}
+	defer func() {
+		for i := len(tx.unlocks) - 1; i >= 0; i-- {
+			tx.unlocks[i]()
+		}
+	}()
+
+	err := operation(context.WithValue(ctx, memoryTxKey{}, tx))
+	if err != nil {
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+	}
+
+	return err
+}
+
+// onRollback registers undo of a change if it's made within a transaction
+func onRollback(ctx context.Context, undo func()) {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTransaction); ok {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+// memoryStorehouseRepository behaves like the postgres one: stock of each item is locked separately,
+// storehouses are locked all together in shared or exclusive mode
+type memoryStorehouseRepository struct {
+	mu              sync.Mutex
+	storehousesLock sync.RWMutex
+	itemLocks       map[domain.ItemID]*sync.Mutex
+	storehouses     map[domain.StoreHouseID]domain.StoreHouse
+	violations      []string
+}
+
+func (repo *memoryStorehouseRepository) GetItemsByID(_ context.Context, id domain.StoreHouseID, itemIDs []domain.ItemID) (map[domain.ItemID]domain.ItemData, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return filterItemsData(repo.storehouses[id].ItemsData, itemIDs), nil
+}
+
+func (repo *memoryStorehouseRepository) GetUnreserved(_ context.Context, filter ports.UnreservedFilter) ([]domain.ItemData, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	unreserved := make([]domain.ItemData, 0)
+	for _, itemData := range filterItemsData(repo.storehouses[filter.StorehouseID].ItemsData, filter.ItemIDs) {
+		if itemData.Available > 0 && itemData.Item.ID > filter.AfterID {
+			unreserved = append(unreserved, itemData)
+		}
+	}
+
+	slices.SortFunc(unreserved, func(a, b domain.ItemData) int {
+		return strings.Compare(string(a.Item.ID), string(b.Item.ID))
+	})
+
+	return unreserved[:min(len(unreserved), filter.Limit)], nil
+}
+
+func (repo *memoryStorehouseRepository) GetItemsInActiveStorehouses(_ context.Context, itemIDs []domain.ItemID) (map[domain.StoreHouseID]map[domain.ItemID]domain.ItemData, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	itemsByStorehouse := make(map[domain.StoreHouseID]map[domain.ItemID]domain.ItemData)
+	for id, storehouse := range repo.storehouses {
+		if storehouse.CheckDispatch(nil) == nil {
+			itemsByStorehouse[id] = filterItemsData(storehouse.ItemsData, itemIDs)
+		}
+	}
+
+	return itemsByStorehouse, nil
+}
+
+func filterItemsData(itemsData map[domain.ItemID]domain.ItemData, itemIDs []domain.ItemID) map[domain.ItemID]domain.ItemData {
+	if len(itemIDs) == 0 {
+		return maps.Clone(itemsData)
+	}
+
+	filtered := make(map[domain.ItemID]domain.ItemData)
+	for _, itemID := range itemIDs {
+		if itemData, ok := itemsData[itemID]; ok {
+			filtered[itemID] = itemData
+		}
+	}
+
+	return filtered
+}
+
+func (repo *memoryStorehouseRepository) GetAllAsMap(_ context.Context) (map[domain.StoreHouseID]domain.StoreHouse, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return domain.CloneStorehouses(repo.storehouses), nil
+}
+
+func (repo *memoryStorehouseRepository) GetByID(_ context.Context, id domain.StoreHouseID) (domain.StoreHouse, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	storehouse, ok := repo.storehouses[id]
+	if !ok {
+		return domain.StoreHouse{}, fmt.Errorf("%w: %s", domain.ErrUnknownStorehouse, id)
+	}
+
+	storehouse.ItemsData = maps.Clone(storehouse.ItemsData)
+
+	return storehouse, nil
+}
+
+func (repo *memoryStorehouseRepository) Create(ctx context.Context, storehouse domain.StoreHouse) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.storehouses[storehouse.ID]; ok {
+		return fmt.Errorf("%w: id: %s", domain.ErrStorehouseAlreadyExists, storehouse.ID)
+	}
+
+	storehouse.ItemsData = make(map[domain.ItemID]domain.ItemData)
+	repo.storehouses[storehouse.ID] = storehouse
+	onRollback(ctx, func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		delete(repo.storehouses, storehouse.ID)
+	})
+
+	return nil
+}
+
+// Update changes everything but stock, like the postgres one
+func (repo *memoryStorehouseRepository) Update(ctx context.Context, storehouse domain.StoreHouse) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.storehouses[storehouse.ID]
+	if !ok {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownStorehouse, storehouse.ID)
+	}
+
+	storehouse.ItemsData = stored.ItemsData
+	repo.storehouses[storehouse.ID] = storehouse
+	onRollback(ctx, func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		stored.ItemsData = repo.storehouses[stored.ID].ItemsData
+		repo.storehouses[stored.ID] = stored
+	})
+
+	return nil
+}
+
+func (repo *memoryStorehouseRepository) LockItems(ctx context.Context, itemIDs []domain.ItemID) (map[domain.StoreHouseID]domain.StoreHouse, error) {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTransaction)
+	if !ok {
+		return nil, errors.New("locking items outside of transaction")
+	}
+
+	if !tx.storehousesLocked {
+		repo.storehousesLock.RLock()
+		tx.storehousesLocked = true
+		tx.unlocks = append(tx.unlocks, repo.storehousesLock.RUnlock)
+	}
+
+	sortedIDs := slices.Clone(itemIDs)
+	slices.Sort(sortedIDs)
+
+	for _, itemID := range sortedIDs {
+		lock := repo.itemLock(itemID)
+		lock.Lock()
+		tx.unlocks = append(tx.unlocks, lock.Unlock)
+	}
+
+	tx.lockedItems = append(tx.lockedItems, itemIDs...)
+
+	repo.mu.Lock()
+	locked := make(map[domain.StoreHouseID]domain.StoreHouse, len(repo.storehouses))
+	for id, storehouse := range repo.storehouses {
+		itemsData := make(map[domain.ItemID]domain.ItemData)
+		for _, itemID := range itemIDs {
+			if itemData, ok := storehouse.ItemsData[itemID]; ok {
+				itemsData[itemID] = itemData
+			}
+		}
+
+		storehouse.ItemsData = itemsData
+		locked[id] = storehouse
+	}
+	repo.mu.Unlock()
+
+	// gives other goroutines a chance to run while the stock is locked
+	runtime.Gosched()
+
+	return locked, nil
+}
+
+func (repo *memoryStorehouseRepository) LockStorehouses(ctx context.Context) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTransaction)
+	if !ok {
+		return errors.New("locking storehouses outside of transaction")
+	}
+
+	if tx.storehousesLocked {
+		return errors.New("upgrading the shared lock of storehouses")
+	}
+
+	repo.storehousesLock.Lock()
+	tx.storehousesLocked = true
+	tx.unlocks = append(tx.unlocks, repo.storehousesLock.Unlock)
+
+	return nil
+}
+
+func (repo *memoryStorehouseRepository) ApplyDeltas(ctx context.Context, deltas []domain.StockDelta) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTransaction)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, delta := range deltas {
+		if !ok || !slices.Contains(tx.lockedItems, delta.ItemID) {
+			repo.violations = append(repo.violations, fmt.Sprintf("%s is changed without lock", delta.ItemID))
+		}
+
+		itemData := repo.storehouses[delta.StorehouseID].ItemsData[delta.ItemID]
+		if itemData.Available+delta.Count < 0 || itemData.Reserved+delta.Reserved < 0 {
+			return fmt.Errorf("%w: storehouse id: %s, item id: %s",
+				domain.ErrNotEnoughItemsInStorehouse, delta.StorehouseID, delta.ItemID)
+		}
+	}
+
+	repo.add(deltas, 1)
+
+	onRollback(ctx, func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		repo.add(deltas, -1)
+	})
+
+	return nil
+}
+
+func (repo *memoryStorehouseRepository) add(deltas []domain.StockDelta, sign int) {
+	for _, delta := range deltas {
+		itemData := repo.storehouses[delta.StorehouseID].ItemsData[delta.ItemID]
+		itemData.Available += sign * delta.Count
+		itemData.Reserved += sign * delta.Reserved
+		itemData.OnHand += sign * delta.OnHand()
+		repo.storehouses[delta.StorehouseID].ItemsData[delta.ItemID] = itemData
+	}
+}
+
+func (repo *memoryStorehouseRepository) itemLock(itemID domain.ItemID) *sync.Mutex {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.itemLocks[itemID]; !ok {
+		repo.itemLocks[itemID] = &sync.Mutex{}
+	}
+
+	return repo.itemLocks[itemID]
+}
+
+func (repo *memoryStorehouseRepository) count(storehouseID domain.StoreHouseID, itemID domain.ItemID) int {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.storehouses[storehouseID].ItemsData[itemID].Available
+}
+
+func (repo *memoryStorehouseRepository) reserved(storehouseID domain.StoreHouseID, itemID domain.ItemID) int {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.storehouses[storehouseID].ItemsData[itemID].Reserved
+}
+
+type memoryItemsRepository struct {
+	mu    sync.Mutex
+	items map[domain.ItemID]domain.Item
+}
+
+func (repo *memoryItemsRepository) GetAllAsMap(_ context.Context) (map[domain.ItemID]domain.Item, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return maps.Clone(repo.items), nil
+}
+
+func (repo *memoryItemsRepository) GetByID(_ context.Context, id domain.ItemID) (domain.Item, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	item, ok := repo.items[id]
+	if !ok {
+		return domain.Item{}, fmt.Errorf("%w: %s", domain.ErrUnknownItem, id)
+	}
+
+	return item, nil
+}
+
+func (repo *memoryItemsRepository) Create(_ context.Context, item domain.Item) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.items[item.ID]; ok {
+		return fmt.Errorf("%w: %s", domain.ErrItemAlreadyExists, item.ID)
+	}
+
+	repo.items[item.ID] = item
+
+	return nil
+}
+
+func (repo *memoryItemsRepository) Update(_ context.Context, item domain.Item) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.items[item.ID]; !ok {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownItem, item.ID)
+	}
+
+	repo.items[item.ID] = item
+
+	return nil
+}
+
+func (repo *memoryItemsRepository) Search(_ context.Context, filter ports.ItemFilter) ([]domain.Item, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	items := make([]domain.Item, 0)
+	for _, item := range repo.items {
+		if item.ID > filter.AfterID && strings.Contains(strings.ToLower(item.Name), strings.ToLower(filter.Query)) {
+			items = append(items, item)
+		}
+	}
+
+	slices.SortFunc(items, func(a, b domain.Item) int {
+		return strings.Compare(string(a.ID), string(b.ID))
+	})
+
+	return items[:min(len(items), filter.Limit)], nil
+}
+
+type memoryStockMovementRepository struct {
+	mu        sync.Mutex
+	movements []domain.StockMovement
+}
+
+func (repo *memoryStockMovementRepository) Append(ctx context.Context, movements []domain.StockMovement) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.movements = append(repo.movements, movements...)
+	onRollback(ctx, func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		ids := make([]string, 0, len(movements))
+		for _, movement := range movements {
+			ids = append(ids, movement.ID)
+		}
+
+		repo.movements = slices.DeleteFunc(repo.movements, func(saved domain.StockMovement) bool {
+			return slices.Contains(ids, saved.ID)
+		})
+	})
+
+	return nil
+}
+
+func (repo *memoryStockMovementRepository) GetStockAt(_ context.Context, storehouseID domain.StoreHouseID,
+	itemID domain.ItemID, at time.Time) ([]domain.StockLevel, error) {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	levels := make(map[domain.ItemID]domain.StockLevel)
+	for _, movement := range repo.movements {
+		if movement.StorehouseID != storehouseID || (itemID != "" && movement.ItemID != itemID) || movement.CreatedAt.After(at) {
+			continue
+		}
+
+		level := levels[movement.ItemID]
+		level.ItemID = movement.ItemID
+		level.OnHand += movement.AvailableDelta + movement.ReservedDelta
+		level.Reserved += movement.ReservedDelta
+		level.Available += movement.AvailableDelta
+		levels[movement.ItemID] = level
+	}
+
+	sorted := make([]domain.StockLevel, 0, len(levels))
+	for _, level := range levels {
+		if level.Available != 0 || level.Reserved != 0 {
+			sorted = append(sorted, level)
+		}
+	}
+
+	slices.SortFunc(sorted, func(a, b domain.StockLevel) int {
+		return strings.Compare(string(a.ItemID), string(b.ItemID))
+	})
+
+	return sorted, nil
+}
+
+type memoryReservationRepository struct {
+	mu           sync.Mutex
+	reservations map[string]domain.Reservation
+	// saveErr is returned by Save if set
+	saveErr error
+	// afterGetExpiredIDs is called by GetExpiredIDs if set, when the reservations are already listed
+	afterGetExpiredIDs func()
+}
+
+func (repo *memoryReservationRepository) GetByID(_ context.Context, id string) (domain.Reservation, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	reservation, ok := repo.reservations[id]
+	if !ok {
+		return domain.Reservation{}, fmt.Errorf("%w: %s", ports.ErrReservationNotFound, id)
+	}
+
+	reservation.Entries = append([]domain.ReserveEntry(nil), reservation.Entries...)
+
+	return reservation, nil
+}
+
+func (repo *memoryReservationRepository) Save(ctx context.Context, reservation domain.Reservation) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.saveErr != nil {
+		return repo.saveErr
+	}
+
+	repo.reservations[reservation.ID] = reservation
+	onRollback(ctx, repo.restore(reservation.ID, domain.Reservation{}, false))
+
+	return nil
+}
+
+func (repo *memoryReservationRepository) Update(ctx context.Context, reservation domain.Reservation) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.reservations[reservation.ID]
+	if !ok || stored.Version != reservation.Version {
+		return fmt.Errorf("%w: reservation %s", ports.ErrConcurrentModification, reservation.ID)
+	}
+
+	reservation.Version++
+	repo.reservations[reservation.ID] = reservation
+	onRollback(ctx, repo.restore(reservation.ID, stored, true))
+
+	return nil
+}
+
+func (repo *memoryReservationRepository) Delete(ctx context.Context, reservation domain.Reservation) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.reservations[reservation.ID]
+	if !ok || stored.Version != reservation.Version {
+		return fmt.Errorf("%w: reservation %s", ports.ErrConcurrentModification, reservation.ID)
+	}
+
+	delete(repo.reservations, reservation.ID)
+	onRollback(ctx, repo.restore(reservation.ID, stored, true))
+
+	return nil
+}
+
+func (repo *memoryReservationRepository) GetOpenByStorehouse(_ context.Context, storehouseID domain.StoreHouseID) ([]domain.Reservation, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	reservations := make([]domain.Reservation, 0)
+	for _, reservation := range repo.reservations {
+		fromStorehouse := slices.ContainsFunc(reservation.Entries, func(entry domain.ReserveEntry) bool {
+			return entry.SourceStorehouseID == storehouseID
+		})
+		if reservation.IsOpen() && fromStorehouse {
+			reservation.Entries = slices.Clone(reservation.Entries)
+			reservations = append(reservations, reservation)
+		}
+	}
+
+	// gives other goroutines a chance to change reservations after they are listed
+	runtime.Gosched()
+
+	return reservations, nil
+}
+
+// List supports only storehouse, item and cursor filters
+func (repo *memoryReservationRepository) List(_ context.Context, filter ports.ReservationFilter) ([]domain.Reservation, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	reservations := make([]domain.Reservation, 0)
+	for _, reservation := range repo.reservations {
+		hasEntry := slices.ContainsFunc(reservation.Entries, func(entry domain.ReserveEntry) bool {
+			return (filter.StorehouseID.IsEmpty() || entry.SourceStorehouseID == filter.StorehouseID) &&
+				(filter.ItemID == "" || entry.ItemID == filter.ItemID)
+		})
+		if !hasEntry {
+			continue
+		}
+
+		if filter.After != nil && !reservation.CreatedAt.After(filter.After.CreatedAt) &&
+			(!reservation.CreatedAt.Equal(filter.After.CreatedAt) || reservation.ID <= filter.After.ID) {
+			continue
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	slices.SortFunc(reservations, func(a, b domain.Reservation) int {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return reservations[:min(len(reservations), filter.Limit)], nil
+}
+
+func (repo *memoryReservationRepository) GetExpiredIDs(_ context.Context, now time.Time, limit int) ([]string, error) {
+	repo.mu.Lock()
+	ids := make([]string, 0)
+	for id, reservation := range repo.reservations {
+		if reservation.IsExpired(now) && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	repo.mu.Unlock()
+
+	if repo.afterGetExpiredIDs != nil {
+		repo.afterGetExpiredIDs()
+	}
+
+	return ids, nil
+}
+
+// restore returns undo which puts back the previous state of the reservation
+func (repo *memoryReservationRepository) restore(id string, previous domain.Reservation, existed bool) func() {
+	return func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		if !existed {
+			delete(repo.reservations, id)
+			return
+		}
+
+		repo.reservations[id] = previous
+	}
+}
+
+// reservedCount sums the count of the item taken from the storehouse by all reservations except cancelled ones
+func (repo *memoryReservationRepository) reservedCount(storehouseID domain.StoreHouseID, itemID domain.ItemID) int {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	count := 0
+	for _, reservation := range repo.reservations {
+		if reservation.Status == domain.Cancelled {
+			continue
+		}
+
+		for _, entry := range reservation.Entries {
+			if entry.SourceStorehouseID == storehouseID && entry.ItemID == itemID {
+				count += entry.Count
+			}
+		}
+	}
+
+	return count
+}
+
+type memoryIdempotencyRepository struct {
+	mu        sync.Mutex
+	keyLock   map[string]*sync.Mutex
+	records   map[string]ports.IdempotencyRecord
+	claimedAt map[string]time.Time
+}
+
+func (repo *memoryIdempotencyRepository) Claim(ctx context.Context, key string, fingerprint string) (ports.IdempotencyRecord, bool, error) {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTransaction)
+	if !ok {
+		return ports.IdempotencyRecord{}, false, errors.New("claiming key outside of transaction")
+	}
+
+	repo.mu.Lock()
+	if _, ok := repo.keyLock[key]; !ok {
+		repo.keyLock[key] = &sync.Mutex{}
+	}
+	lock := repo.keyLock[key]
+	repo.mu.Unlock()
+
+	// the key is held until the end of the transaction like a row inserted by it
+	lock.Lock()
+	tx.unlocks = append(tx.unlocks, lock.Unlock)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if record, ok := repo.records[key]; ok {
+		return record, false, nil
+	}
+
+	repo.records[key] = ports.IdempotencyRecord{Key: key, Fingerprint: fingerprint}
+	repo.claimedAt[key] = time.Now()
+	onRollback(ctx, func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		delete(repo.records, key)
+		delete(repo.claimedAt, key)
+	})
+
+	return repo.records[key], true, nil
+}
+
+func (repo *memoryIdempotencyRepository) SaveResponse(_ context.Context, key string, response ports.ReservationResponseDTO) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	record := repo.records[key]
+	record.Response = response
+	repo.records[key] = record
+
+	return nil
+}
+
+func (repo *memoryIdempotencyRepository) DeleteCreatedBefore(_ context.Context, before time.Time, limit int) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deleted := 0
+	for key, claimedAt := range repo.claimedAt {
+		if deleted == limit {
+			break
+		}
+
+		if claimedAt.Before(before) {
+			delete(repo.records, key)
+			delete(repo.claimedAt, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func newMemoryService(storehouses map[domain.StoreHouseID]domain.StoreHouse, items map[domain.ItemID]domain.Item) (
+	*Service, *memoryStorehouseRepository, *memoryReservationRepository) {
+
+	storehouseRepo := &memoryStorehouseRepository{
+		itemLocks:   make(map[domain.ItemID]*sync.Mutex),
+		storehouses: storehouses,
+	}
+	reservationRepo := &memoryReservationRepository{reservations: make(map[string]domain.Reservation)}
+
+	strategies := map[domain.AllocationStrategyName]ports.AllocationStrategy{
+		domain.NearestFirst:      domain.NearestFirstStrategy{},
+		domain.FewestStorehouses: domain.FewestStorehousesStrategy{},
+		domain.CostOptimal:       domain.CostOptimalStrategy{},
+		domain.SingleStorehouse:  domain.SingleStorehouseStrategy{},
+	}
+
+	idempotencyRepo := &memoryIdempotencyRepository{
+		keyLock:   make(map[string]*sync.Mutex),
+		records:   make(map[string]ports.IdempotencyRecord),
+		claimedAt: make(map[string]time.Time),
+	}
+
+	service := New(storehouseRepo, &memoryItemsRepository{items: items}, reservationRepo, idempotencyRepo,
+		&memoryStockMovementRepository{}, memoryTransactionManager{}, domain.DefaultCostModel(), strategies)
+
+	return service, storehouseRepo, reservationRepo
+}
@@ -1,8 +1,12 @@
 package services
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
@@ -12,123 +16,563 @@ type Service struct {
 	storehouseRepo  ports.StorehouseRepository
 	itemsRepo       ports.ItemsRepository
 	reservationRepo ports.ReservationRepository
+	idempotencyRepo ports.IdempotencyRepository
+	movementRepo    ports.StockMovementRepository
+	transactions    ports.TransactionManager
+	costs           domain.CostModel
+	strategies      map[domain.AllocationStrategyName]ports.AllocationStrategy
 }
 
-func New(storehouseRepo ports.StorehouseRepository, itemsRepo ports.ItemsRepository, reservationRepo ports.ReservationRepository) *Service {
-	return &Service{storehouseRepo: storehouseRepo, itemsRepo: itemsRepo, reservationRepo: reservationRepo}
+func New(storehouseRepo ports.StorehouseRepository, itemsRepo ports.ItemsRepository, reservationRepo ports.ReservationRepository,
+	idempotencyRepo ports.IdempotencyRepository, movementRepo ports.StockMovementRepository, transactions ports.TransactionManager,
+	costs domain.CostModel, strategies map[domain.AllocationStrategyName]ports.AllocationStrategy) *Service {
+	return &Service{storehouseRepo: storehouseRepo, itemsRepo: itemsRepo, reservationRepo: reservationRepo,
+		idempotencyRepo: idempotencyRepo, movementRepo: movementRepo, transactions: transactions, costs: costs,
+		strategies: strategies}
 }
 
-func (service Service) Reserve(request domain.ReserveRequest) (ports.ReservationResponseDTO, error) {
-	storehouses, err := service.storehouseRepo.GetAllAsMap(context.TODO())
+// Reserve allocates items while their stock is locked, so concurrent reservations can't take the same units.
+// Stock and the reservation are saved in one transaction.
+// A repeated call with the same idempotency key returns the original response
+func (service Service) Reserve(request domain.ReserveRequest, idempotencyKey string) (ports.ReservationResponseDTO, error) {
+	return service.withIdempotency(idempotencyKey, "reserve", request,
+		func(ctx context.Context) (ports.ReservationResponseDTO, error) {
+			return service.reserve(ctx, request)
+		})
+}
+
+func (service Service) reserve(ctx context.Context, request domain.ReserveRequest) (ports.ReservationResponseDTO, error) {
+	request = request.WithDispatchStart(time.Now().UTC())
+
+	strategy, err := service.getAllocationStrategy(request.Strategy)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: receiving storehouses: %w", err)
+		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: choosing allocation strategy: %w", err)
 	}
 
-	reservation, err := domain.NewReservationFromReserveRequest(request, storehouses)
+	items, err := service.itemsRepo.GetAllAsMap(ctx)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: building reservation: %w", err)
+		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: receiving items: %w", err)
 	}
 
-	items, err := service.itemsRepo.GetAllAsMap(context.TODO())
+	var reservation domain.Reservation
+	var storehouses map[domain.StoreHouseID]domain.StoreHouse
+
+	err = service.transactions.WithinTransaction(ctx, func(ctx context.Context) error {
+		storehouses, err = service.storehouseRepo.LockItems(ctx, request.ItemIDs())
+		if err != nil {
+			return fmt.Errorf("locking storehouses items: %w", err)
+		}
+
+		reservation, err = domain.NewReservationFromReserveRequest(request, storehouses, items, service.costs, strategy.Allocate)
+		if err != nil {
+			return fmt.Errorf("building reservation: %w", err)
+		}
+
+		// postgres keeps microseconds, so the returned time is the same as the stored one
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		reservation.CreatedAt = now
+		reservation.ExpiresAt = request.GetExpiresAt(now)
+
+		deltas := reservation.GetStockDeltas(domain.Reserve)
+
+		err = service.storehouseRepo.ApplyDeltas(ctx, deltas)
+		if err != nil {
+			return fmt.Errorf("updating storehouses state: %w", err)
+		}
+
+		err = service.movementRepo.Append(ctx, domain.ReservationMovements(reservation.ID, deltas, now))
+		if err != nil {
+			return fmt.Errorf("recording stock movements: %w", err)
+		}
+
+		err = service.reservationRepo.Save(ctx, reservation)
+		if err != nil {
+			return fmt.Errorf("saving reservation: %w", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: receiving items: %w", err)
+		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: %w", err)
+	}
+
+	totalCost, err := reservation.GetTotalCost(storehouses, items, service.costs)
+	if err != nil {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: calculating total cost: %w", err)
+	}
+
+	response := ports.ReservationResponseDTO{Reservation: reservation, TotalCost: totalCost}
+	if request.Explain {
+		response.Explanation = domain.ExplainReservation(request, reservation, storehouses, items, service.costs)
 	}
 
-	updatedStorehouses, err := reservation.GetUpdatedStorehouses(storehouses, domain.Reserve, items)
+	return response, nil
+}
+
+// Quote allocates items against a snapshot of the current stock without locking and saving anything,
+// so the real reservation made later may differ if the stock changes
+func (service Service) Quote(request domain.ReserveRequest) (ports.QuoteResponseDTO, error) {
+	ctx := context.TODO()
+	request = request.WithDispatchStart(time.Now().UTC())
+
+	strategy, err := service.getAllocationStrategy(request.Strategy)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: calculating storehouses state: %w", err)
+		return ports.QuoteResponseDTO{}, fmt.Errorf("quote: choosing allocation strategy: %w", err)
 	}
 
-	err = service.storehouseRepo.UpdateAll(context.TODO(), updatedStorehouses)
+	items, err := service.itemsRepo.GetAllAsMap(ctx)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: updating storehouses state: %w", err)
+		return ports.QuoteResponseDTO{}, fmt.Errorf("quote: receiving items: %w", err)
 	}
 
-	err = service.reservationRepo.Save(context.TODO(), reservation)
+	storehouses, err := service.storehouseRepo.GetAllAsMap(ctx)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: saving reservation: %w", err)
+		return ports.QuoteResponseDTO{}, fmt.Errorf("quote: receiving storehouses: %w", err)
 	}
 
-	totalCost, err := reservation.GetTotalCost(storehouses, items)
+	reservation, err := domain.NewReservationFromReserveRequest(request, storehouses, items, service.costs, strategy.Allocate)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("reserve: calculating total cost: %w", err)
+		return ports.QuoteResponseDTO{}, fmt.Errorf("quote: building reservation: %w", err)
 	}
 
-	return ports.ReservationResponseDTO{Reservation: reservation, TotalCost: totalCost}, nil
+	shipments, err := reservation.GetShipments(storehouses, items, service.costs)
+	if err != nil {
+		return ports.QuoteResponseDTO{}, fmt.Errorf("quote: calculating costs: %w", err)
+	}
+
+	response := ports.QuoteResponseDTO{
+		Entries:     reservation.Entries,
+		Shipments:   shipments,
+		Approximate: reservation.Approximate,
+		Gap:         reservation.Gap,
+	}
+	for _, shipment := range shipments {
+		response.TotalCost += shipment.Cost
+	}
+
+	if request.Explain {
+		response.Explanation = domain.ExplainReservation(request, reservation, storehouses, items, service.costs)
+	}
+
+	return response, nil
 }
 
-func (service Service) Release(reservationID string, itemsToRelease []domain.ReserveEntry) (ports.ReservationResponseDTO, error) {
-	reservation, err := service.reservationRepo.GetByID(context.TODO(), reservationID)
+// Release saves the new reservation state and returns stock in one transaction while stock of its items is locked.
+// The reservation is saved only if it was not changed since reading; otherwise everything is rolled back
+// and the release is repeated with the fresh reservation, so stock can't be returned twice.
+// A repeated call with the same idempotency key returns the original response
+func (service Service) Release(request ports.ReleaseRequestDTO, idempotencyKey string) (ports.ReservationResponseDTO, error) {
+	return retryOnConcurrentModification(func() (ports.ReservationResponseDTO, error) {
+		return service.withIdempotency(idempotencyKey, "release", request,
+			func(ctx context.Context) (ports.ReservationResponseDTO, error) {
+				return service.release(ctx, request, nil)
+			})
+	})
+}
+
+// release releases only expired reservations if expiredBy is set. The reservation is checked after reading,
+// and its version is checked on saving, so a reservation confirmed concurrently is never released by the sweeper
+func (service Service) release(
+	ctx context.Context, request ports.ReleaseRequestDTO, expiredBy *time.Time) (ports.ReservationResponseDTO, error) {
+
+	reservation, err := service.reservationRepo.GetByID(ctx, request.ReservationID)
 	if err != nil {
 		return ports.ReservationResponseDTO{}, fmt.Errorf("release: receiving reservation: %w", err)
 	}
 
+	if expiredBy != nil && !reservation.IsExpired(*expiredBy) {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("release: %w: %s", errReservationNotExpired, reservation.ID)
+	}
+
+	if !reservation.IsModifiable() {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("release: %w: %s", domain.ErrReservationNotModifiable, reservation.Status)
+	}
+
+	items, err := service.itemsRepo.GetAllAsMap(ctx)
+	if err != nil {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("release: receiving items: %w", err)
+	}
+
+	oldReservation := reservation
+	oldReservation.Entries = slices.Clone(reservation.Entries)
+
+	var storehouses map[domain.StoreHouseID]domain.StoreHouse
+	var reoptimization *ports.ReoptimizationDTO
+
+	err = service.transactions.WithinTransaction(ctx, func(ctx context.Context) error {
+		storehouses, err = service.storehouseRepo.LockItems(ctx, reservation.ItemIDs())
+		if err != nil {
+			return fmt.Errorf("locking storehouses items: %w", err)
+		}
+
+		oldStorehousesState, err := oldReservation.GetUpdatedStorehouses(storehouses, domain.Release, items)
+		if err != nil {
+			return fmt.Errorf("calculating released storehouse state: %w", err)
+		}
+
+		if len(request.ItemsToRelease) > 0 {
+			err = reservation.Release(request.ItemsToRelease, storehouses, items, service.costs)
+			if err != nil {
+				return fmt.Errorf("calculating new reservation state: %w", err)
+			}
+		}
+
+		needToDeleteReservation := len(request.ItemsToRelease) == 0 || len(reservation.Entries) == 0
+
+		if request.Reoptimize && !needToDeleteReservation {
+			reservation, reoptimization, err = service.reoptimize(reservation, oldStorehousesState, storehouses, items)
+			if err != nil {
+				return err
+			}
+		}
+
+		if needToDeleteReservation {
+			err = service.reservationRepo.Delete(ctx, oldReservation)
+			if err != nil {
+				return fmt.Errorf("deleting reservation: %w", err)
+			}
+
+			reservation = domain.Reservation{}
+		} else {
+			err = service.reservationRepo.Update(ctx, reservation)
+			if err != nil {
+				return fmt.Errorf("updating reservation: %w", err)
+			}
+		}
+
+		// moved entries may take the released items back, so only the net change is written
+		deltas := domain.MergeStockDeltas(oldReservation.GetStockDeltas(domain.Release), reservation.GetStockDeltas(domain.Reserve))
+
+		err = service.storehouseRepo.ApplyDeltas(ctx, deltas)
+		if err != nil {
+			return fmt.Errorf("updating storehouses state: %w", err)
+		}
+
+		movements := domain.ReservationMovements(oldReservation.ID, deltas, time.Now().UTC().Truncate(time.Microsecond))
+
+		err = service.movementRepo.Append(ctx, movements)
+		if err != nil {
+			return fmt.Errorf("recording stock movements: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("release: %w", err)
+	}
+
+	totalCost, err := reservation.GetTotalCost(storehouses, items, service.costs)
+	if err != nil {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("release: calculating total cost: %w", err)
+	}
+
+	return ports.ReservationResponseDTO{Reservation: reservation, TotalCost: totalCost, Reoptimization: reoptimization}, nil
+}
+
+func (service Service) GetReservation(id string) (ports.ReservationResponseDTO, error) {
+	reservation, err := service.reservationRepo.GetByID(context.TODO(), id)
+	if err != nil {
+		return ports.ReservationResponseDTO{}, fmt.Errorf("get reservation: %w", err)
+	}
+
 	storehouses, err := service.storehouseRepo.GetAllAsMap(context.TODO())
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("release: receiving storehouses: %w", err)
+		return ports.ReservationResponseDTO{}, fmt.Errorf("get reservation: receiving storehouses: %w", err)
 	}
 
 	items, err := service.itemsRepo.GetAllAsMap(context.TODO())
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("release: receiving items: %w", err)
+		return ports.ReservationResponseDTO{}, fmt.Errorf("get reservation: receiving items: %w", err)
 	}
 
-	oldStorehousesState, err := reservation.GetUpdatedStorehouses(storehouses, domain.Release, items)
+	totalCost, err := reservation.GetTotalCost(storehouses, items, service.costs)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("release: calculating released storehouse state: %w", err)
+		return ports.ReservationResponseDTO{}, fmt.Errorf("get reservation: calculating total cost: %w", err)
 	}
 
-	if len(itemsToRelease) > 0 {
-		err = reservation.Release(itemsToRelease)
-		if err != nil {
-			return ports.ReservationResponseDTO{}, fmt.Errorf("release: calculating new reservation state: %w", err)
-		}
+	return ports.ReservationResponseDTO{Reservation: reservation, TotalCost: totalCost}, nil
+}
+
+// ListReservations returns a page of reservations ordered by creation time.
+// One extra reservation is requested to find out whether the next page exists
+func (service Service) ListReservations(request ports.ListReservationsRequestDTO) (ports.ListReservationsResponseDTO, error) {
+	after, err := decodeCursor(request.Cursor)
+	if err != nil {
+		return ports.ListReservationsResponseDTO{}, fmt.Errorf("list reservations: %w", err)
+	}
+
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	reservations, err := service.reservationRepo.List(context.TODO(), ports.ReservationFilter{
+		StorehouseID: request.StorehouseID,
+		ItemID:       request.ItemID,
+		MinLatitude:  request.MinLatitude,
+		MaxLatitude:  request.MaxLatitude,
+		MinLongitude: request.MinLongitude,
+		MaxLongitude: request.MaxLongitude,
+		CreatedFrom:  request.CreatedFrom,
+		CreatedTo:    request.CreatedTo,
+		After:        after,
+		Limit:        limit + 1,
+	})
+	if err != nil {
+		return ports.ListReservationsResponseDTO{}, fmt.Errorf("list reservations: %w", err)
+	}
+
+	response := ports.ListReservationsResponseDTO{Reservations: reservations}
+	if len(reservations) > limit {
+		response.Reservations = reservations[:limit]
+		response.NextCursor = encodeCursor(reservations[limit-1])
 	}
 
-	needToDeleteReservation := len(itemsToRelease) == 0 || len(reservation.Entries) == 0
+	return response, nil
+}
+
+// ChangeStatus moves the reservation to the next lifecycle status.
+// Cancellation makes reserved items available again, while shipping takes them away from storehouses
+func (service Service) ChangeStatus(id string, status domain.ReservationStatus) (domain.Reservation, error) {
+	return retryOnConcurrentModification(func() (domain.Reservation, error) {
+		return service.changeStatus(id, status)
+	})
+}
+
+func (service Service) changeStatus(id string, status domain.ReservationStatus) (domain.Reservation, error) {
+	reservation, err := service.reservationRepo.GetByID(context.TODO(), id)
+	if err != nil {
+		return domain.Reservation{}, fmt.Errorf("change status: receiving reservation: %w", err)
+	}
+
+	err = reservation.TransitTo(status)
+	if err != nil {
+		return domain.Reservation{}, fmt.Errorf("change status: %w", err)
+	}
+
+	err = service.transactions.WithinTransaction(context.TODO(), func(ctx context.Context) error {
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		var movements []domain.StockMovement
+		switch status {
+		case domain.Cancelled:
+			movements = domain.ReservationMovements(reservation.ID, reservation.GetStockDeltas(domain.Release), now)
+		case domain.Shipped:
+			movements = domain.NewLedgerMovements(domain.ShipMovement, reservation.ID, reservation.GetStockDeltas(domain.Ship), now)
+		}
 
-	if needToDeleteReservation {
-		err = service.reservationRepo.Delete(context.TODO(), reservationID)
+		if len(movements) > 0 {
+			_, err := service.storehouseRepo.LockItems(ctx, reservation.ItemIDs())
+			if err != nil {
+				return fmt.Errorf("locking storehouses items: %w", err)
+			}
+
+			err = service.storehouseRepo.ApplyDeltas(ctx, getMovementsDeltas(movements))
+			if err != nil {
+				return fmt.Errorf("updating storehouses state: %w", err)
+			}
+
+			err = service.movementRepo.Append(ctx, movements)
+			if err != nil {
+				return fmt.Errorf("recording stock movements: %w", err)
+			}
+		}
+
+		err := service.reservationRepo.Update(ctx, reservation)
 		if err != nil {
-			return ports.ReservationResponseDTO{}, fmt.Errorf("release: deleting reservation: %w", err)
+			return fmt.Errorf("updating reservation: %w", err)
 		}
 
-		reservation = domain.Reservation{}
-	} else {
-		err = service.reservationRepo.Update(context.TODO(), reservation)
+		return nil
+	})
+	if err != nil {
+		return domain.Reservation{}, fmt.Errorf("change status: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// errReservationNotExpired means the listed reservation was confirmed or released before the sweeper got to it
+var errReservationNotExpired = errors.New("reservation is not expired")
+
+// ReleaseExpired releases expired reservations one by one the same way as Release does.
+// Reservations released or confirmed concurrently by clients are skipped
+func (service Service) ReleaseExpired(now time.Time, limit int) (int, error) {
+	ids, err := service.reservationRepo.GetExpiredIDs(context.TODO(), now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("release expired: receiving expired reservations: %w", err)
+	}
+
+	released := 0
+
+	var resultErr error
+	for _, id := range ids {
+		_, err = retryOnConcurrentModification(func() (ports.ReservationResponseDTO, error) {
+			return service.release(context.TODO(), ports.ReleaseRequestDTO{ReservationID: id}, &now)
+		})
+		if errors.Is(err, ports.ErrReservationNotFound) || errors.Is(err, errReservationNotExpired) {
+			continue
+		}
 		if err != nil {
-			return ports.ReservationResponseDTO{}, fmt.Errorf("relese: updating reservation: %w", err)
+			resultErr = errors.Join(resultErr, fmt.Errorf("release expired: %w", err))
+			continue
 		}
+
+		released++
 	}
 
-	newStorehousesState, err := reservation.GetUpdatedStorehouses(oldStorehousesState, domain.Reserve, items)
+	return released, resultErr
+}
+
+// DeleteIdempotencyKeys forgets old keys, so a repeated request with such a key is performed again
+func (service Service) DeleteIdempotencyKeys(before time.Time, limit int) (int, error) {
+	deleted, err := service.idempotencyRepo.DeleteCreatedBefore(context.TODO(), before, limit)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("release: calculating new storehouses state: %w", err)
+		return 0, fmt.Errorf("delete idempotency keys: %w", err)
 	}
 
-	err = service.storehouseRepo.UpdateAll(context.TODO(), newStorehousesState)
+	return deleted, nil
+}
+
+// reoptimize places the reservation anew with the strategy it was made with and keeps the result only if it's cheaper.
+// Manual entries are not moved. Reservations made before strategies were stored use the default one.
+// releasedStorehouses must contain items of the reservation as if they were not reserved
+func (service Service) reoptimize(reservation domain.Reservation, releasedStorehouses map[domain.StoreHouseID]domain.StoreHouse,
+	storehouses map[domain.StoreHouseID]domain.StoreHouse, items map[domain.ItemID]domain.Item) (domain.Reservation, *ports.ReoptimizationDTO, error) {
+
+	strategy, err := service.getAllocationStrategy(reservation.Strategy)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("release: updating storehouse state: %w", err)
+		return domain.Reservation{}, nil, fmt.Errorf("choosing reoptimization strategy: %w", err)
 	}
 
-	totalCost, err := reservation.GetTotalCost(storehouses, items)
+	oldCost, err := reservation.GetTotalCost(storehouses, items, service.costs)
 	if err != nil {
-		return ports.ReservationResponseDTO{}, fmt.Errorf("release: calculating total cost: %w", err)
+		return domain.Reservation{}, nil, fmt.Errorf("calculating cost before reoptimization: %w", err)
 	}
 
-	return ports.ReservationResponseDTO{Reservation: reservation, TotalCost: totalCost}, nil
+	reallocated, err := reservation.Reallocate(releasedStorehouses, items, service.costs, strategy.Allocate)
+	if errors.Is(err, domain.ErrNotEnoughVehicles) {
+		return reservation, &ports.ReoptimizationDTO{OldTotalCost: oldCost, NewTotalCost: oldCost, Applied: false}, nil
+	}
+	if err != nil {
+		return domain.Reservation{}, nil, fmt.Errorf("reallocating reservation: %w", err)
+	}
+
+	newCost, err := reallocated.GetTotalCost(storehouses, items, service.costs)
+	if err != nil {
+		return domain.Reservation{}, nil, fmt.Errorf("calculating cost after reoptimization: %w", err)
+	}
+
+	if newCost >= oldCost {
+		return reservation, &ports.ReoptimizationDTO{OldTotalCost: oldCost, NewTotalCost: oldCost, Applied: false}, nil
+	}
+
+	return reallocated, &ports.ReoptimizationDTO{OldTotalCost: oldCost, NewTotalCost: newCost, Applied: true}, nil
+}
+
+func (service Service) getAllocationStrategy(name domain.AllocationStrategyName) (ports.AllocationStrategy, error) {
+	if name == "" {
+		name = domain.DefaultAllocationStrategy
+	}
+
+	strategy, ok := service.strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnknownAllocationStrategy, name)
+	}
+
+	return strategy, nil
+}
+
+func (service Service) GetUnreserved(request ports.GetUnreservedRequestDTO) (ports.GetUnreservedResponseDTO, error) {
+	ctx := context.TODO()
+
+	afterID, err := decodeItemCursor(request.Cursor)
+	if err != nil {
+		return ports.GetUnreservedResponseDTO{}, fmt.Errorf("get unreserved: %w", err)
+	}
+
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	// one more item is requested to know whether there is the next page
+	unreserved, err := service.storehouseRepo.GetUnreserved(ctx, ports.UnreservedFilter{
+		StorehouseID: request.StorehouseID,
+		ItemIDs:      request.ItemIDs,
+		AfterID:      afterID,
+		Limit:        limit + 1,
+	})
+	if err != nil {
+		return ports.GetUnreservedResponseDTO{}, fmt.Errorf("get unreserved: receiving unreserved: %w", err)
+	}
+
+	// an empty page of an existing storehouse is told apart from an unknown storehouse only by the extra lookup
+	if len(unreserved) == 0 {
+		_, err = service.storehouseRepo.GetByID(ctx, request.StorehouseID)
+		if err != nil {
+			return ports.GetUnreservedResponseDTO{}, fmt.Errorf("get unreserved: %w", err)
+		}
+	}
+
+	response := ports.GetUnreservedResponseDTO{StorehouseID: request.StorehouseID, Items: unreserved}
+	if len(unreserved) > limit {
+		response.Items = unreserved[:limit]
+		response.NextCursor = encodeItemCursor(unreserved[limit-1].Item.ID)
+	}
+
+	return response, nil
 }
 
-func (service Service) GetUnreserved(storehouseID domain.StoreHouseID) ([]domain.ItemData, error) {
-	allUnreserved, err := service.storehouseRepo.GetItemsByID(context.TODO(), storehouseID)
+func (service Service) GetAvailability(request ports.AvailabilityRequestDTO) (ports.AvailabilityResponseDTO, error) {
+	ctx := context.TODO()
+
+	items, err := service.itemsRepo.GetAllAsMap(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("get unreserved: receiving all unreserved: %w", err)
+		return ports.AvailabilityResponseDTO{}, fmt.Errorf("get availability: receiving items: %w", err)
 	}
 
-	unreserved := make([]domain.ItemData, 0)
-	for _, itemData := range allUnreserved {
-		unreserved = append(unreserved, itemData)
+	var unknownErr error
+	for _, itemID := range request.ItemIDs {
+		if _, ok := items[itemID]; !ok {
+			unknownErr = errors.Join(unknownErr, fmt.Errorf("%w: %s", domain.ErrUnknownItem, itemID))
+		}
+	}
+	if unknownErr != nil {
+		return ports.AvailabilityResponseDTO{}, fmt.Errorf("get availability: %w", unknownErr)
+	}
+
+	itemsByStorehouse, err := service.storehouseRepo.GetItemsInActiveStorehouses(ctx, request.ItemIDs)
+	if err != nil {
+		return ports.AvailabilityResponseDTO{}, fmt.Errorf("get availability: receiving storehouses stock: %w", err)
+	}
+
+	response := ports.AvailabilityResponseDTO{Items: make([]ports.ItemAvailabilityDTO, 0, len(request.ItemIDs))}
+	seen := make(map[domain.ItemID]bool)
+	for _, itemID := range request.ItemIDs {
+		if seen[itemID] {
+			continue
+		}
+		seen[itemID] = true
+
+		availability := ports.ItemAvailabilityDTO{ItemID: itemID, Storehouses: make([]ports.StorehouseAvailabilityDTO, 0)}
+		for storehouseID, itemsData := range itemsByStorehouse {
+			available := itemsData[itemID].Available
+			if available <= 0 {
+				continue
+			}
+
+			availability.Available += available
+			availability.Storehouses = append(availability.Storehouses,
+				ports.StorehouseAvailabilityDTO{StorehouseID: storehouseID, Available: available})
+		}
+
+		slices.SortFunc(availability.Storehouses, func(a, b ports.StorehouseAvailabilityDTO) int {
+			return cmp.Compare(a.StorehouseID, b.StorehouseID)
+		})
+
+		response.Items = append(response.Items, availability)
 	}
 
-	return unreserved, nil
+	return response, nil
 }
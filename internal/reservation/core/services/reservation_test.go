@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+const (
+	initialCount = 30
+	workers      = 8
+	iterations   = 50
+)
+
+func TestService_ConcurrentReserveAndRelease(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+
+	strategies := []domain.AllocationStrategyName{domain.NearestFirst, domain.CostOptimal, domain.FewestStorehouses}
+
+	var mu sync.Mutex
+	reservationIDs := make([]string, 0)
+
+	pickReservation := func(random *rand.Rand) (string, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(reservationIDs) == 0 {
+			return "", false
+		}
+
+		return reservationIDs[random.Intn(len(reservationIDs))], true
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(seed))
+
+			for i := 0; i < iterations; i++ {
+				switch random.Intn(3) {
+				case 0:
+					response, err := service.Reserve(domain.ReserveRequest{
+						DestinationLocation: domain.Location{Latitude: 50, Longitude: 50},
+						ItemsToReserve: []domain.ReserveEntry{
+							{ItemID: domain.ItemID([]string{"1", "2"}[random.Intn(2)]), Count: random.Intn(5) + 1},
+						},
+						Strategy: strategies[random.Intn(len(strategies))],
+					}, "")
+					if err == nil {
+						mu.Lock()
+						reservationIDs = append(reservationIDs, response.Reservation.ID)
+						mu.Unlock()
+					}
+				case 1:
+					id, ok := pickReservation(random)
+					if !ok {
+						continue
+					}
+
+					// the same reservation is released by two goroutines at once
+					var releaseWG sync.WaitGroup
+					for j := 0; j < 2; j++ {
+						releaseWG.Add(1)
+						go func() {
+							defer releaseWG.Done()
+							_, _ = service.Release(ports.ReleaseRequestDTO{ReservationID: id}, "")
+						}()
+					}
+					releaseWG.Wait()
+				case 2:
+					id, ok := pickReservation(random)
+					if !ok {
+						continue
+					}
+
+					_, _ = service.Release(ports.ReleaseRequestDTO{
+						ReservationID:  id,
+						ItemsToRelease: []domain.ReleaseEntry{{ItemID: "1", Count: 1}},
+						Reoptimize:     true,
+					}, "")
+				}
+			}
+		}(int64(worker))
+	}
+
+	wg.Wait()
+
+	assert.Empty(t, storehouseRepo.violations)
+
+	// every item is either in stock or in exactly one reservation, and the ledger knows about every change
+	for _, storehouseID := range []domain.StoreHouseID{"a", "b", "c"} {
+		for itemID := range items {
+			inStock := storehouseRepo.count(storehouseID, itemID)
+			reserved := reservationRepo.reservedCount(storehouseID, itemID)
+
+			assert.GreaterOrEqual(t, inStock, 0)
+			assert.Equal(t, initialCount, inStock+reserved, "storehouse: %s, item: %s", storehouseID, itemID)
+			assert.Equal(t, reserved, storehouseRepo.reserved(storehouseID, itemID), "storehouse: %s, item: %s", storehouseID, itemID)
+
+			levels, err := service.movementRepo.GetStockAt(context.Background(), storehouseID, itemID, time.Now().Add(time.Hour))
+			assert.NoError(t, err)
+
+			level := domain.StockLevel{ItemID: itemID}
+			if len(levels) > 0 {
+				level = levels[0]
+			}
+
+			expected := domain.StockLevel{ItemID: itemID, OnHand: inStock - initialCount + reserved, Reserved: reserved, Available: inStock - initialCount}
+			assert.Equal(t, expected, level)
+		}
+	}
+}
+
+func TestService_ReserveRollsBackStockIfReservationIsNotSaved(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+
+	reservationRepo.saveErr = errors.New("connection lost")
+
+	_, err := service.Reserve(domain.ReserveRequest{
+		DestinationLocation: domain.Location{Latitude: 50, Longitude: 50},
+		ItemsToReserve:      []domain.ReserveEntry{{ItemID: "1", Count: 40}},
+	}, "")
+	assert.ErrorIs(t, err, reservationRepo.saveErr)
+
+	for _, storehouseID := range []domain.StoreHouseID{"a", "b", "c"} {
+		assert.Equal(t, initialCount, storehouseRepo.count(storehouseID, "1"), "storehouse: %s", storehouseID)
+	}
+}
+
+func TestService_ReleaseWithReoptimizationKeepsPlacement(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	storehouses["a"].ItemsData["2"] = domain.ItemData{Item: items["2"], OnHand: 3, Available: 3}
+	for i, id := range []domain.StoreHouseID{"b", "c"} {
+		storehouse := storehouses[id]
+		storehouse.Location.Longitude = float64(60 + 10*i)
+		storehouses[id] = storehouse
+	}
+	service, _, _ := newMemoryService(storehouses, items)
+
+	destination := domain.Location{Latitude: 50, Longitude: 51}
+	reoptimizeAfterRelease := func(id string) domain.Reservation {
+		response, err := service.Release(ports.ReleaseRequestDTO{
+			ReservationID:  id,
+			ItemsToRelease: []domain.ReleaseEntry{{ItemID: "2", Count: 1}},
+			Reoptimize:     true,
+		}, "")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		return response.Reservation
+	}
+
+	// the cost-optimal strategy would take most of the items from the nearest "a" and the rest from "b"
+	single, err := service.Reserve(domain.ReserveRequest{
+		DestinationLocation: destination,
+		ItemsToReserve:      []domain.ReserveEntry{{ItemID: "1", Count: 5}, {ItemID: "2", Count: 5}},
+		Strategy:            domain.SingleStorehouse,
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, domain.SingleStorehouse, single.Reservation.Strategy)
+
+	assert.Equal(t, []domain.ReserveEntry{
+		{ItemID: "1", Count: 5, SourceStorehouseID: "b"},
+		{ItemID: "2", Count: 4, SourceStorehouseID: "b"},
+	}, reoptimizeAfterRelease(single.Reservation.ID).Entries)
+
+	// the cost-optimal strategy would move the manual entry to "a"
+	manual, err := service.Reserve(domain.ReserveRequest{
+		DestinationLocation: destination,
+		ItemsToReserve:      []domain.ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "c"}, {ItemID: "2", Count: 2}},
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, domain.CostOptimal, manual.Reservation.Strategy)
+
+	assert.Equal(t, []domain.ReserveEntry{
+		{ItemID: "1", Count: 5, SourceStorehouseID: "c", Manual: true},
+		{ItemID: "2", Count: 1, SourceStorehouseID: "a"},
+	}, reoptimizeAfterRelease(manual.Reservation.ID).Entries)
+}
+
+func TestService_ReleaseExpired(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+
+	held, err := service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "a"}},
+		HoldFor:        domain.Duration(time.Minute),
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	_, err = service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "2", Count: 5, SourceStorehouseID: "a"}},
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	released, err := service.ReleaseExpired(time.Now(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, released)
+
+	released, err = service.ReleaseExpired(held.Reservation.ExpiresAt.Add(time.Second), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, released)
+
+	assert.Equal(t, initialCount, storehouseRepo.count("a", "1"))
+	assert.Equal(t, initialCount-5, storehouseRepo.count("a", "2"))
+	assert.Equal(t, 0, reservationRepo.reservedCount("a", "1"))
+}
+
+func TestService_ReleaseExpiredConfirmedAfterListing(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+
+	held, err := service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "a"}},
+		HoldFor:        domain.Duration(time.Minute),
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the client confirms the hold between listing and releasing
+	reservationRepo.afterGetExpiredIDs = func() {
+		_, err := service.ChangeStatus(held.Reservation.ID, domain.Confirmed)
+		assert.NoError(t, err)
+	}
+
+	released, err := service.ReleaseExpired(held.Reservation.ExpiresAt.Add(time.Second), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, released)
+
+	reservation, err := service.GetReservation(held.Reservation.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, domain.Confirmed, reservation.Reservation.Status)
+	}
+	assert.Equal(t, initialCount-5, storehouseRepo.count("a", "1"))
+}
+
+func TestService_ChangeStatus(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, _ := newMemoryService(storehouses, items)
+
+	reserve := func(itemID domain.ItemID) string {
+		response, err := service.Reserve(domain.ReserveRequest{
+			ItemsToReserve: []domain.ReserveEntry{{ItemID: itemID, Count: 5, SourceStorehouseID: "a"}},
+		}, "")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		return response.Reservation.ID
+	}
+
+	shipped := reserve("1")
+	for _, status := range []domain.ReservationStatus{domain.Confirmed, domain.Picked, domain.Shipped} {
+		_, err := service.ChangeStatus(shipped, status)
+		assert.NoError(t, err)
+	}
+
+	_, err := service.Release(ports.ReleaseRequestDTO{ReservationID: shipped}, "")
+	assert.ErrorIs(t, err, domain.ErrReservationNotModifiable)
+
+	cancelled := reserve("2")
+	_, err = service.ChangeStatus(cancelled, domain.Confirmed)
+	assert.NoError(t, err)
+
+	reservation, err := service.ChangeStatus(cancelled, domain.Cancelled)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.Cancelled, reservation.Status)
+
+	_, err = service.ChangeStatus(cancelled, domain.Cancelled)
+	assert.ErrorIs(t, err, domain.ErrInvalidStatusTransition)
+
+	// shipped items are consumed, cancelled ones are returned
+	assert.Equal(t, initialCount-5, storehouseRepo.count("a", "1"))
+	assert.Equal(t, initialCount, storehouseRepo.count("a", "2"))
+	assert.Equal(t, 0, storehouseRepo.reserved("a", "1"))
+	assert.Equal(t, 0, storehouseRepo.reserved("a", "2"))
+}
+
+func TestService_ReserveWithIdempotencyKey(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, _ := newMemoryService(storehouses, items)
+
+	request := domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "a"}},
+	}
+
+	// retries of a timed out request may come concurrently
+	responses := make([]ports.ReservationResponseDTO, workers)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var err error
+			responses[i], err = service.Reserve(request, "key")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, response := range responses {
+		assert.Equal(t, responses[0].Reservation.ID, response.Reservation.ID)
+	}
+	assert.Equal(t, initialCount-5, storehouseRepo.count("a", "1"))
+
+	request.ItemsToReserve[0].Count = 6
+	_, err := service.Reserve(request, "key")
+	assert.ErrorIs(t, err, ports.ErrIdempotencyKeyConflict)
+
+	_, err = service.Release(ports.ReleaseRequestDTO{ReservationID: responses[0].Reservation.ID}, "key")
+	assert.ErrorIs(t, err, ports.ErrIdempotencyKeyConflict)
+}
+
+func TestService_DeleteIdempotencyKeys(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, _ := newMemoryService(storehouses, items)
+
+	request := domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "a"}},
+	}
+
+	first, err := service.Reserve(request, "key")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// keys used after the moment are kept
+	deleted, err := service.DeleteIdempotencyKeys(time.Now().Add(-time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	deleted, err = service.DeleteIdempotencyKeys(time.Now().Add(time.Second), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	// a forgotten key is performed as a new request
+	second, err := service.Reserve(request, "key")
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, first.Reservation.ID, second.Reservation.ID)
+	}
+	assert.Equal(t, initialCount-10, storehouseRepo.count("a", "1"))
+}
+
+func TestService_ListReservations(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, _, _ := newMemoryService(storehouses, items)
+
+	created := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		response, err := service.Reserve(domain.ReserveRequest{
+			ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 1, SourceStorehouseID: []domain.StoreHouseID{"a", "b"}[i%2]}},
+		}, "")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		created = append(created, response.Reservation.ID)
+	}
+
+	listed := make([]string, 0)
+	request := ports.ListReservationsRequestDTO{StorehouseID: "a", Limit: 2}
+	for {
+		page, err := service.ListReservations(request)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		for _, reservation := range page.Reservations {
+			listed = append(listed, reservation.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		request.Cursor = page.NextCursor
+	}
+
+	assert.ElementsMatch(t, []string{created[0], created[2], created[4]}, listed)
+
+	_, err := service.ListReservations(ports.ListReservationsRequestDTO{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ports.ErrInvalidCursor)
+}
+
+func TestService_Quote(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+
+	request := domain.ReserveRequest{
+		DestinationLocation: domain.Location{Latitude: 50, Longitude: 51},
+		ItemsToReserve: []domain.ReserveEntry{
+			{ItemID: "1", Count: initialCount + 5},
+			{ItemID: "2", Count: 5, SourceStorehouseID: "c"},
+		},
+		Explain: true,
+	}
+
+	quote, err := service.Quote(request)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, quote.Shipments, 2)
+	assert.Len(t, quote.Explanation, 2)
+	assert.Equal(t, initialCount, storehouseRepo.count("a", "1"))
+	assert.Empty(t, reservationRepo.reservations)
+
+	reserved, err := service.Reserve(request, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.ElementsMatch(t, quote.Entries, reserved.Reservation.Entries)
+	assert.InDelta(t, reserved.TotalCost, quote.TotalCost, 1e-6)
+	assert.Equal(t, quote.Explanation, reserved.Explanation)
+}
+
+func TestService_GetAvailability(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	deactivated := storehouses["c"]
+	deactivated.Deactivated = true
+	storehouses["c"] = deactivated
+	service, _, _ := newMemoryService(storehouses, items)
+
+	_, err := service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 5, SourceStorehouseID: "a"}},
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	unreserved, err := service.GetUnreserved(ports.GetUnreservedRequestDTO{StorehouseID: "a", ItemIDs: []domain.ItemID{"1"}})
+	assert.NoError(t, err)
+	if assert.Len(t, unreserved.Items, 1) {
+		assert.Equal(t, initialCount-5, unreserved.Items[0].Available)
+	}
+
+	availability, err := service.GetAvailability(ports.AvailabilityRequestDTO{ItemIDs: []domain.ItemID{"1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []ports.ItemAvailabilityDTO{{
+		ItemID:    "1",
+		Available: 2*initialCount - 5,
+		Storehouses: []ports.StorehouseAvailabilityDTO{
+			{StorehouseID: "a", Available: initialCount - 5},
+			{StorehouseID: "b", Available: initialCount},
+		},
+	}}, availability.Items)
+
+	_, err = service.GetAvailability(ports.AvailabilityRequestDTO{ItemIDs: []domain.ItemID{"1", "unknown"}})
+	assert.ErrorIs(t, err, domain.ErrUnknownItem)
+}
+
+func TestService_GetUnreserved(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	storehouses["empty"] = domain.StoreHouse{ID: "empty", Name: "empty", ItemsData: make(map[domain.ItemID]domain.ItemData)}
+	service, _, _ := newMemoryService(storehouses, items)
+
+	firstPage, err := service.GetUnreserved(ports.GetUnreservedRequestDTO{StorehouseID: "a", Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, firstPage.Items, 1) && assert.NotEmpty(t, firstPage.NextCursor) {
+		assert.Equal(t, items["1"], firstPage.Items[0].Item)
+	}
+
+	secondPage, err := service.GetUnreserved(ports.GetUnreservedRequestDTO{StorehouseID: "a", Cursor: firstPage.NextCursor, Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, secondPage.Items, 1) {
+		assert.Equal(t, domain.ItemID("2"), secondPage.Items[0].Item.ID)
+	}
+	assert.Empty(t, secondPage.NextCursor)
+
+	empty, err := service.GetUnreserved(ports.GetUnreservedRequestDTO{StorehouseID: "empty"})
+	assert.NoError(t, err)
+	assert.Empty(t, empty.Items)
+
+	_, err = service.GetUnreserved(ports.GetUnreservedRequestDTO{StorehouseID: "unknown"})
+	assert.ErrorIs(t, err, domain.ErrUnknownStorehouse)
+}
+
+func getTestStorehousesAndItems() (map[domain.StoreHouseID]domain.StoreHouse, map[domain.ItemID]domain.Item) {
+	items := map[domain.ItemID]domain.Item{
+		"1": {ID: "1", Name: "1", Size: &domain.Size{LengthMeters: 1, WidthMeters: 1, HeightMeters: 1}, WeightKilograms: 10},
+		"2": {ID: "2", Name: "2", Size: &domain.Size{LengthMeters: 2, WidthMeters: 1, HeightMeters: 1}, WeightKilograms: 5},
+	}
+
+	storehouses := make(map[domain.StoreHouseID]domain.StoreHouse)
+	for i, id := range []domain.StoreHouseID{"a", "b", "c"} {
+		storehouses[id] = domain.StoreHouse{
+			ID:       id,
+			Name:     string(id),
+			Location: domain.Location{Latitude: 50, Longitude: float64(51 + i)},
+			ItemsData: map[domain.ItemID]domain.ItemData{
+				"1": {Item: items["1"], OnHand: initialCount, Available: initialCount},
+				"2": {Item: items["2"], OnHand: initialCount, Available: initialCount},
+			},
+		}
+	}
+
+	return storehouses, items
+}
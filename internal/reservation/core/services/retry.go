@@ -0,0 +1,26 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+const (
+	maxConcurrentAttempts = 10
+)
+
+// retryOnConcurrentModification repeats the operation while it fails because the data it read was changed concurrently.
+// The operation must not leave any changes when it fails with ports.ErrConcurrentModification
+func retryOnConcurrentModification[T any](operation func() (T, error)) (T, error) {
+	var result T
+	var err error
+	for attempt := 0; attempt < maxConcurrentAttempts; attempt++ {
+		result, err = operation()
+		if !errors.Is(err, ports.ErrConcurrentModification) {
+			return result, err
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+// StockService changes the stock outside of reservations and answers questions about the stock history.
+// Every change is recorded in the stock ledger
+type StockService struct {
+	storehouseRepo ports.StorehouseRepository
+	itemsRepo      ports.ItemsRepository
+	movementRepo   ports.StockMovementRepository
+	transactions   ports.TransactionManager
+}
+
+func NewStockService(storehouseRepo ports.StorehouseRepository, itemsRepo ports.ItemsRepository,
+	movementRepo ports.StockMovementRepository, transactions ports.TransactionManager) *StockService {
+	return &StockService{storehouseRepo: storehouseRepo, itemsRepo: itemsRepo, movementRepo: movementRepo, transactions: transactions}
+}
+
+func (service StockService) RecordMovement(movementType domain.MovementType,
+	request ports.StockMovementRequestDTO) (domain.StockMovement, error) {
+
+	movement, err := domain.NewStockMovement(movementType, request.StorehouseID, request.ItemID, request.Quantity,
+		request.Reason, request.Actor, request.Comment, time.Now().UTC().Truncate(time.Microsecond))
+	if err != nil {
+		return domain.StockMovement{}, fmt.Errorf("record stock movement: %w", err)
+	}
+
+	err = service.transactions.WithinTransaction(context.TODO(), func(ctx context.Context) error {
+		_, err := service.itemsRepo.GetByID(ctx, movement.ItemID)
+		if err != nil {
+			return err
+		}
+
+		// the storehouse is checked under the lock, so a concurrent deactivation either sees the movement
+		// or is seen by it
+		storehouses, err := service.storehouseRepo.LockItems(ctx, []domain.ItemID{movement.ItemID})
+		if err != nil {
+			return fmt.Errorf("locking storehouses items: %w", err)
+		}
+
+		storehouse, ok := storehouses[movement.StorehouseID]
+		if !ok {
+			return fmt.Errorf("%w: %s", domain.ErrUnknownStorehouse, movement.StorehouseID)
+		}
+
+		// items may still leave a deactivated storehouse, but nothing is brought there
+		if storehouse.Deactivated && movement.GetStockDelta().Count > 0 {
+			return fmt.Errorf("%w: %s", domain.ErrStorehouseDeactivated, storehouse.ID)
+		}
+
+		err = service.storehouseRepo.ApplyDeltas(ctx, []domain.StockDelta{movement.GetStockDelta()})
+		if err != nil {
+			return fmt.Errorf("applying movement: %w", err)
+		}
+
+		err = service.movementRepo.Append(ctx, []domain.StockMovement{movement})
+		if err != nil {
+			return fmt.Errorf("saving movement: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return domain.StockMovement{}, fmt.Errorf("record stock movement: %w", err)
+	}
+
+	return movement, nil
+}
+
+func (service StockService) GetStock(storehouseID domain.StoreHouseID) (ports.GetStockResponseDTO, error) {
+	storehouse, err := service.storehouseRepo.GetByID(context.TODO(), storehouseID)
+	if err != nil {
+		return ports.GetStockResponseDTO{}, fmt.Errorf("get stock: %w", err)
+	}
+
+	items := make([]domain.ItemData, 0, len(storehouse.ItemsData))
+	for _, itemData := range storehouse.ItemsData {
+		items = append(items, itemData)
+	}
+
+	slices.SortFunc(items, func(a, b domain.ItemData) int {
+		return cmp.Compare(a.Item.ID, b.Item.ID)
+	})
+
+	return ports.GetStockResponseDTO{StorehouseID: storehouse.ID, Items: items}, nil
+}
+
+func (service StockService) GetStockAt(request ports.StockAtRequestDTO) (ports.StockAtResponseDTO, error) {
+	at := time.Now().UTC()
+	if request.At != nil {
+		at = request.At.UTC()
+	}
+
+	_, err := service.storehouseRepo.GetByID(context.TODO(), request.StorehouseID)
+	if err != nil {
+		return ports.StockAtResponseDTO{}, fmt.Errorf("get stock at: %w", err)
+	}
+
+	levels, err := service.movementRepo.GetStockAt(context.TODO(), request.StorehouseID, request.ItemID, at)
+	if err != nil {
+		return ports.StockAtResponseDTO{}, fmt.Errorf("get stock at: %w", err)
+	}
+
+	return ports.StockAtResponseDTO{StorehouseID: request.StorehouseID, At: at, Items: levels}, nil
+}
+
+func getMovementsDeltas(movements []domain.StockMovement) []domain.StockDelta {
+	deltas := make([]domain.StockDelta, 0, len(movements))
+	for _, movement := range movements {
+		deltas = append(deltas, domain.StockDelta{
+			StorehouseID: movement.StorehouseID,
+			ItemID:       movement.ItemID,
+			Count:        movement.AvailableDelta,
+			Reserved:     movement.ReservedDelta,
+		})
+	}
+
+	return domain.MergeStockDeltas(deltas)
+}
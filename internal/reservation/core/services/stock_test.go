@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+func TestStockService_RecordMovement(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, _ := newMemoryService(storehouses, items)
+	movementRepo := service.movementRepo.(*memoryStockMovementRepository)
+	stockService := NewStockService(storehouseRepo, service.itemsRepo, movementRepo, memoryTransactionManager{})
+
+	request := ports.StockMovementRequestDTO{StorehouseID: "a", ItemID: "1", Quantity: 10, Reason: domain.SupplierDelivery, Actor: "clerk"}
+	_, err := stockService.RecordMovement(domain.Receipt, request)
+	assert.NoError(t, err)
+	assert.Equal(t, initialCount+10, storehouseRepo.count("a", "1"))
+
+	request.Quantity, request.Reason = 5, domain.Damaged
+	_, err = stockService.RecordMovement(domain.WriteOff, request)
+	assert.NoError(t, err)
+	assert.Equal(t, initialCount+5, storehouseRepo.count("a", "1"))
+
+	request.Quantity, request.Reason = -3, domain.InventoryCount
+	_, err = stockService.RecordMovement(domain.Adjustment, request)
+	assert.NoError(t, err)
+	assert.Equal(t, initialCount+2, storehouseRepo.count("a", "1"))
+
+	request.Reason = domain.SupplierDelivery
+	_, err = stockService.RecordMovement(domain.Adjustment, request)
+	assert.ErrorIs(t, err, domain.ErrInvalidReasonCode)
+
+	// the stock never becomes negative and the failed movement is not recorded
+	request.Quantity, request.Reason = 2*initialCount, domain.Lost
+	_, err = stockService.RecordMovement(domain.WriteOff, request)
+	assert.ErrorIs(t, err, domain.ErrNotEnoughItemsInStorehouse)
+	assert.Equal(t, initialCount+2, storehouseRepo.count("a", "1"))
+
+	request.ItemID, request.Quantity = "3", 1
+	_, err = stockService.RecordMovement(domain.WriteOff, request)
+	assert.ErrorIs(t, err, domain.ErrUnknownItem)
+
+	assert.Len(t, movementRepo.movements, 3)
+	assert.Empty(t, storehouseRepo.violations)
+}
+
+// lockWait is long enough for a movement which is not blocked to read the storehouse
+const lockWait = 50 * time.Millisecond
+
+func TestStockService_ReceiptDuringDeactivation(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, _ := newMemoryService(storehouses, items)
+	stockService := NewStockService(storehouseRepo, service.itemsRepo, service.movementRepo, memoryTransactionManager{})
+
+	// the deactivation holds the storehouses lock while the receipt comes
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	deactivated := make(chan error)
+	go func() {
+		deactivated <- memoryTransactionManager{}.WithinTransaction(context.Background(), func(ctx context.Context) error {
+			err := storehouseRepo.LockStorehouses(ctx)
+			close(locked)
+			if err != nil {
+				return err
+			}
+
+			<-release
+
+			storehouse, err := storehouseRepo.GetByID(ctx, "a")
+			if err != nil {
+				return err
+			}
+
+			storehouse.Deactivated = true
+			return storehouseRepo.Update(ctx, storehouse)
+		})
+	}()
+	<-locked
+
+	received := make(chan error)
+	go func() {
+		_, err := stockService.RecordMovement(domain.Receipt, ports.StockMovementRequestDTO{
+			StorehouseID: "a", ItemID: "1", Quantity: 10, Reason: domain.SupplierDelivery, Actor: "clerk",
+		})
+		received <- err
+	}()
+
+	// gives the receipt time to read the storehouse before it's deactivated, if it reads without the lock
+	time.Sleep(lockWait)
+	close(release)
+
+	assert.NoError(t, <-deactivated)
+	assert.ErrorIs(t, <-received, domain.ErrStorehouseDeactivated)
+	assert.Equal(t, initialCount, storehouseRepo.count("a", "1"))
+}
+
+func TestStockService_GetStockAt(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, _ := newMemoryService(storehouses, items)
+	stockService := NewStockService(storehouseRepo, service.itemsRepo, service.movementRepo, memoryTransactionManager{})
+
+	// the memory storehouse starts with stock which is not in the ledger, so it's received again
+	_, err := stockService.RecordMovement(domain.Receipt, ports.StockMovementRequestDTO{
+		StorehouseID: "a", ItemID: "1", Quantity: 10, Reason: domain.SupplierDelivery, Actor: "clerk",
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	afterReceipt := time.Now()
+	time.Sleep(time.Millisecond)
+
+	reserved, err := service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 4, SourceStorehouseID: "a"}},
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	afterReserve := time.Now()
+	time.Sleep(time.Millisecond)
+
+	for _, status := range []domain.ReservationStatus{domain.Confirmed, domain.Picked, domain.Shipped} {
+		_, err = service.ChangeStatus(reserved.Reservation.ID, status)
+		assert.NoError(t, err)
+	}
+
+	expected := map[time.Time]domain.StockLevel{
+		afterReceipt.Add(-time.Hour): {},
+		afterReceipt:                 {ItemID: "1", OnHand: 10, Available: 10},
+		afterReserve:                 {ItemID: "1", OnHand: 10, Reserved: 4, Available: 6},
+		time.Now():                   {ItemID: "1", OnHand: 6, Available: 6},
+	}
+	for at, level := range expected {
+		response, err := stockService.GetStockAt(ports.StockAtRequestDTO{StorehouseID: "a", ItemID: "1", At: &at})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		if level.ItemID == "" {
+			assert.Empty(t, response.Items, "at: %s", at)
+		} else {
+			assert.Equal(t, []domain.StockLevel{level}, response.Items, "at: %s", at)
+		}
+	}
+
+	_, err = stockService.GetStockAt(ports.StockAtRequestDTO{StorehouseID: "z"})
+	assert.ErrorIs(t, err, domain.ErrUnknownStorehouse)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+type StorehouseService struct {
+	storehouseRepo  ports.StorehouseRepository
+	reservationRepo ports.ReservationRepository
+	movementRepo    ports.StockMovementRepository
+	transactions    ports.TransactionManager
+}
+
+func NewStorehouseService(storehouseRepo ports.StorehouseRepository, reservationRepo ports.ReservationRepository,
+	movementRepo ports.StockMovementRepository, transactions ports.TransactionManager) *StorehouseService {
+	return &StorehouseService{storehouseRepo: storehouseRepo, reservationRepo: reservationRepo, movementRepo: movementRepo,
+		transactions: transactions}
+}
+
+func (service StorehouseService) Create(request ports.CreateStorehouseRequestDTO) (domain.StoreHouse, error) {
+	err := request.Schedule.Validate()
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("create storehouse: %w", err)
+	}
+
+	storehouse := domain.StoreHouse{
+		ID:        request.ID,
+		Name:      request.Name,
+		Location:  request.Location,
+		Status:    domain.StorehouseOpen,
+		Schedule:  request.Schedule,
+		Vehicle:   request.Vehicle,
+		ItemsData: make(map[domain.ItemID]domain.ItemData),
+	}
+
+	err = service.storehouseRepo.Create(context.TODO(), storehouse)
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("create storehouse: %w", err)
+	}
+
+	return storehouse, nil
+}
+
+func (service StorehouseService) Get(id domain.StoreHouseID) (domain.StoreHouse, error) {
+	storehouse, err := service.storehouseRepo.GetByID(context.TODO(), id)
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("get storehouse: %w", err)
+	}
+
+	return storehouse, nil
+}
+
+// GetAll returns all storehouses including deactivated ones sorted by ID
+func (service StorehouseService) GetAll() ([]domain.StoreHouse, error) {
+	storehouses, err := service.storehouseRepo.GetAllAsMap(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("get storehouses: %w", err)
+	}
+
+	sorted := make([]domain.StoreHouse, 0, len(storehouses))
+	for _, storehouse := range storehouses {
+		sorted = append(sorted, storehouse)
+	}
+
+	slices.SortFunc(sorted, func(a, b domain.StoreHouse) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return sorted, nil
+}
+
+func (service StorehouseService) Update(request ports.UpdateStorehouseRequestDTO) (domain.StoreHouse, error) {
+	err := request.Schedule.Validate()
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("update storehouse: %w", err)
+	}
+
+	return service.modify(request.StorehouseID, func(storehouse *domain.StoreHouse) error {
+		storehouse.Name = request.Name
+		storehouse.Schedule = request.Schedule
+		storehouse.Vehicle = request.Vehicle
+		return nil
+	})
+}
+
+// Relocate changes the location of the storehouse, so open reservations get the new transport cost
+func (service StorehouseService) Relocate(request ports.RelocateStorehouseRequestDTO) (domain.StoreHouse, error) {
+	return service.modify(request.StorehouseID, func(storehouse *domain.StoreHouse) error {
+		storehouse.Location = request.Location
+		return nil
+	})
+}
+
+// SetStatus opens, closes or puts the storehouse into maintenance. The stock and open reservations are kept,
+// only new reservations skip storehouses which are not open. Status of a deactivated storehouse can't be changed
+func (service StorehouseService) SetStatus(request ports.StorehouseStatusRequestDTO) (domain.StoreHouse, error) {
+	err := request.Status.Validate()
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("update storehouse: %w", err)
+	}
+
+	return service.modify(request.StorehouseID, func(storehouse *domain.StoreHouse) error {
+		if storehouse.Deactivated {
+			return fmt.Errorf("%w: %s", domain.ErrStorehouseDeactivated, storehouse.ID)
+		}
+
+		storehouse.Status = request.Status
+		return nil
+	})
+}
+
+func (service StorehouseService) modify(id domain.StoreHouseID, change func(storehouse *domain.StoreHouse) error) (domain.StoreHouse, error) {
+	var storehouse domain.StoreHouse
+
+	err := service.transactions.WithinTransaction(context.TODO(), func(ctx context.Context) error {
+		var err error
+		storehouse, err = service.storehouseRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		err = change(&storehouse)
+		if err != nil {
+			return err
+		}
+
+		return service.storehouseRepo.Update(ctx, storehouse)
+	})
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("update storehouse: %w", err)
+	}
+
+	return storehouse, nil
+}
+
+// Deactivate stops using the storehouse for new reservations.
+// If a transfer plan is given, items left in the storehouse are moved to the target storehouse
+// and open reservations take their items from the target instead
+func (service StorehouseService) Deactivate(request ports.DeactivateStorehouseRequestDTO) (ports.DeactivationResponseDTO, error) {
+	return retryOnConcurrentModification(func() (ports.DeactivationResponseDTO, error) {
+		return service.deactivate(request)
+	})
+}
+
+func (service StorehouseService) deactivate(request ports.DeactivateStorehouseRequestDTO) (ports.DeactivationResponseDTO, error) {
+	response := ports.DeactivationResponseDTO{MovedReservationIDs: make([]string, 0)}
+
+	err := service.transactions.WithinTransaction(context.TODO(), func(ctx context.Context) error {
+		// reservations lock storehouses in shared mode, so none of them is in progress
+		// while open reservations are listed and moved
+		err := service.storehouseRepo.LockStorehouses(ctx)
+		if err != nil {
+			return fmt.Errorf("locking storehouses: %w", err)
+		}
+
+		storehouse, err := service.storehouseRepo.GetByID(ctx, request.StorehouseID)
+		if err != nil {
+			return err
+		}
+
+		if storehouse.Deactivated {
+			return fmt.Errorf("%w: %s", domain.ErrStorehouseDeactivated, storehouse.ID)
+		}
+
+		openReservations, err := service.reservationRepo.GetOpenByStorehouse(ctx, storehouse.ID)
+		if err != nil {
+			return fmt.Errorf("receiving open reservations: %w", err)
+		}
+
+		if len(openReservations) > 0 && request.TransferPlan == nil {
+			return fmt.Errorf("%w: %s has %d, transfer plan is required",
+				domain.ErrStorehouseHasOpenReservations, storehouse.ID, len(openReservations))
+		}
+
+		if request.TransferPlan != nil {
+			response.MovedReservationIDs, err = service.transfer(ctx, storehouse, openReservations, *request.TransferPlan)
+			if err != nil {
+				return err
+			}
+		}
+
+		storehouse.Deactivated = true
+		response.Storehouse = storehouse
+
+		return service.storehouseRepo.Update(ctx, storehouse)
+	})
+	if err != nil {
+		return ports.DeactivationResponseDTO{}, fmt.Errorf("deactivate storehouse: %w", err)
+	}
+
+	return response, nil
+}
+
+// transfer moves stock of the storehouse and entries of its open reservations to the target of the plan
+func (service StorehouseService) transfer(ctx context.Context, source domain.StoreHouse,
+	openReservations []domain.Reservation, plan domain.TransferPlan) ([]string, error) {
+
+	itemIDs := make([]domain.ItemID, 0, len(source.ItemsData))
+	for itemID := range source.ItemsData {
+		itemIDs = append(itemIDs, itemID)
+	}
+
+	storehouses, err := service.storehouseRepo.GetAllAsMap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("receiving storehouses: %w", err)
+	}
+
+	err = plan.Validate(source, storehouses)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := service.storehouseRepo.LockItems(ctx, itemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("locking storehouses items: %w", err)
+	}
+
+	// reserved units are moved with the rest of the stock, so entries only need to point to the target
+	deltas := plan.GetStockDeltas(locked[source.ID])
+
+	err = service.storehouseRepo.ApplyDeltas(ctx, deltas)
+	if err != nil {
+		return nil, fmt.Errorf("moving stock: %w", err)
+	}
+
+	movements := domain.NewLedgerMovements(domain.TransferMovement, "", deltas, time.Now().UTC().Truncate(time.Microsecond))
+
+	err = service.movementRepo.Append(ctx, movements)
+	if err != nil {
+		return nil, fmt.Errorf("recording stock movements: %w", err)
+	}
+
+	movedIDs := make([]string, 0, len(openReservations))
+	for _, reservation := range openReservations {
+		reservation.MoveEntries(source.ID, plan.TargetStorehouseID)
+
+		err = service.reservationRepo.Update(ctx, reservation)
+		if err != nil {
+			return nil, fmt.Errorf("moving reservation %s: %w", reservation.ID, err)
+		}
+
+		movedIDs = append(movedIDs, reservation.ID)
+	}
+
+	return movedIDs, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+func TestStorehouseService_Deactivate(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+	storehouseService := NewStorehouseService(storehouseRepo, reservationRepo, service.movementRepo, memoryTransactionManager{})
+
+	reserved, err := service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{
+			{ItemID: "1", Count: 5, SourceStorehouseID: "a"},
+			{ItemID: "1", Count: 2, SourceStorehouseID: "b"},
+		},
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	_, err = storehouseService.Deactivate(ports.DeactivateStorehouseRequestDTO{StorehouseID: "a"})
+	assert.ErrorIs(t, err, domain.ErrStorehouseHasOpenReservations)
+
+	_, err = storehouseService.Deactivate(ports.DeactivateStorehouseRequestDTO{
+		StorehouseID: "a",
+		TransferPlan: &domain.TransferPlan{TargetStorehouseID: "a"},
+	})
+	assert.ErrorIs(t, err, domain.ErrInvalidTransferPlan)
+
+	response, err := storehouseService.Deactivate(ports.DeactivateStorehouseRequestDTO{
+		StorehouseID: "a",
+		TransferPlan: &domain.TransferPlan{TargetStorehouseID: "b"},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.True(t, response.Storehouse.Deactivated)
+	assert.Equal(t, []string{reserved.Reservation.ID}, response.MovedReservationIDs)
+
+	assert.Equal(t, 0, storehouseRepo.count("a", "1"))
+	assert.Equal(t, 0, storehouseRepo.count("a", "2"))
+	assert.Equal(t, 2*initialCount-7, storehouseRepo.count("b", "1"))
+	assert.Equal(t, 2*initialCount, storehouseRepo.count("b", "2"))
+	assert.Equal(t, 0, storehouseRepo.reserved("a", "1"))
+	assert.Equal(t, 7, storehouseRepo.reserved("b", "1"))
+
+	reservation, err := service.GetReservation(reserved.Reservation.ID)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []domain.ReserveEntry{{ItemID: "1", Count: 7, SourceStorehouseID: "b", Manual: true}},
+		reservation.Reservation.Entries)
+
+	// new reservations never take items from the deactivated storehouse
+	_, err = service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "2", Count: 1, SourceStorehouseID: "a"}},
+	}, "")
+	assert.ErrorIs(t, err, domain.ErrStorehouseDeactivated)
+
+	_, err = storehouseService.Deactivate(ports.DeactivateStorehouseRequestDTO{StorehouseID: "a"})
+	assert.ErrorIs(t, err, domain.ErrStorehouseDeactivated)
+}
+
+func TestStorehouseService_ConcurrentDeactivateAndReserve(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+	storehouseService := NewStorehouseService(storehouseRepo, reservationRepo, service.movementRepo, memoryTransactionManager{})
+
+	var mu sync.Mutex
+	reservationIDs := make([]string, 0)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				response, err := service.Reserve(domain.ReserveRequest{
+					DestinationLocation: domain.Location{Latitude: 50, Longitude: 50},
+					ItemsToReserve:      []domain.ReserveEntry{{ItemID: "1", Count: 1, SourceStorehouseID: "a"}},
+				}, "")
+				if err == nil {
+					mu.Lock()
+					reservationIDs = append(reservationIDs, response.Reservation.ID)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	_, err := storehouseService.Deactivate(ports.DeactivateStorehouseRequestDTO{
+		StorehouseID: "a",
+		TransferPlan: &domain.TransferPlan{TargetStorehouseID: "b"},
+	})
+	assert.NoError(t, err)
+
+	wg.Wait()
+
+	// reservations made before the deactivation are moved, the ones made after it don't use the storehouse
+	for _, id := range reservationIDs {
+		reservation, err := service.GetReservation(id)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		for _, entry := range reservation.Reservation.Entries {
+			assert.NotEqual(t, domain.StoreHouseID("a"), entry.SourceStorehouseID)
+		}
+	}
+
+	assert.Equal(t, 0, storehouseRepo.reserved("a", "1"))
+	assert.Empty(t, storehouseRepo.violations)
+}
+
+func TestStorehouseService_SetStatus(t *testing.T) {
+	storehouses, items := getTestStorehousesAndItems()
+	service, storehouseRepo, reservationRepo := newMemoryService(storehouses, items)
+	storehouseService := NewStorehouseService(storehouseRepo, reservationRepo, service.movementRepo, memoryTransactionManager{})
+
+	_, err := storehouseService.SetStatus(ports.StorehouseStatusRequestDTO{StorehouseID: "a", Status: "broken"})
+	assert.ErrorIs(t, err, domain.ErrUnknownStorehouseStatus)
+
+	storehouse, err := storehouseService.SetStatus(ports.StorehouseStatusRequestDTO{
+		StorehouseID: "a",
+		Status:       domain.StorehouseMaintenance,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, domain.StorehouseMaintenance, storehouse.Status)
+
+	_, err = service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 1, SourceStorehouseID: "a"}},
+	}, "")
+	assert.ErrorIs(t, err, domain.ErrStorehouseNotOpen)
+
+	// the nearest storehouse is under maintenance, so the items are taken from the next one
+	reserved, err := service.Reserve(domain.ReserveRequest{
+		DestinationLocation: domain.Location{Latitude: 50, Longitude: 51},
+		ItemsToReserve:      []domain.ReserveEntry{{ItemID: "1", Count: 1}},
+	}, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []domain.ReserveEntry{{ItemID: "1", Count: 1, SourceStorehouseID: "b"}}, reserved.Reservation.Entries)
+
+	// the stock is kept during maintenance
+	assert.Equal(t, initialCount, storehouseRepo.count("a", "1"))
+
+	_, err = storehouseService.SetStatus(ports.StorehouseStatusRequestDTO{StorehouseID: "a", Status: domain.StorehouseOpen})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	_, err = service.Reserve(domain.ReserveRequest{
+		ItemsToReserve: []domain.ReserveEntry{{ItemID: "1", Count: 1, SourceStorehouseID: "a"}},
+	}, "")
+	assert.NoError(t, err)
+}
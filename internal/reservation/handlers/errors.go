@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/pkg/logger"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+const (
+	codeValidationFailed = "validation_failed"
+	codeInternalError    = "internal_error"
+)
+
+// ErrorResponseDTO is the body of every failed request.
+// Code describes the whole failure and matches the response status, Details describe each of joined errors
+type ErrorResponseDTO struct {
+	Code    string           `json:"code"`
+	Message string           `json:"message"`
+	Details []ErrorDetailDTO `json:"details"`
+}
+
+type ErrorDetailDTO struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Field is set for validation errors and contains the path of the invalid field
+	Field string `json:"field,omitempty"`
+}
+
+type errorKind struct {
+	err    error
+	code   string
+	status int
+}
+
+// errorKinds are checked in order with errors.Is
+var errorKinds = []errorKind{
+	{ErrInvalidJSON, "invalid_json", http.StatusBadRequest},
+	{ErrInvalidParams, "invalid_params", http.StatusBadRequest},
+
+	{domain.ErrUnknownStorehouse, "unknown_storehouse", http.StatusNotFound},
+	{domain.ErrUnknownItem, "unknown_item", http.StatusNotFound},
+	{ports.ErrReservationNotFound, "reservation_not_found", http.StatusNotFound},
+
+	{domain.ErrNotEnoughItemsInStorehouse, "not_enough_items_in_storehouse", http.StatusConflict},
+	{domain.ErrNotEnoughItemsInAllStorehouses, "not_enough_items_in_all_storehouses", http.StatusConflict},
+	{domain.ErrNotEnoughItemsInSingleStorehouse, "not_enough_items_in_single_storehouse", http.StatusConflict},
+	{domain.ErrNotEnoughItemsInReservation, "not_enough_items_in_reservation", http.StatusConflict},
+	{domain.ErrInvalidStatusTransition, "invalid_status_transition", http.StatusConflict},
+	{domain.ErrReservationNotModifiable, "reservation_not_modifiable", http.StatusConflict},
+	{ports.ErrIdempotencyKeyConflict, "idempotency_key_conflict", http.StatusConflict},
+	{ports.ErrConcurrentModification, "concurrent_modification", http.StatusConflict},
+	{domain.ErrStorehouseAlreadyExists, "storehouse_already_exists", http.StatusConflict},
+	{domain.ErrItemAlreadyExists, "item_already_exists", http.StatusConflict},
+	{domain.ErrStorehouseDeactivated, "storehouse_deactivated", http.StatusConflict},
+	{domain.ErrStorehouseHasOpenReservations, "storehouse_has_open_reservations", http.StatusConflict},
+	{domain.ErrItemDoesNotFitVehicle, "item_does_not_fit_vehicle", http.StatusConflict},
+	{domain.ErrNotEnoughVehicles, "not_enough_vehicles", http.StatusConflict},
+	{domain.ErrStorehouseNotOpen, "storehouse_not_open", http.StatusConflict},
+	{domain.ErrStorehouseCannotDispatch, "storehouse_cannot_dispatch", http.StatusConflict},
+
+	{domain.ErrUnknownAllocationStrategy, "unknown_allocation_strategy", http.StatusUnprocessableEntity},
+	{domain.ErrUnknownReservationStatus, "unknown_reservation_status", http.StatusUnprocessableEntity},
+	{domain.ErrInvalidReleaseItems, "invalid_release_items", http.StatusUnprocessableEntity},
+	{ports.ErrInvalidCursor, "invalid_cursor", http.StatusUnprocessableEntity},
+	{domain.ErrInvalidTransferPlan, "invalid_transfer_plan", http.StatusUnprocessableEntity},
+	{domain.ErrUnknownMovementType, "unknown_movement_type", http.StatusUnprocessableEntity},
+	{domain.ErrInvalidReasonCode, "invalid_reason_code", http.StatusUnprocessableEntity},
+	{domain.ErrInvalidQuantity, "invalid_quantity", http.StatusUnprocessableEntity},
+	{domain.ErrUnknownStorehouseStatus, "unknown_storehouse_status", http.StatusUnprocessableEntity},
+	{domain.ErrInvalidSchedule, "invalid_schedule", http.StatusUnprocessableEntity},
+	{domain.ErrInvalidDispatchWindow, "invalid_dispatch_window", http.StatusUnprocessableEntity},
+	{domain.ErrManualEntriesInSeveralStorehouses, "manual_entries_in_several_storehouses", http.StatusUnprocessableEntity},
+}
+
+// statusPriority decides the status of a response with several errors of different kinds:
+// the request must be valid first, then refer to existing entities, and only then conflicts make sense
+var statusPriority = []int{
+	http.StatusBadRequest,
+	http.StatusUnprocessableEntity,
+	http.StatusNotFound,
+	http.StatusConflict,
+}
+
+// ErrorHandler writes errors added to the context by handlers as ErrorResponseDTO.
+// Unknown errors are logged and hidden from the client behind 500
+func ErrorHandler(logger logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		errs := make([]error, 0, len(c.Errors))
+		for _, err := range c.Errors {
+			errs = append(errs, err.Err)
+		}
+
+		err := errors.Join(errs...)
+
+		status, response := newErrorResponse(err)
+		if status == http.StatusInternalServerError {
+			logger.Error(err)
+		}
+
+		c.AbortWithStatusJSON(status, response)
+	}
+}
+
+func newErrorResponse(err error) (int, ErrorResponseDTO) {
+	details := make([]ErrorDetailDTO, 0)
+	detailStatuses := make([]int, 0)
+
+	for _, part := range splitJoined(err) {
+		var validationErrs validator.ValidationErrors
+		if errors.As(part, &validationErrs) {
+			for _, fieldErr := range validationErrs {
+				details = append(details, ErrorDetailDTO{
+					Code:    codeValidationFailed,
+					Message: fieldErr.Error(),
+					Field:   fieldErr.Namespace(),
+				})
+				detailStatuses = append(detailStatuses, http.StatusUnprocessableEntity)
+			}
+
+			continue
+		}
+
+		kind, known := findErrorKind(part)
+		if !known {
+			return http.StatusInternalServerError, ErrorResponseDTO{
+				Code:    codeInternalError,
+				Message: http.StatusText(http.StatusInternalServerError),
+				Details: []ErrorDetailDTO{},
+			}
+		}
+
+		details = append(details, ErrorDetailDTO{Code: kind.code, Message: part.Error()})
+		detailStatuses = append(detailStatuses, kind.status)
+	}
+
+	for _, status := range statusPriority {
+		i := slices.Index(detailStatuses, status)
+		if i == -1 {
+			continue
+		}
+
+		return status, ErrorResponseDTO{Code: details[i].Code, Message: err.Error(), Details: details}
+	}
+
+	return http.StatusInternalServerError, ErrorResponseDTO{
+		Code:    codeInternalError,
+		Message: http.StatusText(http.StatusInternalServerError),
+		Details: []ErrorDetailDTO{},
+	}
+}
+
+func findErrorKind(err error) (errorKind, bool) {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			return kind, true
+		}
+	}
+
+	return errorKind{}, false
+}
+
+// splitJoined returns errors joined with errors.Join, even if they are wrapped with additional context.
+// An error without joined errors inside is returned as is, so its message keeps the context
+func splitJoined(err error) []error {
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() []error }:
+		parts := make([]error, 0)
+		for _, inner := range wrapped.Unwrap() {
+			parts = append(parts, splitJoined(inner)...)
+		}
+
+		return parts
+	case interface{ Unwrap() error }:
+		if inner := wrapped.Unwrap(); inner != nil {
+			if parts := splitJoined(inner); len(parts) > 1 {
+				return parts
+			}
+		}
+	}
+
+	return []error{err}
+}
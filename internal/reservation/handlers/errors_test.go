@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+func TestNewErrorResponse_JoinedErrors(t *testing.T) {
+	err := fmt.Errorf("reserve: building reservation: %w", errors.Join(
+		fmt.Errorf("%w, item: %s", domain.ErrNotEnoughItemsInAllStorehouses, "1"),
+		fmt.Errorf("%w: %s", domain.ErrUnknownStorehouse, "x"),
+	))
+
+	status, response := newErrorResponse(err)
+
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "unknown_storehouse", response.Code)
+	assert.Equal(t, []ErrorDetailDTO{
+		{Code: "not_enough_items_in_all_storehouses", Message: "not enough items in all storehouses, item: 1"},
+		{Code: "unknown_storehouse", Message: "unknown storehouse: x"},
+	}, response.Details)
+}
+
+func TestNewErrorResponse_ValidationErrors(t *testing.T) {
+	err := validator.New().Struct(ports.ReleaseRequestDTO{})
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+
+	status, response := newErrorResponse(err)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, codeValidationFailed, response.Code)
+	assert.Len(t, response.Details, 1)
+	assert.Equal(t, "ReleaseRequestDTO.ReservationID", response.Details[0].Field)
+}
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(ErrorHandler(zap.NewNop().Sugar()))
+	engine.GET("/conflict", func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("release: %w", domain.ErrNotEnoughItemsInReservation))
+	})
+	engine.GET("/unknown", func(c *gin.Context) {
+		_ = c.Error(errors.New("connection refused"))
+	})
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/conflict", nil))
+
+	var response ErrorResponseDTO
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "not_enough_items_in_reservation", response.Code)
+
+	// internal details must not reach the client
+	recorder = httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "connection refused")
+}
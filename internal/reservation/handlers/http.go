@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +13,12 @@ import (
 )
 
 var (
-	ErrInvalidJSON = errors.New("invalid json")
+	ErrInvalidJSON   = errors.New("invalid json")
+	ErrInvalidParams = errors.New("invalid request parameters")
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
 )
 
 type ReservationHandler struct {
@@ -26,19 +32,22 @@ func NewReservationHandler(service ports.ReservationService, validate *validator
 
 // Reserve of ReservationHandler
 // @Tags reservation
-// @Description Creates a reservation for given items if storehouse have required amount
+// @Description Creates a reservation for given items if storehouse have required amount.
+// @Description A repeated request with the same Idempotency-Key returns the original response; the key reused with another payload is rejected.
+// @Description If explain is set, the response lists candidate storehouses of each entry ranked by distance and cost with the reason each one was picked or rejected
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "key to retry the request safely"
 // @Param input body domain.ReserveRequest true "destination location and items to reserve"
 // @Success 200 {object} ports.ReservationResponseDTO
-// @Failure 400 {object} string
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
 // @Router /reserve [post]
 func (handler *ReservationHandler) Reserve(c *gin.Context) {
 	var dto domain.ReserveRequest
 
 	err := c.ShouldBindJSON(&dto)
 	if err != nil {
-		_ = c.Error(ErrInvalidJSON)
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidJSON, err))
 		return
 	}
 
@@ -48,7 +57,7 @@ func (handler *ReservationHandler) Reserve(c *gin.Context) {
 		return
 	}
 
-	reservationResponse, err := handler.service.Reserve(dto)
+	reservationResponse, err := handler.service.Reserve(dto, c.GetHeader(idempotencyKeyHeader))
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -57,21 +66,92 @@ func (handler *ReservationHandler) Reserve(c *gin.Context) {
 	c.JSON(http.StatusOK, reservationResponse)
 }
 
+// Quote of ReservationHandler
+// @Tags reservation
+// @Description Returns the allocation and the cost the reservation would get with the current stock, split into shipments by storehouses.
+// @Description Nothing is reserved, so the real reservation may differ if the stock changes. Explain mode is the same as for reserve
+// @Accept json
+// @Produce json
+// @Param input body domain.ReserveRequest true "destination location and items to reserve"
+// @Success 200 {object} ports.QuoteResponseDTO
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
+// @Router /reserve/quote [post]
+func (handler *ReservationHandler) Quote(c *gin.Context) {
+	var dto domain.ReserveRequest
+
+	err := c.ShouldBindJSON(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidJSON, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	quote, err := handler.service.Quote(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
 // Release of ReservationHandler
 // @Tags reservation
-// @Description Releases items for given reservation. If there is no items left, deleted the reservation
+// @Description Releases items for given reservation. If there is no items left, deleted the reservation.
+// @Description Each item to release may contain: only storehouse ID to release everything from the storehouse,
+// @Description only item ID to release units from the most expensive storehouses, or both. Zero count releases all matching units.
+// @Description If reoptimize is set, the rest of the reservation is moved to other storehouses when it lowers the total cost.
+// @Description A repeated request with the same Idempotency-Key returns the original response; the key reused with another payload is rejected
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "key to retry the request safely"
 // @Param input body ports.ReleaseRequestDTO true "reservation ID and items to release"
 // @Success 200 {object} ports.ReservationResponseDTO
-// @Failure 400 {object} string
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
 // @Router /release [post]
 func (handler *ReservationHandler) Release(c *gin.Context) {
 	var dto ports.ReleaseRequestDTO
 
 	err := c.ShouldBindJSON(&dto)
 	if err != nil {
-		_ = c.Error(ErrInvalidJSON)
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidJSON, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	reservationResponse, err := handler.service.Release(dto, c.GetHeader(idempotencyKeyHeader))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservationResponse)
+}
+
+// GetReservation of ReservationHandler
+// @Tags reservation
+// @Description Returns the reservation with its current total cost
+// @Produce json
+// @Param id path string true "reservation ID"
+// @Success 200 {object} ports.ReservationResponseDTO
+// @Failure 400,404 {object} ErrorResponseDTO
+// @Router /reservations/{id} [get]
+func (handler *ReservationHandler) GetReservation(c *gin.Context) {
+	var dto ports.ReservationIDRequestDTO
+
+	err := c.ShouldBindUri(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
 		return
 	}
 
@@ -81,7 +161,7 @@ func (handler *ReservationHandler) Release(c *gin.Context) {
 		return
 	}
 
-	reservationResponse, err := handler.service.Release(dto.ReservationID, dto.ItemsToRelease)
+	reservationResponse, err := handler.service.GetReservation(dto.ReservationID)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -90,21 +170,171 @@ func (handler *ReservationHandler) Release(c *gin.Context) {
 	c.JSON(http.StatusOK, reservationResponse)
 }
 
+// ListReservations of ReservationHandler
+// @Tags reservation
+// @Description Returns reservations ordered by creation time. Reservations are filtered by an entry with the storehouse and item,
+// @Description by the destination bounding box and by the creation time range. To get the next page, pass nextCursor as cursor
+// @Produce json
+// @Param storehouse-id query string false "storehouse ID"
+// @Param item-id query string false "item ID"
+// @Param min-latitude query number false "minimal destination latitude"
+// @Param max-latitude query number false "maximal destination latitude"
+// @Param min-longitude query number false "minimal destination longitude"
+// @Param max-longitude query number false "maximal destination longitude"
+// @Param created-from query string false "inclusive start of the creation time range, RFC 3339"
+// @Param created-to query string false "exclusive end of the creation time range, RFC 3339"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Success 200 {object} ports.ListReservationsResponseDTO
+// @Failure 400,422 {object} ErrorResponseDTO
+// @Router /reservations [get]
+func (handler *ReservationHandler) ListReservations(c *gin.Context) {
+	var dto ports.ListReservationsRequestDTO
+
+	err := c.ShouldBindQuery(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := handler.service.ListReservations(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Confirm of ReservationHandler
+// @Tags reservation
+// @Description Confirms a held reservation, so it becomes a committed order and doesn't expire anymore
+// @Produce json
+// @Param id path string true "reservation ID"
+// @Success 200 {object} domain.Reservation
+// @Failure 400,404,409 {object} ErrorResponseDTO
+// @Router /reservations/{id}/confirm [post]
+func (handler *ReservationHandler) Confirm(c *gin.Context) {
+	handler.changeStatus(c, domain.Confirmed)
+}
+
+// Pick of ReservationHandler
+// @Tags reservation
+// @Description Marks a confirmed reservation as picked in storehouses
+// @Produce json
+// @Param id path string true "reservation ID"
+// @Success 200 {object} domain.Reservation
+// @Failure 400,404,409 {object} ErrorResponseDTO
+// @Router /reservations/{id}/pick [post]
+func (handler *ReservationHandler) Pick(c *gin.Context) {
+	handler.changeStatus(c, domain.Picked)
+}
+
+// Ship of ReservationHandler
+// @Tags reservation
+// @Description Marks a picked reservation as shipped. Its items are consumed and never return to storehouses
+// @Produce json
+// @Param id path string true "reservation ID"
+// @Success 200 {object} domain.Reservation
+// @Failure 400,404,409 {object} ErrorResponseDTO
+// @Router /reservations/{id}/ship [post]
+func (handler *ReservationHandler) Ship(c *gin.Context) {
+	handler.changeStatus(c, domain.Shipped)
+}
+
+// Cancel of ReservationHandler
+// @Tags reservation
+// @Description Cancels a reservation which is not shipped yet and returns its items to storehouses
+// @Produce json
+// @Param id path string true "reservation ID"
+// @Success 200 {object} domain.Reservation
+// @Failure 400,404,409 {object} ErrorResponseDTO
+// @Router /reservations/{id}/cancel [post]
+func (handler *ReservationHandler) Cancel(c *gin.Context) {
+	handler.changeStatus(c, domain.Cancelled)
+}
+
+func (handler *ReservationHandler) changeStatus(c *gin.Context, status domain.ReservationStatus) {
+	var dto ports.ReservationIDRequestDTO
+
+	err := c.ShouldBindUri(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	reservation, err := handler.service.ChangeStatus(dto.ReservationID, status)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
 // GetUnreserved of ReservationHandler
 // @Tags reservation
-// @Description Returns all unreserved items for given storehouse
+// @Description Returns items of given storehouse which have available units with on-hand, reserved and available counts.
+// @Description Items may be filtered by repeated item-id parameter. Items are ordered by ID, to get the next page, pass nextCursor as cursor
 // @Accept json
 // @Produce json
 // @Param storehouse-id query string true "storehouse ID"
+// @Param item-id query []string false "item IDs to return" collectionFormat(multi)
+// @Param cursor query string false "nextCursor of the previous page"
+// @Param limit query int false "page size, 20 by default, 100 at most"
 // @Success 200 {object} ports.GetUnreservedResponseDTO
-// @Failure 400 {object} string
+// @Failure 400,404,422 {object} ErrorResponseDTO
 // @Router /get-unreserved-items [get]
 func (handler *ReservationHandler) GetUnreserved(c *gin.Context) {
 	var dto ports.GetUnreservedRequestDTO
 
 	err := c.ShouldBindQuery(&dto)
 	if err != nil {
-		_ = c.Error(ErrInvalidJSON)
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := handler.service.GetUnreserved(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetAvailability of ReservationHandler
+// @Tags reservation
+// @Description Returns the total number of available units of each item over active storehouses with a breakdown by storehouse
+// @Produce json
+// @Param item-id query []string true "item IDs" collectionFormat(multi)
+// @Success 200 {object} ports.AvailabilityResponseDTO
+// @Failure 400,404,422 {object} ErrorResponseDTO
+// @Router /availability [get]
+func (handler *ReservationHandler) GetAvailability(c *gin.Context) {
+	var dto ports.AvailabilityRequestDTO
+
+	err := c.ShouldBindQuery(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
 		return
 	}
 
@@ -114,11 +344,11 @@ func (handler *ReservationHandler) GetUnreserved(c *gin.Context) {
 		return
 	}
 
-	unreservedItems, err := handler.service.GetUnreserved(dto.StorehouseID)
+	availability, err := handler.service.GetAvailability(dto)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, ports.GetUnreservedResponseDTO{StorehouseID: dto.StorehouseID, Items: unreservedItems})
+	c.JSON(http.StatusOK, availability)
 }
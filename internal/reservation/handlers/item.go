@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+type ItemHandler struct {
+	service  ports.ItemService
+	validate *validator.Validate
+}
+
+func NewItemHandler(service ports.ItemService, validate *validator.Validate) *ItemHandler {
+	return &ItemHandler{service: service, validate: validate}
+}
+
+// Create of ItemHandler
+// @Tags item
+// @Description Adds the item to the catalog. All dimensions and the weight must be positive
+// @Accept json
+// @Produce json
+// @Param input body domain.Item true "item"
+// @Success 200 {object} domain.Item
+// @Failure 400,409,422 {object} ErrorResponseDTO
+// @Router /items [post]
+func (handler *ItemHandler) Create(c *gin.Context) {
+	var dto domain.Item
+
+	err := c.ShouldBindJSON(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidJSON, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := handler.service.Create(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// Search of ItemHandler
+// @Tags item
+// @Description Returns items which name contains the query ordered by ID.
+// @Description Pages are returned by cursor: nextCursor of the response is passed to get the next page
+// @Produce json
+// @Param query query string false "part of the item name, case-insensitive"
+// @Param cursor query string false "cursor of the page"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Success 200 {object} ports.SearchItemsResponseDTO
+// @Failure 400,422 {object} ErrorResponseDTO
+// @Router /items [get]
+func (handler *ItemHandler) Search(c *gin.Context) {
+	var dto ports.SearchItemsRequestDTO
+
+	err := c.ShouldBindQuery(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := handler.service.Search(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Get of ItemHandler
+// @Tags item
+// @Description Returns the item
+// @Produce json
+// @Param id path string true "item ID"
+// @Success 200 {object} domain.Item
+// @Failure 400,404 {object} ErrorResponseDTO
+// @Router /items/{id} [get]
+func (handler *ItemHandler) Get(c *gin.Context) {
+	var dto ports.ItemIDRequestDTO
+
+	err := c.ShouldBindUri(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := handler.service.Get(dto.ItemID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// Update of ItemHandler
+// @Tags item
+// @Description Replaces name, size and weight of the item. Costs of reservations are calculated with the new values
+// @Accept json
+// @Produce json
+// @Param id path string true "item ID"
+// @Param input body ports.UpdateItemRequestDTO true "name, size and weight"
+// @Success 200 {object} domain.Item
+// @Failure 400,404,422 {object} ErrorResponseDTO
+// @Router /items/{id} [put]
+func (handler *ItemHandler) Update(c *gin.Context) {
+	var dto ports.UpdateItemRequestDTO
+
+	err := bindURIAndJSON(c, &dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := handler.service.Update(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+type StockHandler struct {
+	service  ports.StockService
+	validate *validator.Validate
+}
+
+func NewStockHandler(service ports.StockService, validate *validator.Validate) *StockHandler {
+	return &StockHandler{service: service, validate: validate}
+}
+
+// Receive of StockHandler
+// @Tags stock
+// @Description Records items brought to the storehouse. Reasons: supplier-delivery, customer-return
+// @Accept json
+// @Produce json
+// @Param input body ports.StockMovementRequestDTO true "storehouse, item, positive quantity, reason and actor"
+// @Success 200 {object} domain.StockMovement
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
+// @Router /stock/receipts [post]
+func (handler *StockHandler) Receive(c *gin.Context) {
+	handler.recordMovement(c, domain.Receipt)
+}
+
+// WriteOff of StockHandler
+// @Tags stock
+// @Description Records items removed from the storehouse. Reasons: damaged, lost, expired.
+// @Description Reserved items can't be written off
+// @Accept json
+// @Produce json
+// @Param input body ports.StockMovementRequestDTO true "storehouse, item, positive quantity, reason and actor"
+// @Success 200 {object} domain.StockMovement
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
+// @Router /stock/write-offs [post]
+func (handler *StockHandler) WriteOff(c *gin.Context) {
+	handler.recordMovement(c, domain.WriteOff)
+}
+
+// Adjust of StockHandler
+// @Tags stock
+// @Description Corrects the count of the item in the storehouse, a negative quantity decreases it.
+// @Description Reasons: inventory-count, correction
+// @Accept json
+// @Produce json
+// @Param input body ports.StockMovementRequestDTO true "storehouse, item, non-zero quantity, reason and actor"
+// @Success 200 {object} domain.StockMovement
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
+// @Router /stock/adjustments [post]
+func (handler *StockHandler) Adjust(c *gin.Context) {
+	handler.recordMovement(c, domain.Adjustment)
+}
+
+func (handler *StockHandler) recordMovement(c *gin.Context, movementType domain.MovementType) {
+	var dto ports.StockMovementRequestDTO
+
+	err := c.ShouldBindJSON(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidJSON, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	movement, err := handler.service.RecordMovement(movementType, dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, movement)
+}
+
+// GetStock of StockHandler
+// @Tags stock
+// @Description Returns on-hand, reserved and available units of every item kept in the storehouse.
+// @Description Reserved units stay on hand until shipped, available = on-hand - reserved
+// @Produce json
+// @Param id path string true "storehouse ID"
+// @Success 200 {object} ports.GetStockResponseDTO
+// @Failure 400,404 {object} ErrorResponseDTO
+// @Router /storehouses/{id}/stock [get]
+func (handler *StockHandler) GetStock(c *gin.Context) {
+	var dto ports.StorehouseIDRequestDTO
+
+	err := c.ShouldBindUri(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := handler.service.GetStock(dto.StorehouseID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetStockAt of StockHandler
+// @Tags stock
+// @Description Rebuilds available and reserved stock of the storehouse at the moment from the stock ledger
+// @Produce json
+// @Param id path string true "storehouse ID"
+// @Param item-id query string false "item ID, all items by default"
+// @Param at query string false "moment in RFC3339, now by default"
+// @Success 200 {object} ports.StockAtResponseDTO
+// @Failure 400,404 {object} ErrorResponseDTO
+// @Router /storehouses/{id}/stock-history [get]
+func (handler *StockHandler) GetStockAt(c *gin.Context) {
+	var dto ports.StockAtRequestDTO
+
+	err := c.ShouldBindUri(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = c.ShouldBindQuery(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := handler.service.GetStockAt(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+type StorehouseHandler struct {
+	service  ports.StorehouseService
+	validate *validator.Validate
+}
+
+func NewStorehouseHandler(service ports.StorehouseService, validate *validator.Validate) *StorehouseHandler {
+	return &StorehouseHandler{service: service, validate: validate}
+}
+
+// Create of StorehouseHandler
+// @Tags storehouse
+// @Description Creates an empty storehouse. ID and name must be unique
+// @Accept json
+// @Produce json
+// @Param input body ports.CreateStorehouseRequestDTO true "storehouse ID, name, location, schedule and vehicle profile"
+// @Success 200 {object} domain.StoreHouse
+// @Failure 400,409,422 {object} ErrorResponseDTO
+// @Router /storehouses [post]
+func (handler *StorehouseHandler) Create(c *gin.Context) {
+	var dto ports.CreateStorehouseRequestDTO
+
+	err := c.ShouldBindJSON(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidJSON, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	storehouse, err := handler.service.Create(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, storehouse)
+}
+
+// GetAll of StorehouseHandler
+// @Tags storehouse
+// @Description Returns all storehouses including deactivated ones ordered by ID
+// @Produce json
+// @Success 200 {array} domain.StoreHouse
+// @Router /storehouses [get]
+func (handler *StorehouseHandler) GetAll(c *gin.Context) {
+	storehouses, err := handler.service.GetAll()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, storehouses)
+}
+
+// Get of StorehouseHandler
+// @Tags storehouse
+// @Description Returns the storehouse
+// @Produce json
+// @Param id path string true "storehouse ID"
+// @Success 200 {object} domain.StoreHouse
+// @Failure 400,404 {object} ErrorResponseDTO
+// @Router /storehouses/{id} [get]
+func (handler *StorehouseHandler) Get(c *gin.Context) {
+	var dto ports.StorehouseIDRequestDTO
+
+	err := c.ShouldBindUri(&dto)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidParams, err))
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	storehouse, err := handler.service.Get(dto.StorehouseID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, storehouse)
+}
+
+// Update of StorehouseHandler
+// @Tags storehouse
+// @Description Renames the storehouse and replaces its schedule and vehicle profile
+// @Accept json
+// @Produce json
+// @Param id path string true "storehouse ID"
+// @Param input body ports.UpdateStorehouseRequestDTO true "new name, schedule and vehicle profile"
+// @Success 200 {object} domain.StoreHouse
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
+// @Router /storehouses/{id} [put]
+func (handler *StorehouseHandler) Update(c *gin.Context) {
+	var dto ports.UpdateStorehouseRequestDTO
+
+	err := bindURIAndJSON(c, &dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	storehouse, err := handler.service.Update(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, storehouse)
+}
+
+// Relocate of StorehouseHandler
+// @Tags storehouse
+// @Description Changes the location of the storehouse. Costs of reservations are calculated with the new location
+// @Accept json
+// @Produce json
+// @Param id path string true "storehouse ID"
+// @Param input body ports.RelocateStorehouseRequestDTO true "new location"
+// @Success 200 {object} domain.StoreHouse
+// @Failure 400,404,422 {object} ErrorResponseDTO
+// @Router /storehouses/{id}/relocate [post]
+func (handler *StorehouseHandler) Relocate(c *gin.Context) {
+	var dto ports.RelocateStorehouseRequestDTO
+
+	err := bindURIAndJSON(c, &dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	storehouse, err := handler.service.Relocate(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, storehouse)
+}
+
+// SetStatus of StorehouseHandler
+// @Tags storehouse
+// @Description Opens, closes or puts the storehouse into maintenance. Its stock and open reservations are kept,
+// @Description but new reservations take items only from open storehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "storehouse ID"
+// @Param input body ports.StorehouseStatusRequestDTO true "new status"
+// @Success 200 {object} domain.StoreHouse
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
+// @Router /storehouses/{id}/status [post]
+func (handler *StorehouseHandler) SetStatus(c *gin.Context) {
+	var dto ports.StorehouseStatusRequestDTO
+
+	err := bindURIAndJSON(c, &dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	storehouse, err := handler.service.SetStatus(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, storehouse)
+}
+
+// Deactivate of StorehouseHandler
+// @Tags storehouse
+// @Description Deactivates the storehouse, so new reservations don't take items from it.
+// @Description If open reservations take items from the storehouse, a transfer plan is required:
+// @Description stock left in the storehouse is moved to the target storehouse and the reservations take items from the target instead
+// @Accept json
+// @Produce json
+// @Param id path string true "storehouse ID"
+// @Param input body ports.DeactivateStorehouseRequestDTO false "transfer plan"
+// @Success 200 {object} ports.DeactivationResponseDTO
+// @Failure 400,404,409,422 {object} ErrorResponseDTO
+// @Router /storehouses/{id}/deactivate [post]
+func (handler *StorehouseHandler) Deactivate(c *gin.Context) {
+	var dto ports.DeactivateStorehouseRequestDTO
+
+	err := bindURIAndJSON(c, &dto)
+	// the body may be omitted if the storehouse has no open reservations
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = c.Error(err)
+		return
+	}
+
+	err = handler.validate.Struct(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response, err := handler.service.Deactivate(dto)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func bindURIAndJSON(c *gin.Context, dto any) error {
+	err := c.ShouldBindUri(dto)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidParams, err)
+	}
+
+	err = c.ShouldBindJSON(dto)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidJSON, err)
+	}
+
+	return nil
+}
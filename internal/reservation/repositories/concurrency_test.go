@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/services"
+)
+
+const (
+	testWorkers    = 8
+	testIterations = 30
+)
+
+// TestPostgres_ConcurrentReserveAndRelease is the services test of the same name run against the real locks
+func TestPostgres_ConcurrentReserveAndRelease(t *testing.T) {
+	db := newTestDB(t)
+	fillTestNetwork(t, db)
+
+	strategies := map[domain.AllocationStrategyName]ports.AllocationStrategy{
+		domain.NearestFirst:      domain.NearestFirstStrategy{},
+		domain.FewestStorehouses: domain.FewestStorehousesStrategy{},
+		domain.CostOptimal:       domain.CostOptimalStrategy{},
+	}
+	strategyNames := []domain.AllocationStrategyName{domain.NearestFirst, domain.CostOptimal, domain.FewestStorehouses}
+
+	service := services.New(NewPostgresStorehouse(db), NewPostgresItem(db), NewPostgresReservation(db),
+		NewPostgresIdempotency(db), NewPostgresStockMovement(db), NewPostgresTransactionManager(db),
+		domain.DefaultCostModel(), strategies)
+	storehouseService := services.NewStorehouseService(NewPostgresStorehouse(db), NewPostgresReservation(db),
+		NewPostgresStockMovement(db), NewPostgresTransactionManager(db))
+
+	var mu sync.Mutex
+	reservationIDs := make([]string, 0)
+
+	pickReservation := func(random *rand.Rand) (string, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(reservationIDs) == 0 {
+			return "", false
+		}
+
+		return reservationIDs[random.Intn(len(reservationIDs))], true
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < testWorkers; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(seed))
+
+			for i := 0; i < testIterations; i++ {
+				switch random.Intn(3) {
+				case 0:
+					response, err := service.Reserve(domain.ReserveRequest{
+						DestinationLocation: domain.Location{Latitude: 50, Longitude: 50},
+						ItemsToReserve: []domain.ReserveEntry{
+							{ItemID: domain.ItemID([]string{"1", "2"}[random.Intn(2)]), Count: random.Intn(5) + 1},
+						},
+						Strategy: strategyNames[random.Intn(len(strategyNames))],
+					}, "")
+					if err == nil {
+						mu.Lock()
+						reservationIDs = append(reservationIDs, response.Reservation.ID)
+						mu.Unlock()
+					}
+				case 1:
+					id, ok := pickReservation(random)
+					if !ok {
+						continue
+					}
+
+					// the same reservation is released by two goroutines at once
+					var releaseWG sync.WaitGroup
+					for j := 0; j < 2; j++ {
+						releaseWG.Add(1)
+						go func() {
+							defer releaseWG.Done()
+							_, _ = service.Release(ports.ReleaseRequestDTO{ReservationID: id}, "")
+						}()
+					}
+					releaseWG.Wait()
+				case 2:
+					id, ok := pickReservation(random)
+					if !ok {
+						continue
+					}
+
+					_, _ = service.Release(ports.ReleaseRequestDTO{
+						ReservationID:  id,
+						ItemsToRelease: []domain.ReleaseEntry{{ItemID: "1", Count: 1}},
+						Reoptimize:     true,
+					}, "")
+				}
+			}
+		}(int64(worker))
+	}
+
+	// the storehouse is deactivated while reservations are made, so none of them must be left in it
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		_, err := storehouseService.Deactivate(ports.DeactivateStorehouseRequestDTO{
+			StorehouseID: "c",
+			TransferPlan: &domain.TransferPlan{TargetStorehouseID: "b"},
+		})
+		assert.NoError(t, err)
+	}()
+
+	wg.Wait()
+
+	// nothing is shipped, so every unit stays on hand and reserved units match open reservations
+	rows, err := db.Query(
+		`SELECT si.storehouse_id, si.item_id, si.on_hand, si.reserved, si.available,
+		COALESCE(SUM(ri.items_count) FILTER (WHERE r.id IS NOT NULL), 0)
+		FROM storehouses_items AS si
+		LEFT JOIN reservation_items AS ri ON ri.storehouse_id = si.storehouse_id AND ri.item_id = si.item_id
+		LEFT JOIN reservations AS r ON r.id = ri.reservation_id AND r.status IN ('held', 'confirmed', 'picked')
+		GROUP BY si.storehouse_id, si.item_id, si.on_hand, si.reserved, si.available`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer rows.Close()
+
+	onHand := make(map[domain.ItemID]int)
+	for rows.Next() {
+		var storehouseID domain.StoreHouseID
+		var itemID domain.ItemID
+		var itemOnHand, reserved, available, inReservations int
+		if !assert.NoError(t, rows.Scan(&storehouseID, &itemID, &itemOnHand, &reserved, &available, &inReservations)) {
+			t.FailNow()
+		}
+
+		assert.GreaterOrEqual(t, available, 0, "storehouse: %s, item: %s", storehouseID, itemID)
+		assert.Equal(t, inReservations, reserved, "storehouse: %s, item: %s", storehouseID, itemID)
+		if storehouseID == "c" {
+			assert.Zero(t, itemOnHand, "item: %s", itemID)
+		}
+
+		onHand[itemID] += itemOnHand
+	}
+	assert.NoError(t, rows.Err())
+
+	assert.Equal(t, map[domain.ItemID]int{"1": 3 * testInitialCount, "2": 3 * testInitialCount}, onHand)
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+)
+
+var ErrUnsupportedMatrixFormat = errors.New("unsupported distance matrix format")
+
+var csvMatrixHeader = []string{"storehouse_id", "region", "distance_km"}
+
+type matrixRouteJSON struct {
+	StorehouseID domain.StoreHouseID `json:"storehouseID"`
+	Region       string              `json:"region"`
+	DistanceKm   float64             `json:"distanceKm"`
+}
+
+// LoadDistanceMatrix reads precomputed routes from storehouses to regions. The format is chosen by the extension:
+// .csv with the header storehouse_id,region,distance_km or .json with an array of objects
+// with storehouseID, region and distanceKm
+func LoadDistanceMatrix(path string) ([]domain.MatrixRoute, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening distance matrix file: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	switch filepath.Ext(path) {
+	case ".csv":
+		return readCSVMatrix(file)
+	case ".json":
+		return readJSONMatrix(file)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMatrixFormat, path)
+	}
+}
+
+func readCSVMatrix(reader io.Reader) ([]domain.MatrixRoute, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = len(csvMatrixHeader)
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading distance matrix header: %w", err)
+	}
+
+	for i, column := range csvMatrixHeader {
+		if header[i] != column {
+			return nil, fmt.Errorf("%w: expected header: %v, got: %v", domain.ErrInvalidDistanceMatrix, csvMatrixHeader, header)
+		}
+	}
+
+	routes := make([]domain.MatrixRoute, 0)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading distance matrix row: %w", err)
+		}
+
+		distance, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: parsing distance: %s", domain.ErrInvalidDistanceMatrix, err)
+		}
+
+		routes = append(routes, domain.MatrixRoute{
+			StorehouseID: domain.StoreHouseID(record[0]),
+			Region:       record[1],
+			DistanceKm:   distance,
+		})
+	}
+
+	return routes, nil
+}
+
+func readJSONMatrix(reader io.Reader) ([]domain.MatrixRoute, error) {
+	var rows []matrixRouteJSON
+	err := json.NewDecoder(reader).Decode(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding json: %s", domain.ErrInvalidDistanceMatrix, err)
+	}
+
+	routes := make([]domain.MatrixRoute, 0, len(rows))
+	for _, row := range rows {
+		routes = append(routes, domain.MatrixRoute{
+			StorehouseID: row.StorehouseID,
+			Region:       row.Region,
+			DistanceKm:   row.DistanceKm,
+		})
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+)
+
+func TestLoadDistanceMatrix(t *testing.T) {
+	expected := []domain.MatrixRoute{
+		{StorehouseID: "a", Region: "south", DistanceKm: 512.5},
+		{StorehouseID: "b", Region: "north", DistanceKm: 40},
+	}
+
+	tests := []struct {
+		name          string
+		file          string
+		content       string
+		expected      []domain.MatrixRoute
+		expectedError error
+	}{
+		{
+			name:     "csv",
+			file:     "matrix.csv",
+			content:  "storehouse_id,region,distance_km\na,south,512.5\nb,north,40\n",
+			expected: expected,
+		},
+		{
+			name: "json",
+			file: "matrix.json",
+			content: `[{"storehouseID": "a", "region": "south", "distanceKm": 512.5},
+				{"storehouseID": "b", "region": "north", "distanceKm": 40}]`,
+			expected: expected,
+		},
+		{
+			name:     "csv without routes",
+			file:     "matrix.csv",
+			content:  "storehouse_id,region,distance_km\n",
+			expected: []domain.MatrixRoute{},
+		},
+		{
+			name:          "csv with wrong header",
+			file:          "matrix.csv",
+			content:       "storehouse,region,distance_km\na,south,1\n",
+			expectedError: domain.ErrInvalidDistanceMatrix,
+		},
+		{
+			name:          "csv with wrong distance",
+			file:          "matrix.csv",
+			content:       "storehouse_id,region,distance_km\na,south,far\n",
+			expectedError: domain.ErrInvalidDistanceMatrix,
+		},
+		{
+			name:          "malformed json",
+			file:          "matrix.json",
+			content:       `[{"storehouseID": "a"`,
+			expectedError: domain.ErrInvalidDistanceMatrix,
+		},
+		{
+			name:          "unsupported format",
+			file:          "matrix.xml",
+			content:       "<routes/>",
+			expectedError: ErrUnsupportedMatrixFormat,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if !assert.NoError(t, os.WriteFile(path, []byte(test.content), 0o600)) {
+				t.FailNow()
+			}
+
+			routes, err := LoadDistanceMatrix(path)
+			if test.expectedError != nil {
+				assert.ErrorIs(t, err, test.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, routes)
+		})
+	}
+
+	_, err := LoadDistanceMatrix(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+type PostgresIdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotency(db *sql.DB) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{db: db}
+}
+
+// Claim relies on the unique key: inserting a key claimed by an unfinished transaction waits for its end
+func (repo PostgresIdempotencyRepository) Claim(ctx context.Context, key string, fingerprint string) (ports.IdempotencyRecord, bool, error) {
+	db := getExecutor(ctx, repo.db)
+
+	result, err := db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (key, fingerprint) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`, key, fingerprint)
+	if err != nil {
+		return ports.IdempotencyRecord{}, false, fmt.Errorf("inserting into idempotency_keys table: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return ports.IdempotencyRecord{}, false, fmt.Errorf("getting affected rows: %w", err)
+	}
+
+	if affected == 1 {
+		return ports.IdempotencyRecord{Key: key, Fingerprint: fingerprint}, true, nil
+	}
+
+	record := ports.IdempotencyRecord{Key: key}
+
+	var response []byte
+	err = db.QueryRowContext(ctx,
+		`SELECT fingerprint, response FROM idempotency_keys WHERE key = $1`, key,
+	).Scan(&record.Fingerprint, &response)
+	if err != nil {
+		return ports.IdempotencyRecord{}, false, fmt.Errorf("looking up in idempotency_keys table: %w", err)
+	}
+
+	err = json.Unmarshal(response, &record.Response)
+	if err != nil {
+		return ports.IdempotencyRecord{}, false, fmt.Errorf("decoding stored response: %w", err)
+	}
+
+	return record, false, nil
+}
+
+func (repo PostgresIdempotencyRepository) SaveResponse(ctx context.Context, key string, response ports.ReservationResponseDTO) error {
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("encoding response: %w", err)
+	}
+
+	_, err = getExecutor(ctx, repo.db).ExecContext(ctx,
+		`UPDATE idempotency_keys SET response = $2 WHERE key = $1`, key, encoded)
+	if err != nil {
+		return fmt.Errorf("updating idempotency_keys table: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCreatedBefore deletes the oldest keys first, keys claimed by unfinished transactions are not visible to it
+func (repo PostgresIdempotencyRepository) DeleteCreatedBefore(ctx context.Context, before time.Time, limit int) (int, error) {
+	result, err := getExecutor(ctx, repo.db).ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE key IN (
+			SELECT key FROM idempotency_keys WHERE created_at < $1 ORDER BY created_at LIMIT $2)`,
+		before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("deleting from idempotency_keys table: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting affected rows: %w", err)
+	}
+
+	return int(affected), nil
+}
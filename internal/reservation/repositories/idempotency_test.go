@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresIdempotency_DeleteCreatedBefore(t *testing.T) {
+	db := newTestDB(t)
+
+	repo := NewPostgresIdempotency(db)
+	ctx := context.Background()
+
+	for _, key := range []string{"old-1", "old-2", "new"} {
+		_, claimed, err := repo.Claim(ctx, key, "fingerprint")
+		if !assert.NoError(t, err) || !assert.True(t, claimed) {
+			t.FailNow()
+		}
+	}
+
+	_, err := db.Exec(`UPDATE idempotency_keys SET created_at = now() - interval '2 days' WHERE key LIKE 'old-%'`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	before := time.Now().Add(-24 * time.Hour)
+
+	deleted, err := repo.DeleteCreatedBefore(ctx, before, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	deleted, err = repo.DeleteCreatedBefore(ctx, before, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	var keys []string
+	rows, err := db.Query(`SELECT key FROM idempotency_keys`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		assert.NoError(t, rows.Scan(&key))
+		keys = append(keys, key)
+	}
+	assert.NoError(t, rows.Err())
+
+	assert.Equal(t, []string{"new"}, keys)
+}
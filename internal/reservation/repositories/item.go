@@ -3,9 +3,14 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
 
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
 )
 
 type PostgresItemRepository struct {
@@ -17,29 +22,123 @@ func NewPostgresItem(db *sql.DB) *PostgresItemRepository {
 }
 
 func (repo PostgresItemRepository) GetAllAsMap(ctx context.Context) (map[domain.ItemID]domain.Item, error) {
-	rows, err := repo.db.QueryContext(ctx,
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
 		`SELECT id, name, length_meters, width_meters, height_meters, weight_kg FROM items`)
 	if err != nil {
 		return nil, fmt.Errorf("looking up in items table: %w", err)
 	}
+	defer rows.Close()
 
 	items := make(map[domain.ItemID]domain.Item)
 	for rows.Next() {
-		var item domain.Item
-		item.Size = &domain.Size{}
-
-		err = rows.Scan(&item.ID, &item.Name,
-			&item.Size.LengthMeters, &item.Size.WidthMeters, &item.Size.HeightMeters, &item.WeightKilograms)
+		item, err := scanItem(rows)
 		if err != nil {
-			return nil, fmt.Errorf("scanning row: %w", err)
+			return nil, err
 		}
 
 		items[item.ID] = item
 	}
 
-	if rows.Err() != nil {
+	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("after iterating over items rows: %w", err)
 	}
 
 	return items, nil
 }
+
+func (repo PostgresItemRepository) GetByID(ctx context.Context, id domain.ItemID) (domain.Item, error) {
+	row := getExecutor(ctx, repo.db).QueryRowContext(ctx,
+		`SELECT id, name, length_meters, width_meters, height_meters, weight_kg FROM items WHERE id = $1`, id)
+
+	item, err := scanItem(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Item{}, fmt.Errorf("%w: %s", domain.ErrUnknownItem, id)
+	}
+	if err != nil {
+		return domain.Item{}, err
+	}
+
+	return item, nil
+}
+
+func (repo PostgresItemRepository) Create(ctx context.Context, item domain.Item) error {
+	_, err := getExecutor(ctx, repo.db).ExecContext(ctx,
+		`INSERT INTO items (id, name, length_meters, width_meters, height_meters, weight_kg) VALUES ($1, $2, $3, $4, $5, $6)`,
+		item.ID, item.Name, item.Size.LengthMeters, item.Size.WidthMeters, item.Size.HeightMeters, item.WeightKilograms)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+		return fmt.Errorf("%w: %s", domain.ErrItemAlreadyExists, item.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("inserting into items table: %w", err)
+	}
+
+	return nil
+}
+
+func (repo PostgresItemRepository) Update(ctx context.Context, item domain.Item) error {
+	result, err := getExecutor(ctx, repo.db).ExecContext(ctx,
+		`UPDATE items SET name = $2, length_meters = $3, width_meters = $4, height_meters = $5, weight_kg = $6 WHERE id = $1`,
+		item.ID, item.Name, item.Size.LengthMeters, item.Size.WidthMeters, item.Size.HeightMeters, item.WeightKilograms)
+	if err != nil {
+		return fmt.Errorf("updating items table: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownItem, item.ID)
+	}
+
+	return nil
+}
+
+func (repo PostgresItemRepository) Search(ctx context.Context, filter ports.ItemFilter) ([]domain.Item, error) {
+	// the query is matched literally, so wildcards typed by the client are escaped
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.Query) + "%"
+
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
+		`SELECT id, name, length_meters, width_meters, height_meters, weight_kg FROM items
+		 WHERE name ILIKE $1 AND id > $2 ORDER BY id LIMIT $3`,
+		pattern, filter.AfterID, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("looking up in items table: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.Item, 0)
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over items rows: %w", err)
+	}
+
+	return items, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanItem(row rowScanner) (domain.Item, error) {
+	item := domain.Item{Size: &domain.Size{}}
+
+	err := row.Scan(&item.ID, &item.Name,
+		&item.Size.LengthMeters, &item.Size.WidthMeters, &item.Size.HeightMeters, &item.WeightKilograms)
+	if err != nil {
+		return domain.Item{}, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return item, nil
+}
@@ -5,8 +5,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
 )
 
 type PostgresReservationRepository struct {
@@ -20,22 +25,30 @@ func NewPostgresReservation(db *sql.DB) *PostgresReservationRepository {
 func (repo PostgresReservationRepository) GetByID(ctx context.Context, id string) (domain.Reservation, error) {
 	reservation := domain.Reservation{ID: id}
 
-	err := repo.db.QueryRowContext(ctx,
-		`SELECT destination_latitude, destination_longitude FROM reservations WHERE id = $1`, id,
-	).Scan(&reservation.DestinationLocation.Latitude, &reservation.DestinationLocation.Longitude)
+	db := getExecutor(ctx, repo.db)
+
+	err := db.QueryRowContext(ctx,
+		`SELECT destination_latitude, destination_longitude, status, strategy, created_at, expires_at, version
+		 FROM reservations WHERE id = $1`, id,
+	).Scan(&reservation.DestinationLocation.Latitude, &reservation.DestinationLocation.Longitude,
+		&reservation.Status, &reservation.Strategy, &reservation.CreatedAt, &reservation.ExpiresAt, &reservation.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Reservation{}, fmt.Errorf("%w: %s", ports.ErrReservationNotFound, id)
+	}
 	if err != nil {
 		return domain.Reservation{}, fmt.Errorf("looking up in reservation table: %w", err)
 	}
 
-	rows, err := repo.db.QueryContext(ctx,
-		`SELECT item_id, storehouse_id, items_count FROM reservation_items WHERE reservation_id = $1`, id)
+	rows, err := db.QueryContext(ctx,
+		`SELECT item_id, storehouse_id, items_count, manual FROM reservation_items WHERE reservation_id = $1`, id)
 	if err != nil {
 		return domain.Reservation{}, fmt.Errorf("looking up in reservation_items table: %w", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var entry domain.ReserveEntry
-		err = rows.Scan(&entry.ItemID, &entry.SourceStorehouseID, &entry.Count)
+		err = rows.Scan(&entry.ItemID, &entry.SourceStorehouseID, &entry.Count, &entry.Manual)
 		if err != nil {
 			return domain.Reservation{}, fmt.Errorf("scanning row: %w", err)
 		}
@@ -43,7 +56,7 @@ func (repo PostgresReservationRepository) GetByID(ctx context.Context, id string
 		reservation.Entries = append(reservation.Entries, entry)
 	}
 
-	if rows.Err() != nil {
+	if err = rows.Err(); err != nil {
 		return domain.Reservation{}, fmt.Errorf("after iterating over reservation_items rows: %w", err)
 	}
 
@@ -51,103 +64,287 @@ func (repo PostgresReservationRepository) GetByID(ctx context.Context, id string
 }
 
 func (repo PostgresReservationRepository) Save(ctx context.Context, reservation domain.Reservation) error {
-	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault, ReadOnly: false})
+	return inTransaction(ctx, repo.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO reservations (id, destination_latitude, destination_longitude, status, strategy, created_at, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			reservation.ID, reservation.DestinationLocation.Latitude, reservation.DestinationLocation.Longitude,
+			reservation.Status, reservation.Strategy, reservation.CreatedAt, reservation.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("inserting into reservations table: %w", err)
+		}
+
+		return insertReservationItems(ctx, tx, reservation)
+	})
+}
+
+func (repo PostgresReservationRepository) Update(ctx context.Context, reservation domain.Reservation) error {
+	return inTransaction(ctx, repo.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE reservations SET destination_latitude = $2, destination_longitude = $3, status = $4, expires_at = $5,
+			 version = version + 1
+			 WHERE id = $1 AND version = $6`,
+			reservation.ID, reservation.DestinationLocation.Latitude, reservation.DestinationLocation.Longitude,
+			reservation.Status, reservation.ExpiresAt, reservation.Version)
+		if err != nil {
+			return fmt.Errorf("updating reservations table: %w", err)
+		}
+
+		err = checkVersionedUpdate(result, reservation.ID)
+		if err != nil {
+			return err
+		}
+
+		// TODO: calculate changes instead of deleting-inserting all content
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM reservation_items WHERE reservation_id = $1`, reservation.ID)
+		if err != nil {
+			return fmt.Errorf("deleting associated reservation_items: %w", err)
+		}
+
+		return insertReservationItems(ctx, tx, reservation)
+	})
+}
+
+func (repo PostgresReservationRepository) Delete(ctx context.Context, reservation domain.Reservation) error {
+	return inTransaction(ctx, repo.db, func(tx *sql.Tx) error {
+		// increasing the version locks the row, so concurrent deletion or update waits and then fails
+		result, err := tx.ExecContext(ctx,
+			`UPDATE reservations SET version = version + 1 WHERE id = $1 AND version = $2`,
+			reservation.ID, reservation.Version)
+		if err != nil {
+			return fmt.Errorf("locking reservation: %w", err)
+		}
+
+		err = checkVersionedUpdate(result, reservation.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM reservation_items WHERE reservation_id = $1`, reservation.ID)
+		if err != nil {
+			return fmt.Errorf("deleting associated reservation_items: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM reservations WHERE id = $1`, reservation.ID)
+		if err != nil {
+			return fmt.Errorf("deleting reservation: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo PostgresReservationRepository) GetOpenByStorehouse(ctx context.Context, storehouseID domain.StoreHouseID) ([]domain.Reservation, error) {
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
+		`SELECT r.id FROM reservations r
+		 WHERE r.status = ANY($1)
+		   AND EXISTS (SELECT 1 FROM reservation_items ri WHERE ri.reservation_id = r.id AND ri.storehouse_id = $2)
+		 ORDER BY r.created_at, r.id`,
+		pq.Array([]string{string(domain.Held), string(domain.Confirmed), string(domain.Picked)}), storehouseID)
 	if err != nil {
-		return fmt.Errorf("starting transaction: %w", err)
+		return nil, fmt.Errorf("looking up in reservations table: %w", err)
 	}
+	defer rows.Close()
 
-	defer func() {
-		// TODO
-		_ = tx.Rollback()
-	}()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over reservations rows: %w", err)
+	}
+
+	reservations := make([]domain.Reservation, 0, len(ids))
+	for _, id := range ids {
+		reservation, err := repo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, nil
+}
+
+func (repo PostgresReservationRepository) List(ctx context.Context, filter ports.ReservationFilter) ([]domain.Reservation, error) {
+	db := getExecutor(ctx, repo.db)
+
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !filter.StorehouseID.IsEmpty() || filter.ItemID != "" {
+		entryConditions := []string{`ri.reservation_id = r.id`}
+		if !filter.StorehouseID.IsEmpty() {
+			entryConditions = append(entryConditions, `ri.storehouse_id = `+arg(filter.StorehouseID))
+		}
+		if filter.ItemID != "" {
+			entryConditions = append(entryConditions, `ri.item_id = `+arg(filter.ItemID))
+		}
+
+		conditions = append(conditions,
+			`EXISTS (SELECT 1 FROM reservation_items ri WHERE `+strings.Join(entryConditions, ` AND `)+`)`)
+	}
+	if filter.MinLatitude != nil {
+		conditions = append(conditions, `r.destination_latitude >= `+arg(*filter.MinLatitude))
+	}
+	if filter.MaxLatitude != nil {
+		conditions = append(conditions, `r.destination_latitude <= `+arg(*filter.MaxLatitude))
+	}
+	if filter.MinLongitude != nil {
+		conditions = append(conditions, `r.destination_longitude >= `+arg(*filter.MinLongitude))
+	}
+	if filter.MaxLongitude != nil {
+		conditions = append(conditions, `r.destination_longitude <= `+arg(*filter.MaxLongitude))
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, `r.created_at >= `+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, `r.created_at < `+arg(*filter.CreatedTo))
+	}
+	if filter.After != nil {
+		conditions = append(conditions,
+			`(r.created_at, r.id) > (`+arg(filter.After.CreatedAt)+`, `+arg(filter.After.ID)+`)`)
+	}
 
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO reservations (id, destination_latitude, destination_longitude) VALUES ($1, $2, $3)`,
-		reservation.ID, reservation.DestinationLocation.Latitude, reservation.DestinationLocation.Longitude)
+	query := `SELECT r.id, r.destination_latitude, r.destination_longitude, r.status, r.strategy, r.created_at,
+		r.expires_at, r.version
+		FROM reservations r`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	query += ` ORDER BY r.created_at, r.id LIMIT ` + arg(filter.Limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("inserting into reservations table: %w", err)
+		return nil, fmt.Errorf("looking up in reservations table: %w", err)
 	}
+	defer rows.Close()
 
-	stmt, err := tx.PrepareContext(ctx,
-		`INSERT INTO reservation_items (reservation_id, item_id, storehouse_id, items_count) VALUES ($1, $2, $3, $4)`)
+	reservations := make([]domain.Reservation, 0)
+	indexes := make(map[string]int)
+	for rows.Next() {
+		reservation := domain.Reservation{Entries: make([]domain.ReserveEntry, 0)}
+		err = rows.Scan(&reservation.ID, &reservation.DestinationLocation.Latitude, &reservation.DestinationLocation.Longitude,
+			&reservation.Status, &reservation.Strategy, &reservation.CreatedAt, &reservation.ExpiresAt, &reservation.Version)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		indexes[reservation.ID] = len(reservations)
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over reservations rows: %w", err)
+	}
+
+	ids := make([]string, 0, len(reservations))
+	for _, reservation := range reservations {
+		ids = append(ids, reservation.ID)
+	}
+
+	rows, err = db.QueryContext(ctx,
+		`SELECT reservation_id, item_id, storehouse_id, items_count, manual FROM reservation_items
+		 WHERE reservation_id = ANY($1) ORDER BY id`, pq.Array(ids))
 	if err != nil {
-		return fmt.Errorf("preparing statement for reservation_items: %w", err)
+		return nil, fmt.Errorf("looking up in reservation_items table: %w", err)
 	}
+	defer rows.Close()
 
-	var resultErr error
-	for _, entry := range reservation.Entries {
-		_, err = stmt.ExecContext(ctx, reservation.ID, entry.ItemID, entry.SourceStorehouseID, entry.Count)
+	for rows.Next() {
+		var reservationID string
+		var entry domain.ReserveEntry
+		err = rows.Scan(&reservationID, &entry.ItemID, &entry.SourceStorehouseID, &entry.Count, &entry.Manual)
 		if err != nil {
-			resultErr = errors.Join(resultErr, fmt.Errorf("executing statement for reservation_items: %w", err))
+			return nil, fmt.Errorf("scanning row: %w", err)
 		}
+
+		i := indexes[reservationID]
+		reservations[i].Entries = append(reservations[i].Entries, entry)
 	}
 
-	resultErr = errors.Join(resultErr, tx.Commit())
-	if resultErr != nil {
-		return fmt.Errorf("statement work and transaction commitment: %w", err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over reservation_items rows: %w", err)
 	}
 
-	return nil
+	return reservations, nil
 }
 
-func (repo PostgresReservationRepository) Update(ctx context.Context, reservation domain.Reservation) error {
-	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault, ReadOnly: false})
+func (repo PostgresReservationRepository) GetExpiredIDs(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
+		`SELECT id FROM reservations WHERE status = $1 AND expires_at <= $2 ORDER BY expires_at LIMIT $3`,
+		domain.Held, now, limit)
 	if err != nil {
-		return fmt.Errorf("starting transaction: %w", err)
+		return nil, fmt.Errorf("looking up in reservations table: %w", err)
 	}
+	defer rows.Close()
 
-	defer func() {
-		// TODO
-		_ = tx.Rollback()
-	}()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
 
-	_, err = tx.ExecContext(ctx,
-		`UPDATE reservations SET destination_latitude = $2, destination_longitude = $3 WHERE id = $1`,
-		reservation.ID, reservation.DestinationLocation.Latitude, reservation.DestinationLocation.Longitude)
-	if err != nil {
-		return fmt.Errorf("updating reservations table: %w", err)
+		ids = append(ids, id)
 	}
 
-	// TODO: calculate changes instead of deleting-inserting all content
-	_, err = tx.ExecContext(ctx,
-		`DELETE FROM reservation_items WHERE reservation_id = $1`, reservation.ID)
-	if err != nil {
-		return fmt.Errorf("deleting associated reservation_items: %w", err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over reservations rows: %w", err)
 	}
 
+	return ids, nil
+}
+
+func insertReservationItems(ctx context.Context, tx *sql.Tx, reservation domain.Reservation) error {
 	stmt, err := tx.PrepareContext(ctx,
-		`INSERT INTO reservation_items (reservation_id, item_id, storehouse_id, items_count) VALUES ($1, $2, $3, $4)`)
+		`INSERT INTO reservation_items (reservation_id, item_id, storehouse_id, items_count, manual)
+		 VALUES ($1, $2, $3, $4, $5)`)
 	if err != nil {
 		return fmt.Errorf("preparing statement for reservation_items: %w", err)
 	}
 
+	defer func() {
+		_ = stmt.Close()
+	}()
+
 	var resultErr error
 	for _, entry := range reservation.Entries {
-		_, err = stmt.ExecContext(ctx, reservation.ID, entry.ItemID, entry.SourceStorehouseID, entry.Count)
+		_, err = stmt.ExecContext(ctx, reservation.ID, entry.ItemID, entry.SourceStorehouseID, entry.Count, entry.Manual)
 		if err != nil {
 			resultErr = errors.Join(resultErr, fmt.Errorf("executing statement for reservation_items: %w", err))
 		}
 	}
 
-	resultErr = errors.Join(resultErr, tx.Commit())
-	if resultErr != nil {
-		return fmt.Errorf("statement work and transaction commitment: %w", err)
-	}
-
-	return nil
+	return resultErr
 }
 
-func (repo PostgresReservationRepository) Delete(ctx context.Context, id string) error {
-	_, err := repo.db.ExecContext(ctx,
-		`DELETE FROM reservation_items WHERE reservation_id = $1`, id)
+func checkVersionedUpdate(result sql.Result, id string) error {
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("deleting associated reservation_items: %w", err)
+		return fmt.Errorf("checking updated version: %w", err)
 	}
 
-	_, err = repo.db.ExecContext(ctx,
-		`DELETE FROM reservations WHERE id = $1`, id)
-	if err != nil {
-		return fmt.Errorf("deleting reservation: %w", err)
+	if affected == 0 {
+		return fmt.Errorf("%w: reservation %s", ports.ErrConcurrentModification, id)
 	}
 
 	return nil
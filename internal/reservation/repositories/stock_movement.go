@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+)
+
+type PostgresStockMovementRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresStockMovement(db *sql.DB) *PostgresStockMovementRepository {
+	return &PostgresStockMovementRepository{db: db}
+}
+
+func (repo PostgresStockMovementRepository) Append(ctx context.Context, movements []domain.StockMovement) error {
+	return inTransaction(ctx, repo.db, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx,
+			`INSERT INTO stock_movements (id, type, storehouse_id, item_id, quantity, available_delta, reserved_delta,
+				reservation_id, reason, actor, comment, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12)`)
+		if err != nil {
+			return fmt.Errorf("preparing statement for stock_movements: %w", err)
+		}
+
+		defer func() {
+			_ = stmt.Close()
+		}()
+
+		for _, movement := range movements {
+			_, err = stmt.ExecContext(ctx, movement.ID, movement.Type, movement.StorehouseID, movement.ItemID,
+				movement.Quantity, movement.AvailableDelta, movement.ReservedDelta, movement.ReservationID,
+				movement.Reason, movement.Actor, movement.Comment, movement.CreatedAt)
+			if err != nil {
+				return fmt.Errorf("inserting into stock_movements table: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo PostgresStockMovementRepository) GetStockAt(ctx context.Context, storehouseID domain.StoreHouseID,
+	itemID domain.ItemID, at time.Time) ([]domain.StockLevel, error) {
+
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
+		`SELECT item_id, SUM(available_delta + reserved_delta), SUM(reserved_delta), SUM(available_delta) FROM stock_movements
+		 WHERE storehouse_id = $1 AND ($2 = '' OR item_id = $2) AND created_at <= $3
+		 GROUP BY item_id
+		 HAVING SUM(available_delta) <> 0 OR SUM(reserved_delta) <> 0
+		 ORDER BY item_id`,
+		storehouseID, itemID, at)
+	if err != nil {
+		return nil, fmt.Errorf("looking up in stock_movements table: %w", err)
+	}
+	defer rows.Close()
+
+	levels := make([]domain.StockLevel, 0)
+	for rows.Next() {
+		var level domain.StockLevel
+		err = rows.Scan(&level.ItemID, &level.OnHand, &level.Reserved, &level.Available)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		levels = append(levels, level)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over stock_movements rows: %w", err)
+	}
+
+	return levels, nil
+}
@@ -3,11 +3,19 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/lib/pq"
+
 	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
 )
 
+// uniqueViolationCode is the postgres error code of a unique constraint violation
+const uniqueViolationCode = "23505"
+
 type PostgresStorehouseRepository struct {
 	db *sql.DB
 }
@@ -16,55 +24,107 @@ func NewPostgresStorehouse(db *sql.DB) *PostgresStorehouseRepository {
 	return &PostgresStorehouseRepository{db: db}
 }
 
-func (repo PostgresStorehouseRepository) GetItemsByID(ctx context.Context, id domain.StoreHouseID) (map[domain.ItemID]domain.ItemData, error) {
-	rows, err := repo.db.QueryContext(ctx,
-		`SELECT item_id, items_count FROM storehouses_items WHERE storehouse_id = $1`, id)
+func (repo PostgresStorehouseRepository) GetItemsByID(ctx context.Context, id domain.StoreHouseID, itemIDs []domain.ItemID) (map[domain.ItemID]domain.ItemData, error) {
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
+		`SELECT i.id, i.name, i.length_meters, i.width_meters, i.height_meters, i.weight_kg, si.on_hand, si.reserved, si.available
+		FROM storehouses_items AS si JOIN items AS i ON i.id = si.item_id
+		WHERE si.storehouse_id = $1 AND si.on_hand > 0 AND (cardinality($2::text[]) = 0 OR si.item_id = ANY($2))`,
+		id, pq.Array(itemIDs))
 	if err != nil {
 		return nil, fmt.Errorf("looking up in storehouses_items table: %w", err)
 	}
+	defer rows.Close()
 
-	// TODO: should add full info about items, not only their IDs?
 	unreserved := make(map[domain.ItemID]domain.ItemData)
 	for rows.Next() {
-		var itemData domain.ItemData
-		err = rows.Scan(&itemData.Item.ID, &itemData.Count)
+		itemData, err := scanItemData(rows)
 		if err != nil {
-			return nil, fmt.Errorf("scanning row: %w", err)
+			return nil, err
 		}
 
 		unreserved[itemData.Item.ID] = itemData
 	}
 
-	if rows.Err() != nil {
+	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("after iterating over storehouses_items rows: %w", err)
 	}
 
 	return unreserved, nil
 }
 
-func (repo PostgresStorehouseRepository) GetAllAsMap(ctx context.Context) (map[domain.StoreHouseID]domain.StoreHouse, error) {
-	rows, err := repo.db.QueryContext(ctx, `SELECT id, name, latitude, longitude FROM storehouses`)
+func (repo PostgresStorehouseRepository) GetUnreserved(ctx context.Context, filter ports.UnreservedFilter) ([]domain.ItemData, error) {
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
+		`SELECT i.id, i.name, i.length_meters, i.width_meters, i.height_meters, i.weight_kg, si.on_hand, si.reserved, si.available
+		FROM storehouses_items AS si JOIN items AS i ON i.id = si.item_id
+		WHERE si.storehouse_id = $1 AND si.available > 0 AND (cardinality($2::text[]) = 0 OR si.item_id = ANY($2))
+		AND si.item_id > $3 ORDER BY si.item_id LIMIT $4`,
+		filter.StorehouseID, pq.Array(filter.ItemIDs), filter.AfterID, filter.Limit)
 	if err != nil {
-		return nil, fmt.Errorf("looking up in storehouses table: %w", err)
+		return nil, fmt.Errorf("looking up in storehouses_items table: %w", err)
 	}
+	defer rows.Close()
 
-	storehouses := make(map[domain.StoreHouseID]domain.StoreHouse)
+	unreserved := make([]domain.ItemData, 0)
 	for rows.Next() {
-		var storehouse domain.StoreHouse
-		err = rows.Scan(&storehouse.ID, &storehouse.Name, &storehouse.Location.Latitude, &storehouse.Location.Longitude)
+		itemData, err := scanItemData(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		unreserved = append(unreserved, itemData)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over storehouses_items rows: %w", err)
+	}
+
+	return unreserved, nil
+}
+
+func (repo PostgresStorehouseRepository) GetItemsInActiveStorehouses(ctx context.Context, itemIDs []domain.ItemID) (map[domain.StoreHouseID]map[domain.ItemID]domain.ItemData, error) {
+	rows, err := getExecutor(ctx, repo.db).QueryContext(ctx,
+		`SELECT si.storehouse_id, i.id, i.name, i.length_meters, i.width_meters, i.height_meters, i.weight_kg,
+		si.on_hand, si.reserved, si.available
+		FROM storehouses_items AS si JOIN storehouses AS s ON s.id = si.storehouse_id JOIN items AS i ON i.id = si.item_id
+		WHERE NOT s.deactivated AND s.status = 'open' AND si.on_hand > 0 AND (cardinality($1::text[]) = 0 OR si.item_id = ANY($1))`,
+		pq.Array(itemIDs))
+	if err != nil {
+		return nil, fmt.Errorf("looking up in storehouses_items table: %w", err)
+	}
+	defer rows.Close()
+
+	itemsByStorehouse := make(map[domain.StoreHouseID]map[domain.ItemID]domain.ItemData)
+	for rows.Next() {
+		var storehouseID domain.StoreHouseID
+		itemData := domain.ItemData{Item: domain.Item{Size: &domain.Size{}}}
+		err = rows.Scan(&storehouseID, &itemData.Item.ID, &itemData.Item.Name, &itemData.Item.Size.LengthMeters,
+			&itemData.Item.Size.WidthMeters, &itemData.Item.Size.HeightMeters, &itemData.Item.WeightKilograms,
+			&itemData.OnHand, &itemData.Reserved, &itemData.Available)
 		if err != nil {
 			return nil, fmt.Errorf("scanning row: %w", err)
 		}
 
-		storehouses[storehouse.ID] = storehouse
+		if itemsByStorehouse[storehouseID] == nil {
+			itemsByStorehouse[storehouseID] = make(map[domain.ItemID]domain.ItemData)
+		}
+		itemsByStorehouse[storehouseID][itemData.Item.ID] = itemData
 	}
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("after iterating over storehouses rows: %w", err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over storehouses_items rows: %w", err)
+	}
+
+	return itemsByStorehouse, nil
+}
+
+func (repo PostgresStorehouseRepository) GetAllAsMap(ctx context.Context) (map[domain.StoreHouseID]domain.StoreHouse, error) {
+	storehouses, err := getStorehouses(ctx, getExecutor(ctx, repo.db), "")
+	if err != nil {
+		return nil, err
 	}
 
 	for id, storehouse := range storehouses {
-		itemsData, err := repo.GetItemsByID(ctx, id)
+		itemsData, err := repo.GetItemsByID(ctx, id, nil)
 		if err != nil {
 			return nil, fmt.Errorf("subquery for storehouses_items: %w", err)
 		}
@@ -76,47 +136,261 @@ func (repo PostgresStorehouseRepository) GetAllAsMap(ctx context.Context) (map[d
 	return storehouses, nil
 }
 
-func (repo PostgresStorehouseRepository) UpdateAll(ctx context.Context, storehouses map[domain.StoreHouseID]domain.StoreHouse) error {
-	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault, ReadOnly: false})
+func (repo PostgresStorehouseRepository) GetByID(ctx context.Context, id domain.StoreHouseID) (domain.StoreHouse, error) {
+	storehouse, err := scanStorehouse(getExecutor(ctx, repo.db).QueryRowContext(ctx,
+		`SELECT `+storehouseColumns+` FROM storehouses WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.StoreHouse{}, fmt.Errorf("%w: %s", domain.ErrUnknownStorehouse, id)
+	}
 	if err != nil {
-		return fmt.Errorf("starting transaction: %w", err)
+		return domain.StoreHouse{}, fmt.Errorf("looking up in storehouses table: %w", err)
 	}
 
-	defer func() {
-		// TODO
-		_ = tx.Rollback()
-	}()
+	storehouse.ItemsData, err = repo.GetItemsByID(ctx, id, nil)
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("subquery for storehouses_items: %w", err)
+	}
+
+	return storehouse, nil
+}
+
+func (repo PostgresStorehouseRepository) Create(ctx context.Context, storehouse domain.StoreHouse) error {
+	schedule, err := json.Marshal(storehouse.Schedule)
+	if err != nil {
+		return fmt.Errorf("encoding schedule: %w", err)
+	}
+
+	maxWeight, maxVolume, maxLength, maxCount := vehicleLimits(storehouse.Vehicle)
+	_, err = getExecutor(ctx, repo.db).ExecContext(ctx,
+		`INSERT INTO storehouses (`+storehouseColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		storehouse.ID, storehouse.Name, storehouse.Location.Latitude, storehouse.Location.Longitude, storehouse.Deactivated,
+		storehouse.Status, schedule, maxWeight, maxVolume, maxLength, maxCount)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+		return fmt.Errorf("%w: id: %s, name: %s", domain.ErrStorehouseAlreadyExists, storehouse.ID, storehouse.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("inserting into storehouses table: %w", err)
+	}
+
+	return nil
+}
+
+// Update saves name, location, deactivation flag, status, schedule and vehicle profile of the storehouse.
+// Stock is changed only with ApplyDeltas
+func (repo PostgresStorehouseRepository) Update(ctx context.Context, storehouse domain.StoreHouse) error {
+	schedule, err := json.Marshal(storehouse.Schedule)
+	if err != nil {
+		return fmt.Errorf("encoding schedule: %w", err)
+	}
+
+	maxWeight, maxVolume, maxLength, maxCount := vehicleLimits(storehouse.Vehicle)
+	result, err := getExecutor(ctx, repo.db).ExecContext(ctx,
+		`UPDATE storehouses SET name = $2, latitude = $3, longitude = $4, deactivated = $5, status = $6, schedule = $7,
+		 vehicle_max_weight_kg = $8, vehicle_max_volume_m3 = $9, vehicle_max_length_m = $10, vehicle_max_count = $11
+		 WHERE id = $1`,
+		storehouse.ID, storehouse.Name, storehouse.Location.Latitude, storehouse.Location.Longitude, storehouse.Deactivated,
+		storehouse.Status, schedule, maxWeight, maxVolume, maxLength, maxCount)
 
-	//TODO: make better query instead of multiple delete-insert. Maybe domain logic is the one that should be changed
-	deleteStmt, err := tx.PrepareContext(ctx, `DELETE FROM storehouses_items WHERE storehouse_id = $1`)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+		return fmt.Errorf("%w: name: %s", domain.ErrStorehouseAlreadyExists, storehouse.Name)
+	}
 	if err != nil {
-		return fmt.Errorf("preparing deletion query: %w", err)
+		return fmt.Errorf("updating storehouses table: %w", err)
 	}
 
-	insertStmt, err := tx.PrepareContext(ctx,
-		`INSERT INTO storehouses_items (storehouse_id, item_id, items_count) VALUES ($1, $2, $3)`)
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("preparing insertion query: %w", err)
+		return fmt.Errorf("getting affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", domain.ErrUnknownStorehouse, storehouse.ID)
 	}
 
-	for _, storehouse := range storehouses {
-		_, err = deleteStmt.ExecContext(ctx, storehouse.ID)
+	return nil
+}
+
+const storehouseColumns = `id, name, latitude, longitude, deactivated, status, schedule,
+	vehicle_max_weight_kg, vehicle_max_volume_m3, vehicle_max_length_m, vehicle_max_count`
+
+// scanStorehouse scans storehouseColumns, the vehicle profile is nil if its limits are NULL
+func scanStorehouse(row rowScanner) (domain.StoreHouse, error) {
+	var storehouse domain.StoreHouse
+	var schedule []byte
+	var maxWeight, maxVolume, maxLength sql.NullFloat64
+	var maxCount sql.NullInt64
+
+	err := row.Scan(&storehouse.ID, &storehouse.Name, &storehouse.Location.Latitude, &storehouse.Location.Longitude,
+		&storehouse.Deactivated, &storehouse.Status, &schedule, &maxWeight, &maxVolume, &maxLength, &maxCount)
+	if err != nil {
+		return domain.StoreHouse{}, err
+	}
+
+	err = json.Unmarshal(schedule, &storehouse.Schedule)
+	if err != nil {
+		return domain.StoreHouse{}, fmt.Errorf("decoding schedule: %w", err)
+	}
+
+	if maxWeight.Valid {
+		storehouse.Vehicle = &domain.VehicleProfile{
+			MaxWeightKilograms: maxWeight.Float64,
+			MaxVolumeM3:        maxVolume.Float64,
+			MaxLengthMeters:    maxLength.Float64,
+			MaxVehicles:        int(maxCount.Int64),
+		}
+	}
+
+	return storehouse, nil
+}
+
+// vehicleLimits returns values of vehicle columns, all of them are NULL without the profile
+func vehicleLimits(vehicle *domain.VehicleProfile) (maxWeight, maxVolume, maxLength, maxCount any) {
+	if vehicle == nil {
+		return nil, nil, nil, nil
+	}
+
+	return vehicle.MaxWeightKilograms, vehicle.MaxVolumeM3, vehicle.MaxLengthMeters, vehicle.MaxVehicles
+}
+
+// scanItemData scans the item joined with its stock
+func scanItemData(row rowScanner) (domain.ItemData, error) {
+	itemData := domain.ItemData{Item: domain.Item{Size: &domain.Size{}}}
+
+	err := row.Scan(&itemData.Item.ID, &itemData.Item.Name, &itemData.Item.Size.LengthMeters, &itemData.Item.Size.WidthMeters,
+		&itemData.Item.Size.HeightMeters, &itemData.Item.WeightKilograms, &itemData.OnHand, &itemData.Reserved, &itemData.Available)
+	if err != nil {
+		return domain.ItemData{}, fmt.Errorf("scanning row: %w", err)
+	}
+
+	return itemData, nil
+}
+
+// getStorehouses reads all storehouses without stock. A non-empty locking clause locks the rows in the order of IDs
+func getStorehouses(ctx context.Context, db executor, lockingClause string) (map[domain.StoreHouseID]domain.StoreHouse, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+storehouseColumns+` FROM storehouses ORDER BY id `+lockingClause)
+	if err != nil {
+		return nil, fmt.Errorf("looking up in storehouses table: %w", err)
+	}
+	defer rows.Close()
+
+	storehouses := make(map[domain.StoreHouseID]domain.StoreHouse)
+	for rows.Next() {
+		storehouse, err := scanStorehouse(rows)
 		if err != nil {
-			return fmt.Errorf("deleting: %w", err)
+			return nil, fmt.Errorf("scanning row: %w", err)
 		}
 
-		for itemID, itemData := range storehouse.ItemsData {
-			_, err = insertStmt.ExecContext(ctx, storehouse.ID, itemID, itemData.Count)
+		storehouse.ItemsData = make(map[domain.ItemID]domain.ItemData)
+		storehouses[storehouse.ID] = storehouse
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over storehouses rows: %w", err)
+	}
+
+	return storehouses, nil
+}
+
+// ApplyDeltas takes items with a guarded update, so available and reserved units never become negative,
+// and adds items with an upsert, because the row may not exist yet
+func (repo PostgresStorehouseRepository) ApplyDeltas(ctx context.Context, deltas []domain.StockDelta) error {
+	return inTransaction(ctx, repo.db, func(tx *sql.Tx) error {
+		return applyDeltas(ctx, tx, deltas)
+	})
+}
+
+func applyDeltas(ctx context.Context, tx *sql.Tx, deltas []domain.StockDelta) error {
+	for _, delta := range deltas {
+		if delta.Count >= 0 && delta.Reserved >= 0 {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO storehouses_items (storehouse_id, item_id, on_hand, reserved) VALUES ($1, $2, $3, $4)
+				 ON CONFLICT (storehouse_id, item_id) DO UPDATE
+				 SET on_hand = storehouses_items.on_hand + EXCLUDED.on_hand, reserved = storehouses_items.reserved + EXCLUDED.reserved`,
+				delta.StorehouseID, delta.ItemID, delta.OnHand(), delta.Reserved)
 			if err != nil {
-				return fmt.Errorf("inserting: %w", err)
+				return fmt.Errorf("adding items to storehouses_items: %w", err)
 			}
+
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx,
+			`UPDATE storehouses_items SET on_hand = on_hand + $3, reserved = reserved + $4
+			 WHERE storehouse_id = $1 AND item_id = $2 AND available + $3 - $4 >= 0 AND reserved + $4 >= 0`,
+			delta.StorehouseID, delta.ItemID, delta.OnHand(), delta.Reserved)
+		if err != nil {
+			return fmt.Errorf("taking items from storehouses_items: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("getting affected rows: %w", err)
+		}
+
+		if affected == 0 {
+			return fmt.Errorf("%w: storehouse id: %s, item id: %s",
+				domain.ErrNotEnoughItemsInStorehouse, delta.StorehouseID, delta.ItemID)
 		}
 	}
 
-	err = tx.Commit()
+	return nil
+}
+
+// LockStorehouses locks rows of all storehouses with SELECT ... FOR UPDATE in the order of IDs,
+// the same order LockItems takes shared locks in, so they don't deadlock
+func (repo PostgresStorehouseRepository) LockStorehouses(ctx context.Context) error {
+	_, err := getStorehouses(ctx, getExecutor(ctx, repo.db), "FOR UPDATE")
 	if err != nil {
-		return fmt.Errorf("transaction commit: %w", err)
+		return fmt.Errorf("locking storehouses rows: %w", err)
 	}
 
 	return nil
 }
+
+// LockItems locks rows of all storehouses with SELECT ... FOR SHARE and rows of the given items
+// with SELECT ... FOR UPDATE, so concurrent reservations and releases of the same items are applied
+// one after another and storehouses can't be deactivated meanwhile
+func (repo PostgresStorehouseRepository) LockItems(ctx context.Context, itemIDs []domain.ItemID) (map[domain.StoreHouseID]domain.StoreHouse, error) {
+	db := getExecutor(ctx, repo.db)
+
+	storehouses, err := getStorehouses(ctx, db, "FOR SHARE")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		ids = append(ids, string(itemID))
+	}
+
+	// the order is fixed, so transactions lock rows in the same order and don't deadlock
+	rows, err := db.QueryContext(ctx,
+		`SELECT storehouse_id, item_id, on_hand, reserved, available FROM storehouses_items
+		 WHERE item_id = ANY($1) ORDER BY storehouse_id, item_id FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("locking storehouses_items rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var storehouseID domain.StoreHouseID
+		var itemData domain.ItemData
+		err = rows.Scan(&storehouseID, &itemData.Item.ID, &itemData.OnHand, &itemData.Reserved, &itemData.Available)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		if itemData.OnHand > 0 {
+			storehouses[storehouseID].ItemsData[itemData.Item.ID] = itemData
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("after iterating over storehouses_items rows: %w", err)
+	}
+
+	return storehouses, nil
+}
@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/domain"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+// lockWait is long enough for a query which is not blocked to finish
+const lockWait = 200 * time.Millisecond
+
+func TestPostgresStorehouse_LockItems(t *testing.T) {
+	db := newTestDB(t)
+	fillTestNetwork(t, db)
+
+	repo := NewPostgresStorehouse(db)
+	transactions := NewPostgresTransactionManager(db)
+
+	// holdLock locks item "1" in a transaction which is committed by the returned function
+	holdLock := func() func() {
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- transactions.WithinTransaction(context.Background(), func(ctx context.Context) error {
+				storehouses, err := repo.LockItems(ctx, []domain.ItemID{"1"})
+				if err != nil {
+					close(locked)
+					return err
+				}
+
+				assert.Equal(t, testInitialCount, storehouses["a"].ItemsData["1"].Available)
+				assert.NotContains(t, storehouses["a"].ItemsData, domain.ItemID("2"))
+
+				close(locked)
+				<-release
+
+				return nil
+			})
+		}()
+		<-locked
+
+		return func() {
+			close(release)
+			assert.NoError(t, <-done)
+		}
+	}
+
+	release := holdLock()
+
+	// another item is not locked
+	err := transactions.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		_, err := repo.LockItems(ctx, []domain.ItemID{"2"})
+		return err
+	})
+	assert.NoError(t, err)
+
+	release()
+
+	// the same item and storehouses wait for the transaction holding the lock
+	testCases := []struct {
+		name string
+		lock func(ctx context.Context) error
+	}{
+		{
+			name: "same item",
+			lock: func(ctx context.Context) error {
+				_, err := repo.LockItems(ctx, []domain.ItemID{"1"})
+				return err
+			},
+		},
+		{
+			name: "storehouses",
+			lock: repo.LockStorehouses,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			release := holdLock()
+
+			acquired := make(chan error)
+			go func() {
+				acquired <- transactions.WithinTransaction(context.Background(), testCase.lock)
+			}()
+
+			select {
+			case err := <-acquired:
+				release()
+				t.Fatalf("lock is acquired while another transaction holds it, err: %v", err)
+			case <-time.After(lockWait):
+			}
+
+			release()
+			assert.NoError(t, <-acquired)
+		})
+	}
+}
+
+func TestPostgresStorehouse_ApplyDeltas(t *testing.T) {
+	db := newTestDB(t)
+	fillTestNetwork(t, db)
+
+	repo := NewPostgresStorehouse(db)
+	ctx := context.Background()
+
+	err := repo.ApplyDeltas(ctx, []domain.StockDelta{
+		{StorehouseID: "a", ItemID: "1", Count: -5, Reserved: 5},
+		{StorehouseID: "b", ItemID: "1", Count: -testInitialCount - 1},
+	})
+	assert.ErrorIs(t, err, domain.ErrNotEnoughItemsInStorehouse)
+
+	// the whole call is rolled back
+	itemsData, err := repo.GetItemsByID(ctx, "a", []domain.ItemID{"1"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, domain.ItemData{Item: itemsData["1"].Item, OnHand: testInitialCount, Available: testInitialCount},
+		itemsData["1"])
+
+	err = repo.ApplyDeltas(ctx, []domain.StockDelta{
+		{StorehouseID: "a", ItemID: "1", Count: -5, Reserved: 5},
+		{StorehouseID: "a", ItemID: "1", Count: 0, Reserved: -6},
+	})
+	assert.ErrorIs(t, err, domain.ErrNotEnoughItemsInStorehouse)
+
+	// an item which is not kept in the storehouse yet is added
+	_, err = db.Exec(`DELETE FROM storehouses_items WHERE storehouse_id = 'c' AND item_id = '2'`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = repo.ApplyDeltas(ctx, []domain.StockDelta{
+		{StorehouseID: "a", ItemID: "1", Count: -5, Reserved: 5},
+		{StorehouseID: "c", ItemID: "2", Count: 3},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	itemsData, err = repo.GetItemsByID(ctx, "a", []domain.ItemID{"1"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, testInitialCount, itemsData["1"].OnHand)
+	assert.Equal(t, 5, itemsData["1"].Reserved)
+	assert.Equal(t, testInitialCount-5, itemsData["1"].Available)
+
+	itemsData, err = repo.GetItemsByID(ctx, "c", []domain.ItemID{"2"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 3, itemsData["2"].Available)
+}
+
+func TestPostgresStorehouse_GetUnreserved(t *testing.T) {
+	db := newTestDB(t)
+	fillTestNetwork(t, db)
+
+	repo := NewPostgresStorehouse(db)
+	ctx := context.Background()
+
+	// fully reserved items are skipped
+	err := repo.ApplyDeltas(ctx, []domain.StockDelta{{StorehouseID: "b", ItemID: "1", Count: -testInitialCount, Reserved: testInitialCount}})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	page, err := repo.GetUnreserved(ctx, ports.UnreservedFilter{StorehouseID: "a", Limit: 1})
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, domain.ItemID("1"), page[0].Item.ID)
+	}
+
+	page, err = repo.GetUnreserved(ctx, ports.UnreservedFilter{StorehouseID: "a", AfterID: "1", Limit: 2})
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, domain.ItemID("2"), page[0].Item.ID)
+	}
+
+	page, err = repo.GetUnreserved(ctx, ports.UnreservedFilter{StorehouseID: "b", Limit: 2})
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, domain.ItemID("2"), page[0].Item.ID)
+	}
+
+	page, err = repo.GetUnreserved(ctx, ports.UnreservedFilter{StorehouseID: "a", ItemIDs: []domain.ItemID{"2"}, Limit: 2})
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, domain.ItemID("2"), page[0].Item.ID)
+	}
+}
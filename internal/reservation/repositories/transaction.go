@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type txKey struct{}
+
+type PostgresTransactionManager struct {
+	db *sql.DB
+}
+
+func NewPostgresTransactionManager(db *sql.DB) *PostgresTransactionManager {
+	return &PostgresTransactionManager{db: db}
+}
+
+func (manager PostgresTransactionManager) WithinTransaction(ctx context.Context, operation func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return operation(ctx)
+	}
+
+	tx, err := manager.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault, ReadOnly: false})
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = operation(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("transaction commit: %w", err)
+	}
+
+	return nil
+}
+
+// executor is implemented by both *sql.DB and *sql.Tx
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// getExecutor returns the transaction of the context if there is one, so reads see uncommitted changes of the unit of work
+func getExecutor(ctx context.Context, db *sql.DB) executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// inTransaction runs operation in the transaction of the context or in a new one
+func inTransaction(ctx context.Context, db *sql.DB, operation func(tx *sql.Tx) error) error {
+	return NewPostgresTransactionManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+		return operation(ctx.Value(txKey{}).(*sql.Tx))
+	})
+}
@@ -0,0 +1,106 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adepte-myao/lamoda-test-2023/configs"
+	"github.com/adepte-myao/lamoda-test-2023/internal/pkg/logger"
+	"github.com/adepte-myao/lamoda-test-2023/internal/reservation/core/ports"
+)
+
+var ErrInvalidSweeperConfig = errors.New("invalid sweeper config")
+
+// ExpirySweeper periodically releases reservations whose hold time is over and deletes old idempotency keys
+type ExpirySweeper struct {
+	service   ports.ReservationService
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+	logger    logger.Logger
+}
+
+// NewExpirySweeper fails if the interval, the batch size or the retention is not positive:
+// the ticker can't be created with such an interval, an empty batch never releases anything
+// and keys without retention would be deleted before a retry could use them
+func NewExpirySweeper(config configs.AppConfig, service ports.ReservationService, logger logger.Logger) (*ExpirySweeper, error) {
+	if config.Sweeper.IntervalSeconds <= 0 {
+		return nil, fmt.Errorf("%w: sweeper.interval_seconds must be positive, got %d",
+			ErrInvalidSweeperConfig, config.Sweeper.IntervalSeconds)
+	}
+
+	if config.Sweeper.BatchSize <= 0 {
+		return nil, fmt.Errorf("%w: sweeper.batch_size must be positive, got %d",
+			ErrInvalidSweeperConfig, config.Sweeper.BatchSize)
+	}
+
+	if config.Sweeper.IdempotencyRetentionHours <= 0 {
+		return nil, fmt.Errorf("%w: sweeper.idempotency_retention_hours must be positive, got %d",
+			ErrInvalidSweeperConfig, config.Sweeper.IdempotencyRetentionHours)
+	}
+
+	return &ExpirySweeper{
+		service:   service,
+		interval:  time.Second * time.Duration(config.Sweeper.IntervalSeconds),
+		batchSize: config.Sweeper.BatchSize,
+		retention: time.Hour * time.Duration(config.Sweeper.IdempotencyRetentionHours),
+		logger:    logger,
+	}, nil
+}
+
+// Run sweeps expired reservations until ctx is done
+func (sweeper *ExpirySweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweeper.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweeper.sweep(ctx)
+			sweeper.deleteIdempotencyKeys(ctx)
+		}
+	}
+}
+
+// sweep releases expired reservations batch by batch until there are no more of them
+func (sweeper *ExpirySweeper) sweep(ctx context.Context) {
+	for ctx.Err() == nil {
+		released, err := sweeper.service.ReleaseExpired(time.Now(), sweeper.batchSize)
+		if released > 0 {
+			sweeper.logger.Info("Released expired reservations: ", released)
+		}
+
+		if err != nil {
+			sweeper.logger.Error(err)
+			return
+		}
+
+		if released < sweeper.batchSize {
+			return
+		}
+	}
+}
+
+// deleteIdempotencyKeys deletes keys older than the retention batch by batch until there are no more of them
+func (sweeper *ExpirySweeper) deleteIdempotencyKeys(ctx context.Context) {
+	before := time.Now().Add(-sweeper.retention)
+	for ctx.Err() == nil {
+		deleted, err := sweeper.service.DeleteIdempotencyKeys(before, sweeper.batchSize)
+		if deleted > 0 {
+			sweeper.logger.Info("Deleted idempotency keys: ", deleted)
+		}
+
+		if err != nil {
+			sweeper.logger.Error(err)
+			return
+		}
+
+		if deleted < sweeper.batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/adepte-myao/lamoda-test-2023/configs"
+)
+
+func TestNewExpirySweeper(t *testing.T) {
+	var config configs.AppConfig
+	config.Sweeper.IntervalSeconds = 30
+	config.Sweeper.BatchSize = 100
+	config.Sweeper.IdempotencyRetentionHours = 24
+
+	_, err := NewExpirySweeper(config, nil, nil)
+	assert.NoError(t, err)
+
+	config.Sweeper.IntervalSeconds = 0
+	_, err = NewExpirySweeper(config, nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSweeperConfig)
+
+	config.Sweeper.IntervalSeconds = 30
+	config.Sweeper.BatchSize = 0
+	_, err = NewExpirySweeper(config, nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSweeperConfig)
+
+	config.Sweeper.BatchSize = 100
+	config.Sweeper.IdempotencyRetentionHours = 0
+	_, err = NewExpirySweeper(config, nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidSweeperConfig)
+}